@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/auth"
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/config"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/preview"
+	"golang.org/x/term"
+)
+
+// commands are the subcommands of the binary, running it without any starts the server.
+var commands = map[string]func(args []string) error{
+	"preview":       previewCmd,
+	"lint":          lintCmd,
+	"import":        importCmd,
+	"export":        exportCmd,
+	"hash-password": hashPasswordCmd,
+}
+
+// runCommand runs the subcommand name with its arguments.
+func runCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd(args)
+}
+
+// previewCmd prints a signed, expiring preview URL of a post.
+func previewCmd(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	id := fs.String("id", "", "ID of the post to preview")
+	ttl := fs.Duration("ttl", 0, "validity of the link (default preview.ttl from config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		fs.Usage()
+		return fmt.Errorf("preview: -id is required")
+	}
+
+	conf, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+	signer, err := preview.NewSigner([]byte(conf.GetString("preview.key")))
+	if err != nil {
+		return fmt.Errorf("preview: %v", err)
+	}
+	if *ttl == 0 {
+		*ttl = conf.GetDuration("preview.ttl")
+	}
+	fmt.Fprintln(os.Stdout, signer.URL(conf.BaseURL(), *id, *ttl))
+	fmt.Fprintf(os.Stderr, "valid until %s\n", time.Now().Add(*ttl).Format(time.RFC1123))
+	return nil
+}
+
+// lintCmd validates the content tree and fails if it has any issue.
+func lintCmd(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fileName := fs.String("file", "", "posts json file (default posts.file from config)")
+	mdDir := fs.String("mddir", "", "markdown directory (default posts.mddir from config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conf, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+	content, err := contentOptions(conf, *fileName, *mdDir)
+	if err != nil {
+		return fmt.Errorf("lint: %v", err)
+	}
+	issues, err := post.Lint(post.LintOptions{
+		Storage:  content.Storage,
+		FileName: content.FileName,
+		MdDir:    content.MdDir,
+		Location: content.Location,
+		Authors:  content.Authors,
+	})
+	if err != nil {
+		return fmt.Errorf("lint: %v", err)
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stdout, issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("lint: %d issues found", len(issues))
+	}
+	fmt.Fprintln(os.Stderr, "no issues found")
+	return nil
+}
+
+// importCmd migrates the posts.json file and the markdown directory into the SQLite database.
+func importCmd(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	database := fs.String("db", "", "SQLite database (default posts.database from config)")
+	fileName := fs.String("file", "", "posts json file (default posts.file from config)")
+	mdDir := fs.String("mddir", "", "markdown directory (default posts.mddir from config)")
+	replace := fs.Bool("replace", false, "delete the posts of the database missing from the files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conf, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+	content, err := contentOptions(conf, *fileName, *mdDir)
+	if err != nil {
+		return fmt.Errorf("import: %v", err)
+	}
+	content.Replace = *replace
+	db, err := post.OpenSQLite(stringOrConfig(*database, conf, "posts.database"))
+	if err != nil {
+		return fmt.Errorf("import: %v", err)
+	}
+	defer db.Close()
+	count, conflicts, err := post.ImportSQLite(db, content)
+	if err != nil {
+		return fmt.Errorf("import: %v", err)
+	}
+	for _, c := range conflicts {
+		fmt.Fprintln(os.Stderr, "warning:", c)
+	}
+	fmt.Fprintf(os.Stderr, "imported %d posts\n", count)
+	return nil
+}
+
+// exportCmd writes the posts of the SQLite database back to a posts.json file and a markdown directory.
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	database := fs.String("db", "", "SQLite database (default posts.database from config)")
+	fileName := fs.String("file", "", "posts json file to write (default posts.file from config)")
+	mdDir := fs.String("mddir", "", "markdown directory to write (default posts.mddir from config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conf, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+	content, err := contentOptions(conf, *fileName, *mdDir)
+	if err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	db, err := post.OpenSQLite(stringOrConfig(*database, conf, "posts.database"))
+	if err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	defer db.Close()
+	count, err := post.ExportSQLite(db, content)
+	if err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d posts\n", count)
+	return nil
+}
+
+// hashPasswordCmd reads a password from the standard input and prints its bcrypt hash for admin.passwordhash.
+// The password is not echoed when typed on a terminal.
+func hashPasswordCmd(args []string) error {
+	fs := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := readPassword(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("hash-password: cannot read password: %v", err)
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash-password: %v", err)
+	}
+	fmt.Fprintln(os.Stdout, hash)
+	return nil
+}
+
+// readPassword reads a line from in without echoing it if in is a terminal, or as it is from a pipe.
+func readPassword(in *os.File) (string, error) {
+	if fd := int(in.Fd()); term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	password, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+// contentOptions returns the content sources of the config, fileName and mdDir override posts.file and posts.mddir.
+// The authors of authors.file are read from the same storage as the posts.
+func contentOptions(conf *config.Config, fileName string, mdDir string) (post.TransferOptions, error) {
+	location, err := time.LoadLocation(conf.GetString("site.timezone"))
+	if err != nil {
+		return post.TransferOptions{}, fmt.Errorf("invalid site.timezone: %v", err)
+	}
+	storage, err := post.NewStorage(post.StorageOptions{
+		Kind:     conf.GetString("posts.storage"),
+		Embedded: embeddedContent(),
+		Archive:  conf.GetString("posts.archive"),
+	})
+	if err != nil {
+		return post.TransferOptions{}, err
+	}
+	authors, err := author.Load(storage, conf.GetString("authors.file"))
+	if err != nil {
+		return post.TransferOptions{}, err
+	}
+	return post.TransferOptions{
+		Storage:  storage,
+		FileName: stringOrConfig(fileName, conf, "posts.file"),
+		MdDir:    stringOrConfig(mdDir, conf, "posts.mddir"),
+		Location: location,
+		Authors:  authors,
+	}, nil
+}
+
+// stringOrConfig returns value, or the config value of key if it is empty.
+func stringOrConfig(value string, conf *config.Config, key string) string {
+	if value == "" {
+		return conf.GetString(key)
+	}
+	return value
+}
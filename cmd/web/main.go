@@ -2,8 +2,9 @@ package main
 
 import (
 	"context"
-	_ "embed"
+	"embed"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -18,15 +19,35 @@ import (
 var (
 	//go:embed VERSION.txt
 	Version string
+
+	// content is compiled into the binary for posts.storage: embed,
+	// copy the posts.json file and the markdown directory into cmd/web/content before building.
+	//go:embed all:content
+	content embed.FS
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("error: %+v", err)
+		}
+		return
+	}
 	fmt.Println(Version)
 	if err := appMain(); err != nil {
 		log.Fatalf("error: %+v", err)
 	}
 }
 
+// embeddedContent returns the content compiled into the binary, rooted at cmd/web/content.
+func embeddedContent() fs.FS {
+	sub, err := fs.Sub(content, "content")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
 // appMain is the main function for the application.
 func appMain() error {
 	conf, err := config.NewConfig()
@@ -37,6 +58,7 @@ func appMain() error {
 	srv, err := app.NewServer(app.ServerOptions{
 		C:       conf,
 		Version: Version,
+		Content: embeddedContent(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create server: %+v", err)
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/auth"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/ui"
+)
+
+// adminUserKey is the context key of the logged in user.
+const adminUserKey = "adminuser"
+
+// requireAdmin is the middleware of the admin API, it aborts requests without the admin.token bearer token
+// or a session cookie. Without a configured token or login every admin request is refused.
+func (a *appServer) requireAdmin(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if ok && a.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) == 1 {
+		c.Set(adminUserKey, a.adminUser)
+		c.Next()
+		return
+	}
+	if a.login(c) {
+		c.Next()
+		return
+	}
+	a.logger.Warnc(c).Str("path", c.Request.URL.Path).Msg("unauthorized admin request")
+	c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+}
+
+// requireLogin is the middleware of the admin pages, it redirects requests without a session to the login page.
+func (a *appServer) requireLogin(c *gin.Context) {
+	if a.login(c) {
+		c.Next()
+		return
+	}
+	target := "/admin/login?next=" + url.QueryEscape(c.Request.URL.RequestURI())
+	if c.GetHeader("HX-Request") == "true" {
+		c.Header("HX-Redirect", target)
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	c.Redirect(http.StatusSeeOther, target)
+	c.Abort()
+}
+
+// login reports whether the request has a valid session and rotates it when it is due.
+// The user of the session is stored in the context under adminUserKey.
+func (a *appServer) login(c *gin.Context) bool {
+	if a.sessions == nil {
+		return false
+	}
+	session, err := a.sessions.Verify(c.Request)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoSession) {
+			a.logger.Warnc(c).Err(err).Msg("rejected session")
+		}
+		return false
+	}
+	if _, rotated := a.sessions.Refresh(c.Writer, session); rotated {
+		a.logger.Debugc(c).Str("user", session.User).Msg("session rotated")
+	}
+	c.Set(adminUserKey, session.User)
+	return true
+}
+
+// LoginPageHandler is the handler for GET /admin/login
+func (a *appServer) LoginPageHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("LoginPageHandler: serving login page")
+	next := safeNext(c.Query("next"))
+	if a.login(c) {
+		c.Redirect(http.StatusSeeOther, next)
+		return
+	}
+	message := ""
+	if a.sessions == nil {
+		message = "Login is disabled, set admin.passwordhash and admin.sessionkey in the config."
+	}
+	if err := presentSubContent(c, ui.Login("", next, message)); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering login failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// LoginHandler is the handler for POST /admin/login
+// It starts a session and redirects to the page the user came from.
+func (a *appServer) LoginHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("LoginHandler: serving login endpoint")
+	user, next := c.PostForm("user"), safeNext(c.PostForm("next"))
+	if a.sessions == nil {
+		a.presentError(c, http.StatusServiceUnavailable, "Login disabled", "Set admin.passwordhash and admin.sessionkey in the config.")
+		return
+	}
+	if err := auth.CheckCredentials(user, c.PostForm("password"), a.adminUser, a.adminPasswordHash); err != nil {
+		log(logger.WarnLevel).Str("user", user).Msg("failed login")
+		if err := presentSubContentWithStatus(c, http.StatusUnauthorized, ui.Login(user, next, "Wrong user or password.")); err != nil {
+			log(logger.ErrorLevel).Err(err).Msg("rendering login failed")
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		}
+		return
+	}
+	a.sessions.Issue(c.Writer, user)
+	log(logger.InfoLevel).Str("user", user).Msg("logged in")
+	c.Redirect(http.StatusSeeOther, next)
+}
+
+// LogoutPageHandler is the handler for GET /admin/logout
+func (a *appServer) LogoutPageHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("LogoutPageHandler: serving logout page")
+	if err := presentSubContent(c, ui.Logout(c.GetString(adminUserKey))); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering logout failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// LogoutHandler is the handler for POST /admin/logout
+func (a *appServer) LogoutHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("LogoutHandler: serving logout endpoint")
+	if a.sessions != nil {
+		a.sessions.Clear(c.Writer)
+	}
+	if err := presentSubContent(c, ui.Login("", "/admin", "You have been logged out.")); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering login failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// AdminHandler is the handler for GET /admin
+func (a *appServer) AdminHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AdminHandler: serving admin page")
+	if err := presentSubContent(c, ui.AdminHome(c.GetString(adminUserKey))); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering admin failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// safeNext returns next if it is a path on this site, /admin otherwise, so that the login cannot redirect elsewhere.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/admin"
+	}
+	return next
+}
+
+// AdminGetPostHandler is the handler for GET /admin/api/posts/:id
+// It returns the post even if it is unpublished, with its markdown body.
+func (a *appServer) AdminGetPostHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AdminGetPostHandler: serving admin post endpoint")
+	p, err := a.pService.GetUnpublishedPost(log, c.Param("id"))
+	if err != nil {
+		a.writeAdminError(c, log, err)
+		return
+	}
+	markdown, err := a.readMarkdown(p)
+	if err != nil {
+		a.writeAdminError(c, log, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"post": p, "markdown": markdown})
+}
+
+// AdminCreatePostHandler is the handler for POST /admin/api/posts
+func (a *appServer) AdminCreatePostHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AdminCreatePostHandler: serving admin create post endpoint")
+	var in post.PostInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("decoding post failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json: " + err.Error()})
+		return
+	}
+	p, err := a.pService.CreatePost(log, in)
+	if err != nil {
+		a.writeAdminError(c, log, err)
+		return
+	}
+	a.postSaved(c, p, in.Markdown, "")
+	c.Header("Location", "/admin/api/posts/"+p.ID)
+	c.JSON(http.StatusCreated, gin.H{"post": p})
+}
+
+// AdminUpdatePostHandler is the handler for PUT /admin/api/posts/:id
+func (a *appServer) AdminUpdatePostHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AdminUpdatePostHandler: serving admin update post endpoint")
+	var in post.PostInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("decoding post failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json: " + err.Error()})
+		return
+	}
+	a.recordBaseline(c, log, c.Param("id"))
+	p, err := a.pService.UpdatePost(log, c.Param("id"), in)
+	if err != nil {
+		a.writeAdminError(c, log, err)
+		return
+	}
+	a.postSaved(c, p, in.Markdown, "")
+	c.JSON(http.StatusOK, gin.H{"post": p})
+}
+
+// AdminDeletePostHandler is the handler for DELETE /admin/api/posts/:id
+func (a *appServer) AdminDeletePostHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AdminDeletePostHandler: serving admin delete post endpoint")
+	a.recordBaseline(c, log, c.Param("id"))
+	if err := a.pService.DeletePost(log, c.Param("id")); err != nil {
+		a.writeAdminError(c, log, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// postSaved keeps a saved post as a new revision and notifies the pages it links to if it is published.
+func (a *appServer) postSaved(c *gin.Context, saved post.Post, markdown string, note string) {
+	a.recordRevision(c, saved, markdown, note)
+	a.sendWebmentions(a.logger.ContextLoggingFn(c), saved, markdown)
+}
+
+// writeAdminError responds to a failed admin request with the json error matching err.
+// Validation errors list the problem of every invalid field.
+func (a *appServer) writeAdminError(c *gin.Context, log logger.LoggingFn, err error) {
+	log(logger.ErrorLevel).Err(err).Msg("admin request failed")
+	var validationError *post.ValidationError
+	var keyError *post.KeyError
+	switch {
+	case errors.As(err, &validationError):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation failed", "fields": validationError.Fields})
+	case errors.As(err, &keyError) && errors.Is(keyError.Err, post.ErrKeyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": keyError.Error()})
+	case errors.As(err, &keyError):
+		c.JSON(http.StatusNotFound, gin.H{"error": keyError.Error()})
+	case errors.Is(err, post.ErrReadOnly):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": internalServerErrorMsg})
+	}
+}
@@ -2,11 +2,26 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/kegliz/silent-blog/internal/auth"
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/comment"
 	"github.com/kegliz/silent-blog/internal/config"
 	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/preview"
+	"github.com/kegliz/silent-blog/internal/project"
+	"github.com/kegliz/silent-blog/internal/render"
 	"github.com/kegliz/silent-blog/internal/server/logger"
 	"github.com/kegliz/silent-blog/internal/server/router"
+	"github.com/kegliz/silent-blog/internal/webmention"
+	"github.com/kegliz/silent-blog/ui"
+	"golang.org/x/text/language"
 
 	"github.com/kegliz/silent-blog/internal/server"
 )
@@ -15,20 +30,81 @@ type (
 	ServerOptions struct {
 		C       *config.Config
 		Version string
+		// Content is the content compiled into the binary, used with posts.storage: embed.
+		Content fs.FS
 	}
 
 	appServer struct {
 		logger   *logger.Logger
 		router   *router.Router
 		pService post.Service
+		projects project.Service
+		renderer *render.Renderer
+		pageSize int
+		// tocDepth is the depth of the tables of contents of the posts that do not set it, 0 for none
+		tocDepth int
 		version  string
+		// previewSigner is nil when previews are disabled
+		previewSigner *preview.Signer
+		dateFormat    ui.DateFormat
+		// site is the title and the authors of the site presented by the templates
+		site ui.Site
+		// content is where the markdown files of the posts are read from
+		content fs.FS
+		// adminToken is the bearer token of the admin API, empty if it is disabled
+		adminToken string
+		// sessions is nil when the admin login is disabled
+		sessions          *auth.Sessions
+		adminUser         string
+		adminPasswordHash string
+		// revisions keeps every version of the posts saved by the admin
+		revisions *post.Revisions
+		// comments is nil when the comments are disabled
+		comments     comment.Store
+		commentGuard *comment.Guard
+		// baseURL is the public URL of the site
+		baseURL string
+		// webmentions is nil when receiving webmentions is disabled, webmentionSender and sentWebmentions when sending them is
+		webmentions      *webmention.Receiver
+		webmentionSender *webmention.Sender
+		sentWebmentions  *webmention.SentLog
+		// reloaded signals that the posts were reloaded, publicationsMu serializes the checks of the published posts
+		reloaded       <-chan struct{}
+		publicationsMu sync.Mutex
+		// background tracks the work outliving its request, like sending webmentions, until stop is closed.
+		// backgroundMu guards closing, set by Shutdown so that no work is started while background is awaited.
+		background   sync.WaitGroup
+		backgroundMu sync.Mutex
+		closing      bool
+		stop         chan struct{}
 	}
 
 	appServerOptions struct {
-		logger   *logger.Logger
-		router   *router.Router
-		pService post.Service
-		version  string
+		logger        *logger.Logger
+		router        *router.Router
+		pService      post.Service
+		projects      project.Service
+		renderer      *render.Renderer
+		pageSize      int
+		tocDepth      int
+		version       string
+		previewSigner *preview.Signer
+		dateFormat    ui.DateFormat
+		site          ui.Site
+		content       fs.FS
+		adminToken    string
+
+		sessions          *auth.Sessions
+		adminUser         string
+		adminPasswordHash string
+		revisions         *post.Revisions
+		comments          comment.Store
+		commentGuard      *comment.Guard
+		baseURL           string
+		webmentions       *webmention.Receiver
+		webmentionSender  *webmention.Sender
+		sentWebmentions   *webmention.SentLog
+		reloaded          <-chan struct{}
 	}
 )
 
@@ -38,9 +114,36 @@ func newAppServer(options appServerOptions) *appServer {
 		logger:   options.logger,
 		router:   options.router,
 		pService: options.pService,
+		projects: options.projects,
+		renderer: options.renderer,
+		pageSize: options.pageSize,
+		tocDepth: options.tocDepth,
 		version:  options.version,
+
+		previewSigner: options.previewSigner,
+		dateFormat:    options.dateFormat,
+		site:          options.site,
+		content:       options.content,
+		adminToken:    options.adminToken,
+
+		sessions:          options.sessions,
+		adminUser:         options.adminUser,
+		adminPasswordHash: options.adminPasswordHash,
+		revisions:         options.revisions,
+		comments:          options.comments,
+		commentGuard:      options.commentGuard,
+		baseURL:           options.baseURL,
+		webmentions:       options.webmentions,
+		webmentionSender:  options.webmentionSender,
+		sentWebmentions:   options.sentWebmentions,
+		reloaded:          options.reloaded,
+		stop:              make(chan struct{}),
 	}
+	a.router.Use(a.withDateFormat, a.withSite, a.withLang)
 	a.router.SetRoutes(a.routes())
+	if a.webmentionSender != nil {
+		a.goBackground(a.watchPublications)
+	}
 	return a
 }
 
@@ -57,30 +160,292 @@ func (a *appServer) Listen(port int, localOnly bool, isTLS bool, domain string)
 }
 
 // Shutdown implements server.Server for graceful shutdown.
+// The requests are drained first, then the background work is stopped and awaited before the stores are closed.
 func (a *appServer) Shutdown(ctx context.Context) error {
-	return a.router.Shutdown(ctx)
+	err := a.router.Shutdown(ctx)
+	a.backgroundMu.Lock()
+	if !a.closing {
+		a.closing = true
+		close(a.stop)
+	}
+	a.backgroundMu.Unlock()
+	if a.webmentions != nil {
+		a.webmentions.Close()
+	}
+	a.background.Wait()
+	if a.comments != nil {
+		if err := a.comments.Close(); err != nil {
+			a.logger.Error().Err(err).Msg("closing comment store failed")
+		}
+	}
+	if err := a.pService.Close(); err != nil {
+		a.logger.Error().Err(err).Msg("closing post service failed")
+	}
+	return err
+}
+
+// goBackground runs fn in the background, tracked by background, unless the server is shutting down.
+// It reports whether fn was started.
+func (a *appServer) goBackground(fn func()) bool {
+	a.backgroundMu.Lock()
+	defer a.backgroundMu.Unlock()
+	if a.closing {
+		return false
+	}
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		fn()
+	}()
+	return true
 }
 
 // NewServer creates a new server.
 func NewServer(options ServerOptions) (server.Server, error) {
 	l, r := server.NewLoggerAndRouter(server.EngineOptions{
-		Debug: options.C.GetBool("debug"),
+		Debug:          options.C.GetBool("debug"),
+		TrustedProxies: options.C.GetStringSlice("trustedproxies"),
 	})
 	l.Debug().Msgf("Options: posts.file: %s, posts.mddir: %s", options.C.GetString("posts.file"), options.C.GetString("posts.mddir"))
-	p, err := post.NewService(post.ServiceOptions{
-		Logger:   l,
-		FileName: options.C.GetString("posts.file"),
-		MdDir:    options.C.GetString("posts.mddir"),
+	location, err := time.LoadLocation(options.C.GetString("site.timezone"))
+	if err != nil {
+		return nil, fmt.Errorf("NewServer: invalid site.timezone: %v", err)
+	}
+	siteLang := ui.DefaultLang
+	if lang := options.C.GetString("site.lang"); lang != "" {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, fmt.Errorf("NewServer: invalid site.lang: %v", err)
+		}
+		siteLang = tag.String()
+	}
+	reloaded := make(chan struct{}, 1)
+	onReload := func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}
+	p, storage, authors, err := newPostService(options, l, location, onReload)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := newProjectService(options, l, storage)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	rr := render.NewRenderer(render.RendererOptions{
+		MaxEntries: options.C.GetInt("render.cachesize"),
+		FS:         storage,
 	})
+	signer, err := preview.NewSigner([]byte(options.C.GetString("preview.key")))
 	if err != nil {
+		l.Warn().Err(err).Msg("previews are disabled")
+	}
+	sessions, err := newSessions(options.C)
+	if err != nil {
+		l.Warn().Err(err).Msg("admin login is disabled")
+	}
+	comments, guard, err := newComments(options.C)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	receiver, sender, sent, err := newWebmentions(options.C, l)
+	if err != nil {
+		p.Close()
+		if comments != nil {
+			comments.Close()
+		}
 		return nil, err
 	}
 	app := newAppServer(appServerOptions{
-		logger:   l,
-		router:   r,
-		pService: p,
-		version:  options.Version,
+		logger:        l,
+		router:        r,
+		pService:      p,
+		projects:      projects,
+		renderer:      rr,
+		pageSize:      options.C.GetInt("posts.pagesize"),
+		tocDepth:      options.C.GetInt("posts.tocdepth"),
+		version:       options.Version,
+		previewSigner: signer,
+		dateFormat: ui.DateFormat{
+			Layout:   options.C.GetString("site.dateformat"),
+			Relative: options.C.GetBool("site.relativedates"),
+			Location: location,
+		},
+		site: ui.Site{
+			Title:    options.C.GetString("site.title"),
+			Lang:     siteLang,
+			Authors:  authors,
+			Projects: options.C.GetString("projects.file") != "",
+		},
+		content:    storage,
+		adminToken: options.C.GetString("admin.token"),
+
+		sessions:          sessions,
+		adminUser:         options.C.GetString("admin.user"),
+		adminPasswordHash: options.C.GetString("admin.passwordhash"),
+		revisions:         post.NewRevisions(revisionsDir(options.C)),
+		comments:          comments,
+		commentGuard:      guard,
+		baseURL:           options.C.BaseURL(),
+		webmentions:       receiver,
+		webmentionSender:  sender,
+		sentWebmentions:   sent,
+		reloaded:          reloaded,
 	})
 
 	return app, nil
 }
+
+// newSessions returns the session manager of the admin login, which needs a password hash and a signing key.
+func newSessions(c *config.Config) (*auth.Sessions, error) {
+	if c.GetString("admin.passwordhash") == "" {
+		return nil, errors.New("no admin.passwordhash configured")
+	}
+	return auth.NewSessions(auth.SessionOptions{
+		Key:    []byte(c.GetString("admin.sessionkey")),
+		TTL:    c.GetDuration("admin.sessionttl"),
+		Secure: c.GetBool("tls"),
+	})
+}
+
+// newComments returns the comment store selected by comments.store and the guard of its forms, nil if comments.enabled is off.
+func newComments(c *config.Config) (comment.Store, *comment.Guard, error) {
+	if !c.GetBool("comments.enabled") {
+		return nil, nil, nil
+	}
+	guard, err := comment.NewGuard(comment.GuardOptions{
+		Key:      []byte(c.GetString("comments.key")),
+		MinDelay: c.GetDuration("comments.mindelay"),
+		MaxAge:   c.GetDuration("comments.maxage"),
+		Rate:     c.GetInt("comments.ratelimit"),
+		Window:   c.GetDuration("comments.ratewindow"),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("NewServer: cannot create comment guard: %v", err)
+	}
+	var store comment.Store
+	switch kind := c.GetString("comments.store"); kind {
+	case "file", "":
+		store, err = comment.NewFileStore(c.GetString("comments.file"))
+	case "sqlite":
+		store, err = comment.NewSQLiteStore(c.GetString("comments.database"))
+	default:
+		err = fmt.Errorf("unknown comments.store %q", kind)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("NewServer: %v", err)
+	}
+	return store, guard, nil
+}
+
+// newWebmentions returns the receiver of webmentions, nil if webmention.enabled is off,
+// and the sender with the log of the notified posts, nil if webmention.send is off.
+func newWebmentions(c *config.Config, l *logger.Logger) (*webmention.Receiver, *webmention.Sender, *webmention.SentLog, error) {
+	client := webmention.NewClient(c.GetBool("webmention.allowprivate"))
+	var sender *webmention.Sender
+	var sent *webmention.SentLog
+	if c.GetBool("webmention.send") {
+		var err error
+		if sent, err = webmention.NewSentLog(c.GetString("webmention.sentfile")); err != nil {
+			return nil, nil, nil, fmt.Errorf("NewServer: %v", err)
+		}
+		sender = webmention.NewSender(client, c.BaseURL())
+	}
+	if !c.GetBool("webmention.enabled") {
+		return nil, sender, sent, nil
+	}
+	store, err := webmention.NewFileStore(c.GetString("webmention.file"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("NewServer: %v", err)
+	}
+	return webmention.NewReceiver(webmention.ReceiverOptions{Logger: l, Store: store, Client: client}), sender, sent, nil
+}
+
+// revisionsDir returns the directory of the post revisions, by default next to the posts file or database.
+func revisionsDir(c *config.Config) string {
+	if dir := c.GetString("posts.revisions"); dir != "" {
+		return dir
+	}
+	file := c.GetString("posts.file")
+	if c.GetString("posts.backend") == "sqlite" {
+		file = c.GetString("posts.database")
+	}
+	return filepath.Join(filepath.Dir(file), "revisions")
+}
+
+// newProjectService returns the project service reading projects.file from the storage of the posts,
+// or from the local disk with the sqlite backend.
+func newProjectService(options ServerOptions, l *logger.Logger, storage post.Storage) (project.Service, error) {
+	if options.C.GetString("posts.backend") == "sqlite" {
+		storage = post.NewLocalStorage()
+	}
+	projects, err := project.NewService(project.ServiceOptions{
+		Logger:   l,
+		Storage:  storage,
+		FileName: options.C.GetString("projects.file"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NewServer: %v", err)
+	}
+	return projects, nil
+}
+
+// newPostService returns the post service selected by posts.backend, the storage its markdown files are read from
+// and the authors the posts are checked against. The authors file is read with the posts, from the local disk with the sqlite backend.
+// onReload is called whenever the watched posts are reloaded.
+func newPostService(options ServerOptions, l *logger.Logger, location *time.Location, onReload func()) (post.Service, post.Storage, *author.Directory, error) {
+	if options.C.GetString("posts.backend") == "sqlite" {
+		authors, err := author.Load(post.NewLocalStorage(), options.C.GetString("authors.file"))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("NewServer: %v", err)
+		}
+		db, err := post.OpenSQLite(options.C.GetString("posts.database"))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("NewServer: %v", err)
+		}
+		p, err := post.NewSQLiteService(post.SQLiteServiceOptions{
+			Logger:     l,
+			DB:         db,
+			ShowDrafts: options.C.GetBool("posts.showdrafts"),
+			Location:   location,
+			Authors:    authors,
+		})
+		if err != nil {
+			db.Close()
+			return nil, nil, nil, err
+		}
+		return p, post.NewSQLiteStorage(db), authors, nil
+	}
+
+	storage, err := post.NewStorage(post.StorageOptions{
+		Kind:     options.C.GetString("posts.storage"),
+		Embedded: options.Content,
+		Archive:  options.C.GetString("posts.archive"),
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("NewServer: %v", err)
+	}
+	authors, err := author.Load(storage, options.C.GetString("authors.file"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("NewServer: %v", err)
+	}
+	p, err := post.NewService(post.ServiceOptions{
+		Logger:     l,
+		Storage:    storage,
+		FileName:   options.C.GetString("posts.file"),
+		MdDir:      options.C.GetString("posts.mddir"),
+		Watch:      options.C.GetBool("posts.watch"),
+		ShowDrafts: options.C.GetBool("posts.showdrafts"),
+		Location:   location,
+		Authors:    authors,
+		OnReload:   onReload,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return p, storage, authors, nil
+}
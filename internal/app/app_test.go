@@ -6,9 +6,20 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/auth"
 	"github.com/kegliz/silent-blog/internal/config"
+	"github.com/kegliz/silent-blog/internal/post"
 	"github.com/kegliz/silent-blog/internal/server"
 	"github.com/kegliz/silent-blog/internal/server/router"
 	"github.com/stretchr/testify/suite"
@@ -27,6 +38,11 @@ func (s *AppServerTestSuite) SetupSuite() {
 
 	var yamlExample = []byte(`
 debug: true
+posts.mddir: testdata/posts
+posts.pagesize: 1
+preview.key: test-preview-key
+projects.file: testdata/projects.json
+site.dateformat: "Jan 2, 2006"
 `)
 
 	c.ReadConfig(bytes.NewBuffer(yamlExample))
@@ -54,6 +70,14 @@ func (s *AppServerTestSuite) doRequest(method string, urlStr string, body io.Rea
 	return rec
 }
 
+func (s *AppServerTestSuite) doHtmxRequest(method string, urlStr string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, urlStr, nil)
+	req.Header.Set("HX-Request", "true")
+	s.TestAppServer.(*appServer).router.ServeHTTP(rec, req)
+	return rec
+}
+
 // test Shutdown() method
 func (s *AppServerTestSuite) TestNoServerToShutdown() {
 	// No server started yet
@@ -76,6 +100,919 @@ func (s *AppServerTestSuite) TestHealthHandler() {
 	s.Contains(rec.Body.String(), "OK", "200 GET /health")
 }
 
+// test /health/render endpoint handler
+func (s *AppServerTestSuite) TestRenderStatsHandler() {
+	rec := s.doRequest(http.MethodGet, "/health/render", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /health/render")
+	s.Contains(rec.Body.String(), `"maxEntries":128`, "200 GET /health/render")
+}
+
+// test /post/:id endpoint handler
+func (s *AppServerTestSuite) TestPresentPost() {
+	rec := s.doRequest(http.MethodGet, "/post/first-post", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /post/first-post")
+	s.Contains(rec.Body.String(), "Hello from the first post.", "200 GET /post/first-post")
+	s.Contains(rec.Body.String(), `hx-get="/tags/htmx"`, "tags should link to the tag pages")
+	s.Contains(rec.Body.String(), `<time datetime="2024-01-01T00:00:00Z" title="Jan 1, 2024">Jan 1, 2024</time>`, "date should use the configured format")
+	s.Contains(rec.Body.String(), "1 min read · 7 words", "the header should show the reading time")
+
+	rec = s.doRequest(http.MethodGet, "/post/missing", nil, "")
+	s.Equal(http.StatusNotFound, rec.Code, "404 GET /post/missing")
+
+	rec = s.doRequest(http.MethodGet, "/post/draft-post", nil, "")
+	s.Equal(http.StatusNotFound, rec.Code, "404 GET /post/draft-post")
+}
+
+// test /posts endpoint handler with pagination
+func (s *AppServerTestSuite) TestPostsHandler() {
+	rec := s.doRequest(http.MethodGet, "/posts", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /posts")
+	body := rec.Body.String()
+	s.Contains(body, "Second post", "newest post should be on the first page")
+	s.NotContains(body, "First post", "older post should be on the next page")
+	s.Contains(body, `hx-get="/posts?cursor=`, "first page should load more")
+
+	rec = s.doHtmxRequest(http.MethodGet, "/posts?page=2")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /posts?page=2")
+	body = rec.Body.String()
+	s.Contains(body, "First post", "200 GET /posts?page=2")
+	s.Contains(body, "Hello from the first post.", "the list should show the excerpt")
+	s.Contains(body, "1 min read", "the list should show the reading time")
+	s.NotContains(body, `id="subcontent"`, "htmx next page should only contain the posts")
+	s.NotContains(body, "Load more", "last page should not load more")
+
+	rec = s.doRequest(http.MethodGet, "/posts?page=0", nil, "")
+	s.Equal(http.StatusBadRequest, rec.Code, "400 GET /posts?page=0")
+	rec = s.doRequest(http.MethodGet, "/posts?cursor=%25%25", nil, "")
+	s.Equal(http.StatusBadRequest, rec.Code, "400 GET /posts?cursor=%%")
+}
+
+// test /preview/:id endpoint handler
+func (s *AppServerTestSuite) TestPreviewHandler() {
+	signer := s.TestAppServer.(*appServer).previewSigner
+	s.Require().NotNil(signer)
+
+	rec := s.doRequest(http.MethodGet, signer.URL("", "draft-post", time.Hour), nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /preview/draft-post")
+	s.Contains(rec.Body.String(), "Not ready yet.", "200 GET /preview/draft-post")
+	s.Contains(rec.Body.String(), "Draft - this post is not published.", "preview should show the draft banner")
+	s.Equal("noindex", rec.Header().Get("X-Robots-Tag"))
+
+	rec = s.doRequest(http.MethodGet, "/preview/draft-post?token=forged", nil, "")
+	s.Equal(http.StatusForbidden, rec.Code, "403 GET /preview/draft-post with invalid token")
+	s.Contains(rec.Body.String(), "Invalid preview link")
+
+	rec = s.doRequest(http.MethodGet, signer.URL("", "draft-post", -time.Hour), nil, "")
+	s.Equal(http.StatusGone, rec.Code, "410 GET /preview/draft-post with expired token")
+	s.Contains(rec.Body.String(), "Preview link expired")
+
+	rec = s.doRequest(http.MethodGet, "/posts?page=1", nil, "")
+	s.NotContains(rec.Body.String(), "Draft post", "drafts should stay hidden from the post list")
+}
+
+// test /series/:name endpoint handler and the series box of the post page
+func (s *AppServerTestSuite) TestSeriesHandler() {
+	rec := s.doRequest(http.MethodGet, "/series/intro", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /series/intro")
+	body := rec.Body.String()
+	s.Less(strings.Index(body, "First post"), strings.Index(body, "Second post"), "parts should be in order")
+
+	rec = s.doRequest(http.MethodGet, "/series/unknown", nil, "")
+	s.Equal(http.StatusNotFound, rec.Code, "404 GET /series/unknown")
+
+	rec = s.doRequest(http.MethodGet, "/post/second-post", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /post/second-post")
+	body = rec.Body.String()
+	s.Contains(body, "Part of the series", "post page should show the series box")
+	s.Contains(body, `<span class="text-white font-bold">Second post</span>`, "current part should be highlighted")
+	s.Contains(body, "← First post", "previous part should be linked")
+	s.NotContains(body, "Second post →", "last part should have no next part")
+}
+
+// test the related posts and the chronological neighbours at the bottom of the post page
+func (s *AppServerTestSuite) TestPostNeighbours() {
+	rec := s.doRequest(http.MethodGet, "/post/first-post", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /post/first-post")
+	body := rec.Body.String()
+	s.Contains(body, "Related posts", "post sharing a tag should be related")
+	s.Contains(body, "Newer: Second post →", "newer post should be linked")
+	s.NotContains(body, "← Older", "oldest post should have no older post")
+	s.NotContains(body, "Draft post", "drafts should not be linked")
+}
+
+// test serving the posts from a SQLite database
+func (s *AppServerTestSuite) TestSQLiteBackend() {
+	database := filepath.Join(s.T().TempDir(), "posts.db")
+	db, err := post.OpenSQLite(database)
+	s.Require().NoError(err)
+	_, _, err = post.ImportSQLite(db, post.TransferOptions{MdDir: "testdata/posts"})
+	s.Require().NoError(err)
+	s.Require().NoError(db.Close())
+
+	c := config.NewNakedConfig()
+	c.SetConfigType("yaml")
+	s.Require().NoError(c.ReadConfig(strings.NewReader("posts.backend: sqlite\nposts.database: " + database + "\n")))
+	srv, err := NewServer(ServerOptions{C: c, Version: "test"})
+	s.Require().NoError(err)
+	defer srv.Shutdown(context.Background())
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/post/first-post", nil)
+	srv.(*appServer).router.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /post/first-post")
+	s.Contains(rec.Body.String(), "Hello from the first post.", "markdown should be rendered from the database")
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/tags/go", nil)
+	srv.(*appServer).router.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /tags/go")
+	s.Contains(rec.Body.String(), "Second post")
+}
+
+// test the admin write API on a copy of the test posts
+func (s *AppServerTestSuite) TestAdminAPI() {
+	dir := s.T().TempDir()
+	jsonFile := filepath.Join(dir, "posts.json")
+	s.Require().NoError(os.WriteFile(jsonFile, []byte("[]"), 0644))
+	c := config.NewNakedConfig()
+	c.SetConfigType("yaml")
+	s.Require().NoError(c.ReadConfig(strings.NewReader(
+		"posts.file: " + jsonFile + "\nposts.mddir: " + filepath.Join(dir, "md") + "\nposts.watch: false\nadmin.token: test-admin-token\n")))
+	srv, err := NewServer(ServerOptions{C: c, Version: "test"})
+	s.Require().NoError(err)
+	defer srv.Shutdown(context.Background())
+	do := func(method string, urlStr string, token string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, urlStr, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		srv.(*appServer).router.ServeHTTP(rec, req)
+		return rec
+	}
+	newPost := `{"id": "api-post", "title": "API post", "tags": ["go"], "date": "2024-03-01", "markdown": "Hello from the API."}`
+
+	rec := do(http.MethodPost, "/admin/api/posts", "", newPost)
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 POST /admin/api/posts without token")
+	rec = do(http.MethodPost, "/admin/api/posts", "wrong-token", newPost)
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 POST /admin/api/posts with wrong token")
+
+	rec = do(http.MethodPost, "/admin/api/posts", "test-admin-token", newPost)
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	s.Equal("/admin/api/posts/api-post", rec.Header().Get("Location"))
+	s.Contains(rec.Body.String(), `"date":"2024-03-01"`)
+	rec = do(http.MethodGet, "/post/api-post", "", "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /post/api-post")
+	s.Contains(rec.Body.String(), "Hello from the API.")
+
+	rec = do(http.MethodPost, "/admin/api/posts", "test-admin-token", newPost)
+	s.Equal(http.StatusConflict, rec.Code, "409 POST /admin/api/posts with a taken ID")
+	rec = do(http.MethodPost, "/admin/api/posts", "test-admin-token", `{"id": "no-title", "date": "tomorrow"}`)
+	s.Equal(http.StatusUnprocessableEntity, rec.Code, "422 POST /admin/api/posts with invalid fields")
+	s.JSONEq(`{"error": "validation failed", "fields": {"title": "is required", "date": "invalid date \"tomorrow\""}}`, rec.Body.String())
+	rec = do(http.MethodPost, "/admin/api/posts", "test-admin-token", `{"id": `)
+	s.Equal(http.StatusBadRequest, rec.Code, "400 POST /admin/api/posts with invalid json")
+
+	rec = do(http.MethodPut, "/admin/api/posts/api-post", "test-admin-token", `{"title": "Draft now", "date": "2024-03-01", "draft": true, "markdown": "Changed."}`)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodGet, "/post/api-post", "", "")
+	s.Equal(http.StatusNotFound, rec.Code, "drafts should be hidden after the update")
+	rec = do(http.MethodGet, "/admin/api/posts/api-post", "test-admin-token", "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/api/posts/api-post")
+	s.Contains(rec.Body.String(), `"markdown":"Changed."`)
+	rec = do(http.MethodPut, "/admin/api/posts/missing", "test-admin-token", `{"title": "Missing", "date": "2024-03-01"}`)
+	s.Equal(http.StatusNotFound, rec.Code, "404 PUT /admin/api/posts/missing")
+
+	rec = do(http.MethodDelete, "/admin/api/posts/api-post", "", "")
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 DELETE /admin/api/posts/api-post without token")
+	rec = do(http.MethodDelete, "/admin/api/posts/api-post", "test-admin-token", "")
+	s.Equal(http.StatusNoContent, rec.Code, "204 DELETE /admin/api/posts/api-post")
+	rec = do(http.MethodGet, "/admin/api/posts/api-post", "test-admin-token", "")
+	s.Equal(http.StatusNotFound, rec.Code, "404 GET /admin/api/posts/api-post after delete")
+
+	rec = s.doRequest(http.MethodPost, "/admin/api/posts", strings.NewReader(newPost), "application/json")
+	s.Equal(http.StatusUnauthorized, rec.Code, "the admin API should be disabled without admin.token")
+}
+
+// test logging in and out of the admin and guarding routes with the session
+func (s *AppServerTestSuite) TestAdminLogin() {
+	hash, err := auth.HashPassword("test-password")
+	s.Require().NoError(err)
+	c := config.NewNakedConfig()
+	c.SetConfigType("yaml")
+	s.Require().NoError(c.ReadConfig(strings.NewReader(
+		"posts.mddir: testdata/posts\nposts.watch: false\nadmin.passwordhash: " + hash + "\nadmin.sessionkey: test-session-key\nadmin.sessionttl: 1h\nadmin.user: admin\n")))
+	srv, err := NewServer(ServerOptions{C: c, Version: "test"})
+	s.Require().NoError(err)
+	defer srv.Shutdown(context.Background())
+	var cookies []*http.Cookie
+	do := func(method string, urlStr string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, urlStr, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		srv.(*appServer).router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/admin", nil, false)
+	s.Equal(http.StatusSeeOther, rec.Code, "303 GET /admin without session")
+	s.Equal("/admin/login?next=%2Fadmin", rec.Header().Get("Location"))
+	rec = do(http.MethodGet, "/admin", nil, true)
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 htmx GET /admin without session")
+	s.Equal("/admin/login?next=%2Fadmin", rec.Header().Get("HX-Redirect"))
+	rec = do(http.MethodGet, "/admin/api/posts/first-post", nil, false)
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 GET /admin/api/posts/first-post without session")
+
+	rec = do(http.MethodGet, "/admin/login?next=/admin", nil, false)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/login")
+	s.Contains(rec.Body.String(), `action="/admin/login"`)
+
+	rec = do(http.MethodPost, "/admin/login", url.Values{"user": {"admin"}, "password": {"wrong"}}, false)
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 POST /admin/login with a wrong password")
+	s.Contains(rec.Body.String(), "Wrong user or password.")
+	s.Empty(rec.Result().Cookies())
+
+	rec = do(http.MethodPost, "/admin/login", url.Values{"user": {"admin"}, "password": {"test-password"}, "next": {"//evil.example"}}, false)
+	s.Equal(http.StatusSeeOther, rec.Code, "303 POST /admin/login")
+	s.Equal("/admin", rec.Header().Get("Location"), "the login should only redirect on this site")
+	cookies = rec.Result().Cookies()
+	s.Require().Len(cookies, 1)
+	s.Equal(auth.CookieName, cookies[0].Name)
+
+	rec = do(http.MethodGet, "/admin", nil, false)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin with session")
+	s.Contains(rec.Body.String(), "Logged in as admin.")
+	rec = do(http.MethodGet, "/admin/api/posts/first-post", nil, false)
+	s.Equal(http.StatusOK, rec.Code, "the session should authorize the admin API")
+	rec = do(http.MethodGet, "/admin/login?next=/admin/logout", nil, false)
+	s.Equal(http.StatusSeeOther, rec.Code, "a logged in user should skip the login page")
+	s.Equal("/admin/logout", rec.Header().Get("Location"))
+
+	rec = do(http.MethodPost, "/admin/logout", nil, false)
+	s.Equal(http.StatusOK, rec.Code, "200 POST /admin/logout")
+	s.Contains(rec.Body.String(), "You have been logged out.")
+	s.Require().Len(rec.Result().Cookies(), 1)
+	s.True(rec.Result().Cookies()[0].MaxAge < 0, "the session cookie should be removed")
+}
+
+// newWritableServer returns a server over an empty writable content directory with the admin login enabled.
+// The extra yaml lines are added to its configuration.
+func (s *AppServerTestSuite) newWritableServer(extra ...string) *appServer {
+	return s.newWritableServerWithFiles(nil, extra...)
+}
+
+// newWritableServerWithFiles is newWritableServer with the given markdown files in the md directory when it starts.
+func (s *AppServerTestSuite) newWritableServerWithFiles(files map[string]string, extra ...string) *appServer {
+	dir := s.T().TempDir()
+	jsonFile := filepath.Join(dir, "posts.json")
+	s.Require().NoError(os.WriteFile(jsonFile, []byte("[]"), 0644))
+	if len(files) > 0 {
+		s.Require().NoError(os.MkdirAll(filepath.Join(dir, "md"), 0755))
+	}
+	for name, content := range files {
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, "md", name), []byte(content), 0644))
+	}
+	hash, err := auth.HashPassword("test-password")
+	s.Require().NoError(err)
+	c := config.NewNakedConfig()
+	c.SetConfigType("yaml")
+	s.Require().NoError(c.ReadConfig(strings.NewReader("posts.file: " + jsonFile + "\nposts.mddir: " + filepath.Join(dir, "md") +
+		"\nposts.watch: false\nadmin.user: admin\nadmin.passwordhash: " + hash + "\nadmin.sessionkey: test-session-key\nadmin.sessionttl: 1h\n" +
+		strings.ReplaceAll(strings.Join(extra, "\n"), "$DIR", dir))))
+	srv, err := NewServer(ServerOptions{C: c, Version: "test"})
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { srv.Shutdown(context.Background()) })
+	return srv.(*appServer)
+}
+
+// login logs in to srv and returns the session cookie.
+func (s *AppServerTestSuite) login(srv *appServer) *http.Cookie {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/login", strings.NewReader("user=admin&password=test-password"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	srv.router.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusSeeOther, rec.Code, "login failed")
+	return rec.Result().Cookies()[0]
+}
+
+// doFormRequest sends a form as an htmx request to srv with the session cookie.
+func (s *AppServerTestSuite) doFormRequest(srv *appServer, session *http.Cookie, method string, urlStr string, form url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, urlStr, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	if session != nil {
+		req.AddCookie(session)
+	}
+	srv.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// test editing posts in the admin editor
+func (s *AppServerTestSuite) TestEditor() {
+	srv := s.newWritableServer()
+	rec := s.doFormRequest(srv, nil, http.MethodGet, "/admin/editor", nil)
+	s.Equal(http.StatusUnauthorized, rec.Code, "the editor should require a login")
+	session := s.login(srv)
+
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/editor", nil)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/editor")
+	s.Contains(rec.Body.String(), "New post")
+	s.Contains(rec.Body.String(), `hx-post="/admin/editor/preview"`)
+
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor/preview", url.Values{"markdown": {"# Preview\n\n*live*"}})
+	s.Equal(http.StatusOK, rec.Code, "200 POST /admin/editor/preview")
+	s.Contains(rec.Body.String(), "<em>live</em>")
+	s.NotContains(rec.Body.String(), "<html", "the preview should be a fragment")
+
+	form := url.Values{"id": {"edited"}, "title": {""}, "date": {"next week"}, "tags": {"go, htmx"}, "markdown": {"Edited *body*."}}
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Equal(http.StatusOK, rec.Code, "invalid posts should be shown again")
+	s.Contains(rec.Body.String(), "is required", "the title error should be shown inline")
+	s.Contains(rec.Body.String(), `invalid date &#34;next week&#34;`, "the date error should be shown inline")
+	s.Contains(rec.Body.String(), `value="go, htmx"`, "the input should be kept")
+	s.Contains(rec.Body.String(), "<em>body</em>", "the preview should be rendered")
+	_, err := srv.pService.GetUnpublishedPost(srv.logger.ContextLoggingFn(&gin.Context{}), "edited")
+	s.Error(err, "an invalid post should not be saved")
+
+	form.Set("title", "Edited post")
+	form.Set("date", "2024-03-01")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Equal(http.StatusOK, rec.Code, "200 POST /admin/editor")
+	s.Contains(rec.Body.String(), "Saved.")
+	s.Equal("/admin/editor/edited", rec.Header().Get("HX-Push-Url"))
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/edited", nil)
+	s.Equal(http.StatusOK, rec.Code, "the saved post should be published")
+	s.Contains(rec.Body.String(), "<em>body</em>")
+
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/editor/edited", nil)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/editor/edited")
+	s.Contains(rec.Body.String(), `name="original" value="edited"`)
+	s.Contains(rec.Body.String(), "Edited *body*.")
+
+	form.Set("original", "edited")
+	form.Set("draft", "true")
+	form.Set("seriesName", "tour")
+	form.Set("seriesPart", "two")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Contains(rec.Body.String(), "part must be a number")
+	form.Set("seriesPart", "2")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Contains(rec.Body.String(), "Saved.")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/edited", nil)
+	s.Equal(http.StatusNotFound, rec.Code, "the post should be a draft after the update")
+
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/editor/missing", nil)
+	s.Equal(http.StatusNotFound, rec.Code, "404 GET /admin/editor/missing")
+}
+
+// test the revision history of the posts saved in the editor
+func (s *AppServerTestSuite) TestRevisions() {
+	srv := s.newWritableServer()
+	session := s.login(srv)
+	rec := s.doFormRequest(srv, session, http.MethodGet, "/admin/revisions/history", nil)
+	s.Equal(http.StatusNotFound, rec.Code, "a post without revisions should have no history")
+
+	form := url.Values{"id": {"history"}, "title": {"History"}, "date": {"2024-03-01"}, "tags": {"go"}, "markdown": {"first line\nsecond line\n"}}
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Contains(rec.Body.String(), "Saved.")
+	form.Set("original", "history")
+	form.Set("markdown", "first line\nchanged line\n")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Contains(rec.Body.String(), "Saved.")
+	s.Contains(rec.Body.String(), `href="/admin/revisions/history"`, "the editor should link the history")
+
+	revisions, err := srv.revisions.List("history")
+	s.Require().NoError(err)
+	s.Require().Len(revisions, 2)
+	newest, oldest := revisions[0], revisions[1]
+	s.Equal("admin", newest.Author)
+
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/revisions/history", nil)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/revisions/history")
+	s.Contains(rec.Body.String(), newest.ID)
+	s.Contains(rec.Body.String(), oldest.ID)
+
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/revisions/history/diff?from="+oldest.ID+"&to="+newest.ID, nil)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/revisions/history/diff")
+	s.Contains(rec.Body.String(), "second line")
+	s.Contains(rec.Body.String(), "changed line")
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/revisions/history/diff?from=missing&to="+newest.ID, nil)
+	s.Equal(http.StatusNotFound, rec.Code, "404 for a missing revision")
+
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/revisions/history/"+oldest.ID+"/restore", nil)
+	s.Equal(http.StatusOK, rec.Code, "200 POST restore")
+	s.Contains(rec.Body.String(), "The revision has been restored.")
+	revisions, err = srv.revisions.List("history")
+	s.Require().NoError(err)
+	s.Require().Len(revisions, 3, "the restore should be a new revision")
+	s.Equal(oldest.Post.Markdown, revisions[0].Post.Markdown)
+	s.Contains(revisions[0].Note, "Restored")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/history", nil)
+	s.Contains(rec.Body.String(), "second line", "the post should be restored")
+
+	s.Require().NoError(srv.pService.DeletePost(srv.logger.ContextLoggingFn(&gin.Context{}), "history"))
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/revisions/history/"+newest.ID+"/restore", nil)
+	s.Contains(rec.Body.String(), "The revision has been restored.")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/history", nil)
+	s.Contains(rec.Body.String(), "changed line", "a deleted post should be restored")
+}
+
+// test that the version of a post from before its first edit is kept and can be restored
+func (s *AppServerTestSuite) TestRevisionBaseline() {
+	srv := s.newWritableServerWithFiles(map[string]string{
+		"fixture.md": "---\ntitle: Fixture\ndate: 2024-01-01\n---\noriginal text\n",
+		"doomed.md":  "---\ntitle: Doomed\ndate: 2024-01-02\n---\ndoomed text\n",
+	})
+	session := s.login(srv)
+
+	form := url.Values{"original": {"fixture"}, "id": {"fixture"}, "title": {"Fixture"}, "date": {"2024-01-01"}, "markdown": {"edited text\n"}}
+	rec := s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Contains(rec.Body.String(), "Saved.")
+	revisions, err := srv.revisions.List("fixture")
+	s.Require().NoError(err)
+	s.Require().Len(revisions, 2, "the original should be kept before the first edit")
+	original := revisions[1]
+	s.Equal("original", original.Note)
+	s.Contains(original.Post.Markdown, "original text")
+
+	form.Set("markdown", "edited again\n")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", form)
+	s.Contains(rec.Body.String(), "Saved.")
+	revisions, err = srv.revisions.List("fixture")
+	s.Require().NoError(err)
+	s.Len(revisions, 3, "the original should only be kept once")
+
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/revisions/fixture/"+original.ID+"/restore", nil)
+	s.Contains(rec.Body.String(), "The revision has been restored.")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/fixture", nil)
+	s.Contains(rec.Body.String(), "original text", "the original should be restored")
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/admin/api/posts/doomed", nil)
+	req.AddCookie(session)
+	srv.router.ServeHTTP(rec, req)
+	s.Equal(http.StatusNoContent, rec.Code)
+	revisions, err = srv.revisions.List("doomed")
+	s.Require().NoError(err, "a deleted post should be kept")
+	s.Require().Len(revisions, 1)
+	s.Contains(revisions[0].Post.Markdown, "doomed text")
+}
+
+// test commenting on a post and moderating the comments
+func (s *AppServerTestSuite) TestComments() {
+	rec := s.doRequest(http.MethodPost, "/post/first-post/comments", strings.NewReader("author=reader&body=hi"), "application/x-www-form-urlencoded")
+	s.Equal(http.StatusNotFound, rec.Code, "the comments should be disabled without comments.enabled")
+
+	srv := s.newWritableServer("comments.enabled: true", "comments.file: $DIR/comments.json", "comments.ratelimit: 2", "comments.ratewindow: 1h")
+	session := s.login(srv)
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", url.Values{"id": {"talk"}, "title": {"Talk"}, "date": {"2024-03-01"}, "tags": {"go"}, "markdown": {"Comment on me."}})
+	s.Contains(rec.Body.String(), "Saved.")
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/talk", nil)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /post/talk")
+	s.Contains(rec.Body.String(), `hx-post="/post/talk/comments"`)
+	s.Contains(rec.Body.String(), "0 comments")
+	token := regexp.MustCompile(`name="token" value="([^"]+)"`).FindStringSubmatch(rec.Body.String())
+	s.Require().Len(token, 2, "the form should carry a token")
+	comment := url.Values{"token": {token[1]}, "author": {"Reader"}, "body": {"<script>alert(1)</script> *nice*"}}
+	// newComment is the comment sent from a form shown again
+	newComment := func() url.Values {
+		rec := s.doFormRequest(srv, nil, http.MethodGet, "/post/talk", nil)
+		token := regexp.MustCompile(`name="token" value="([^"]+)"`).FindStringSubmatch(rec.Body.String())
+		s.Require().Len(token, 2, "the form should carry a token")
+		return url.Values{"token": {token[1]}, "author": {"Reader"}, "body": {"again"}}
+	}
+
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", url.Values{"token": {token[1]}, "author": {"Bot"}, "body": {"spam"}, "website": {"http://spam.example"}})
+	s.Contains(rec.Body.String(), "will be shown once approved", "a bot should not notice the honeypot")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", url.Values{"token": {token[1]}, "body": {"anonymous"}})
+	s.Contains(rec.Body.String(), "is required")
+	s.Contains(rec.Body.String(), "anonymous", "the input should be kept")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", url.Values{"token": {"forged"}, "author": {"Reader"}, "body": {"hi"}})
+	s.Contains(rec.Body.String(), "The form has expired")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/missing/comments", comment)
+	s.Equal(http.StatusNotFound, rec.Code, "404 POST /post/missing/comments")
+
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", comment)
+	s.Equal(http.StatusOK, rec.Code, "200 POST /post/talk/comments")
+	s.Contains(rec.Body.String(), "will be shown once approved")
+	s.NotContains(rec.Body.String(), "<html", "the form should be a fragment")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", comment)
+	s.Contains(rec.Body.String(), "This form has been sent already", "a form should only be accepted once")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/talk", nil)
+	s.NotContains(rec.Body.String(), "nice", "the comment should wait for moderation")
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/admin/comments", nil)
+	s.Equal(http.StatusUnauthorized, rec.Code, "the queue should require a login")
+	rec = s.doFormRequest(srv, session, http.MethodGet, "/admin/comments", nil)
+	s.Equal(http.StatusOK, rec.Code, "200 GET /admin/comments")
+	s.Contains(rec.Body.String(), "&lt;script&gt;alert(1)&lt;/script&gt; <em>nice</em>")
+	pending, err := srv.comments.Pending()
+	s.Require().NoError(err)
+	s.Require().Len(pending, 1, "the honeypot comment should not be kept")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/comments/"+pending[0].ID+"/approve", nil)
+	s.Contains(rec.Body.String(), "The comment has been approved.")
+	s.Contains(rec.Body.String(), "There are no comments to moderate.")
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/comments/missing/delete", nil)
+	s.Contains(rec.Body.String(), "The comment does not exist anymore.")
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/talk", nil)
+	s.Contains(rec.Body.String(), "1 comment")
+	s.Contains(rec.Body.String(), "<em>nice</em>")
+	s.NotContains(rec.Body.String(), "<script>alert(1)</script>")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/posts", nil)
+	s.Contains(rec.Body.String(), "1 comment", "the post list should show the comment count")
+
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", newComment())
+	s.Contains(rec.Body.String(), "will be shown once approved")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/post/talk/comments", newComment())
+	s.Contains(rec.Body.String(), "You have sent many comments lately", "the rate limit should stop the third comment")
+	spoofed := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/post/talk/comments", strings.NewReader(newComment().Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("X-Forwarded-For", "203.0.113.99")
+	srv.router.ServeHTTP(spoofed, req)
+	s.Contains(spoofed.Body.String(), "You have sent many comments lately", "a forwarded address should not lift the rate limit")
+
+	// without htmx the post page is presented with the form
+	plain := func(form url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/post/talk/comments", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "192.0.2.50:1234"
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+	form := newComment()
+	form.Set("author", "")
+	form.Set("body", "typed without htmx")
+	rec = plain(form)
+	s.Equal(http.StatusUnprocessableEntity, rec.Code, "a rejected comment should be answered with 422")
+	s.Contains(rec.Body.String(), "<html", "the whole post page should be presented")
+	s.Contains(rec.Body.String(), "is required")
+	s.Contains(rec.Body.String(), "typed without htmx", "the input should be kept")
+	form.Set("author", "Reader")
+	rec = plain(form)
+	s.Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Body.String(), ">Talk</div>")
+	s.Contains(rec.Body.String(), "will be shown once approved")
+
+	// the form speaks the language of its post
+	_, err = srv.pService.CreatePost(srv.logger.ContextLoggingFn(&gin.Context{}), post.PostInput{ID: "beszelgetes", Title: "Beszélgetés", Date: "2024-03-01", Lang: "hu"})
+	s.Require().NoError(err)
+	hungarian := func(method string, target string, form url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept-Language", "hu")
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+	rec = hungarian(http.MethodGet, "/post/beszelgetes", nil)
+	s.Contains(rec.Body.String(), "0 hozzászólás")
+	s.Contains(rec.Body.String(), ">Küldés</button>")
+	s.NotContains(rec.Body.String(), "Comments are shown once approved")
+	rec = hungarian(http.MethodPost, "/post/beszelgetes/comments", url.Values{"token": {"forged"}, "author": {"Olvasó"}, "body": {"szia"}})
+	s.Contains(rec.Body.String(), "Az űrlap lejárt")
+	rec = hungarian(http.MethodPost, "/post/talk/comments", url.Values{"token": {"forged"}, "author": {"Reader"}, "body": {"hi"}})
+	s.Contains(rec.Body.String(), "The form has expired", "an English post should keep an English form")
+	rec = hungarian(http.MethodPost, "/post/missing/comments", url.Values{"token": {"forged"}, "author": {"Olvasó"}, "body": {"szia"}})
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Contains(rec.Body.String(), "A bejegyzés nem található")
+}
+
+// test sending webmentions on save and receiving them at /webmention
+func (s *AppServerTestSuite) TestWebmentions() {
+	rec := s.doRequest(http.MethodPost, "/webmention", strings.NewReader("source=http://other.example/&target=http://localhost/post/first-post"), "application/x-www-form-urlencoded")
+	s.Equal(http.StatusNotFound, rec.Code, "the webmentions should be disabled without webmention.enabled")
+
+	var mu sync.Mutex
+	var received url.Values
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Link", `</endpoint>; rel="webmention"`)
+			io.WriteString(w, "<p>An article</p>")
+		case "/endpoint":
+			r.ParseForm()
+			mu.Lock()
+			received = r.PostForm
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		case "/reply":
+			io.WriteString(w, `<div class="h-entry"><span class="p-author">Ann Other</span>
+				<a class="u-in-reply-to" href="http://blog.test/post/talk">re</a><p class="e-content">Well said.</p></div>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+
+	srv := s.newWritableServer("site.url: http://blog.test/", "webmention.enabled: true", "webmention.send: true",
+		"webmention.allowprivate: true", "webmention.file: $DIR/webmentions.json", "webmention.sentfile: $DIR/sent.json")
+	session := s.login(srv)
+	rec = s.doFormRequest(srv, session, http.MethodPost, "/admin/editor", url.Values{"id": {"talk"}, "title": {"Talk"}, "date": {"2024-03-01"},
+		"markdown": {"Answering [an article](" + remote.URL + "/article) and [myself](/post/other)."}})
+	s.Contains(rec.Body.String(), "Saved.")
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received.Get("source") == "http://blog.test/post/talk" && received.Get("target") == remote.URL+"/article"
+	}, 5*time.Second, 10*time.Millisecond, "the linked article should be notified")
+
+	// scheduled posts are notified once they are published
+	srv.checkPublications()
+	log := srv.logger.ContextLoggingFn(&gin.Context{})
+	publishAt := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	_, err := srv.pService.CreatePost(log, post.PostInput{ID: "later", Title: "Later", Date: "2024-03-02", PublishAt: publishAt.Format(time.RFC3339),
+		Markdown: "Back to [the article](" + remote.URL + "/article)."})
+	s.Require().NoError(err)
+	srv.checkPublications()
+	s.False(srv.sentWebmentions.Sent("later"), "a scheduled post should not be notified before its publication")
+	time.Sleep(time.Until(publishAt) + 10*time.Millisecond)
+	srv.checkPublications()
+	s.True(srv.sentWebmentions.Sent("later"), "a published post should be notified")
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received.Get("source") == "http://blog.test/post/later"
+	}, 5*time.Second, 10*time.Millisecond, "the article linked from the scheduled post should be notified")
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/talk", nil)
+	s.Equal(`<http://blog.test/webmention>; rel="webmention"`, rec.Header().Get("Link"), "the post should announce the endpoint")
+
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {remote.URL + "/reply"}, "target": {"http://elsewhere.test/post/talk"}})
+	s.Equal(http.StatusBadRequest, rec.Code, "400 POST /webmention of another site")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {remote.URL + "/reply"}, "target": {"http://blog.test/post/missing"}})
+	s.Equal(http.StatusBadRequest, rec.Code, "400 POST /webmention of a missing post")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {remote.URL + "/reply"}, "target": {"http://blog.test/no-such-lang!/post/talk"}})
+	s.Equal(http.StatusBadRequest, rec.Code, "400 POST /webmention of a path that is not a post")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {remote.URL + "/mention"}, "target": {"http://blog.test/hu/post/talk"}})
+	s.Equal(http.StatusAccepted, rec.Code, "202 POST /webmention of a translated post URL")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {"javascript:alert(1)"}, "target": {"http://blog.test/post/talk"}})
+	s.Equal(http.StatusBadRequest, rec.Code, "400 POST /webmention of an invalid source")
+	rec = s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {remote.URL + "/reply"}, "target": {"http://blog.test/post/talk"}})
+	s.Equal(http.StatusAccepted, rec.Code, "202 POST /webmention")
+	s.Eventually(func() bool {
+		rec := s.doFormRequest(srv, nil, http.MethodGet, "/post/talk", nil)
+		return strings.Contains(rec.Body.String(), "Well said.") && strings.Contains(rec.Body.String(), "Ann Other")
+	}, 5*time.Second, 10*time.Millisecond, "the verified reply should be shown")
+}
+
+// test that webmentions sent while the server shuts down are refused instead of breaking the shutdown
+func (s *AppServerTestSuite) TestWebmentionsDuringShutdown() {
+	srv := s.newWritableServer("site.url: http://blog.test/", "webmention.enabled: true", "webmention.send: true",
+		"webmention.file: $DIR/webmentions.json", "webmention.sentfile: $DIR/sent.json")
+	log := srv.logger.ContextLoggingFn(&gin.Context{})
+	talk, err := srv.pService.CreatePost(log, post.PostInput{ID: "talk", Title: "Talk", Date: "2024-03-01", Markdown: "See [this](http://192.0.2.1/)."})
+	s.Require().NoError(err)
+
+	done := make(chan struct{})
+	codes := make(chan int, 1000)
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			rec := s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {"http://other.test/" + strconv.Itoa(i)}, "target": {"http://blog.test/post/talk"}})
+			codes <- rec.Code
+			srv.sendWebmentions(log, talk, "See [this](http://192.0.2.1/).")
+		}
+	}()
+	time.Sleep(time.Millisecond)
+	srv.Shutdown(context.Background())
+	<-done
+	close(codes)
+	for code := range codes {
+		s.Contains([]int{http.StatusAccepted, http.StatusServiceUnavailable}, code)
+	}
+	rec := s.doFormRequest(srv, nil, http.MethodPost, "/webmention", url.Values{"source": {"http://other.test/late"}, "target": {"http://blog.test/post/talk"}})
+	s.Equal(http.StatusServiceUnavailable, rec.Code, "a webmention after the shutdown should be refused")
+}
+
+// test the table of contents of the posts and its metadata
+func (s *AppServerTestSuite) TestTableOfContents() {
+	rec := s.doRequest(http.MethodGet, "/post/first-post", nil, "")
+	s.Contains(rec.Body.String(), `<h1 id="first-post">First post<a class="heading-anchor" href="#first-post"`, "headings should have anchors")
+	s.NotContains(rec.Body.String(), "Table of contents", "a post without sections should have no table of contents")
+
+	srv := s.newWritableServer("posts.tocdepth: 1")
+	log := srv.logger.ContextLoggingFn(&gin.Context{})
+	markdown := "# Long\n\n## Setup\n\n### Details\n\n## Usage\n"
+	_, err := srv.pService.CreatePost(log, post.PostInput{ID: "long", Title: "Long", Date: "2024-03-01", Markdown: markdown})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "deep", Title: "Deep", Date: "2024-03-02", Markdown: markdown, Extra: map[string]interface{}{"tocDepth": 2}})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "plain", Title: "Plain", Date: "2024-03-03", Markdown: markdown, Extra: map[string]interface{}{"toc": false}})
+	s.Require().NoError(err)
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/long", nil)
+	s.Contains(rec.Body.String(), `<nav aria-label="Table of contents">`)
+	s.Contains(rec.Body.String(), `<a href="#usage" class="hover:text-white">Usage</a>`)
+	s.NotContains(rec.Body.String(), `href="#details" class="hover:text-white"`, "the depth should come from posts.tocdepth")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/deep", nil)
+	s.Contains(rec.Body.String(), `<a href="#details" class="hover:text-white">Details</a>`, "the depth should come from the post")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/plain", nil)
+	s.NotContains(rec.Body.String(), "Table of contents", "the post should opt out")
+	s.Contains(rec.Body.String(), `<h2 id="usage">`)
+}
+
+// test author bylines and pages
+func (s *AppServerTestSuite) TestAuthors() {
+	rec := s.doRequest(http.MethodGet, "/", nil, "")
+	s.Contains(rec.Body.String(), `href="/">Silent Blog</a>`, "the header should show the site title")
+	s.NotContains(rec.Body.String(), `hx-get="/authors"`, "a site without authors should not link to them")
+
+	authorsFile, err := filepath.Abs("testdata/authors.json")
+	s.Require().NoError(err)
+	srv := s.newWritableServer("authors.file: "+authorsFile, "site.title: Team Blog")
+	log := srv.logger.ContextLoggingFn(&gin.Context{})
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "together", Title: "Together", Date: "2024-03-01", Authors: []string{"ann", "bob"}})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "alone", Title: "Alone", Date: "2024-03-02", Authors: []string{"bob"}})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "stranger", Title: "Stranger", Date: "2024-03-03", Authors: []string{"eve"}})
+	var validationError *post.ValidationError
+	s.Require().ErrorAs(err, &validationError)
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/post/together", nil)
+	s.Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Body.String(), `by <a href="/authors/ann" class="hover:text-white underline" hx-get="/authors/ann"`)
+	s.Contains(rec.Body.String(), `>Ann Other</a> , <a href="/authors/bob"`, "the byline should keep the order of the post")
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/posts", nil)
+	s.Contains(rec.Body.String(), `>Bob</a>`, "the post list should have bylines")
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/authors/ann", nil)
+	srv.router.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	s.Contains(body, `href="/">Team Blog</a>`)
+	s.Contains(body, `hx-get="/authors"`, "the header should link to the authors")
+	s.Contains(body, "Ann writes about <em>Go</em> and htmx.")
+	s.Contains(body, `<a href="https://github.com/ann" class="underline hover:text-white" rel="me">GitHub</a>`)
+	s.Contains(body, `<img src="/static/ann.png" alt="Ann Other"`)
+	s.Contains(body, ">Together</a>")
+	s.NotContains(body, ">Alone</a>", "only the posts of the author should be listed")
+
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/authors", nil)
+	s.Contains(rec.Body.String(), `>Ann Other</a>`)
+	s.Contains(rec.Body.String(), `>Bob</a>`)
+	rec = s.doFormRequest(srv, nil, http.MethodGet, "/authors/eve", nil)
+	s.Equal(http.StatusNotFound, rec.Code)
+}
+
+// test the translations of a post and the language of the pages
+func (s *AppServerTestSuite) TestTranslations() {
+	srv := s.newWritableServer("site.url: https://blog.example")
+	log := srv.logger.ContextLoggingFn(&gin.Context{})
+	_, err := srv.pService.CreatePost(log, post.PostInput{ID: "boxes", Title: "Boxes", Date: "2024-03-01", TranslationKey: "boxes"})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "dobozok", Title: "Dobozok", Date: "2024-03-01", Lang: "hu", TranslationKey: "boxes"})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "plain", Title: "Plain", Date: "2024-03-02"})
+	s.Require().NoError(err)
+	_, err = srv.pService.CreatePost(log, post.PostInput{ID: "wrong", Title: "Wrong", Date: "2024-03-01", Lang: "not a language"})
+	var validationError *post.ValidationError
+	s.Require().ErrorAs(err, &validationError)
+
+	get := func(url string, acceptLanguage string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/post/boxes", "")
+	s.Equal(http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	s.Contains(body, `<html lang="en">`)
+	s.Contains(body, ">Boxes</div>")
+	s.Contains(body, `<link rel="alternate" hreflang="en" href="https://blog.example/en/post/boxes">`)
+	s.Contains(body, `<link rel="alternate" hreflang="hu" href="https://blog.example/hu/post/dobozok">`)
+	s.Contains(body, `<link rel="alternate" hreflang="x-default" href="https://blog.example/post/boxes">`)
+	s.Contains(body, `<a href="/hu/post/dobozok" hreflang="hu" lang="hu"`, "the post should link its translations")
+	s.Equal("en", rec.Header().Get("Content-Language"))
+	s.Contains(rec.Header().Values("Vary"), "Accept-Language")
+
+	rec = get("/post/boxes", "hu-HU,hu;q=0.9,en;q=0.8")
+	s.Equal(http.StatusOK, rec.Code)
+	body = rec.Body.String()
+	s.Contains(body, `<html lang="hu">`, "the translation preferred by the reader should be presented")
+	s.Contains(body, ">Dobozok</div>")
+	s.Contains(body, ">Bejegyzések</a>", "the navigation should be in the language of the page")
+	s.Equal("hu", rec.Header().Get("Content-Language"))
+
+	rec = get("/en/post/dobozok", "hu")
+	s.Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Body.String(), ">Boxes</div>", "the language prefix should win over the header")
+	rec = get("/hu/post/dobozok", "")
+	s.Contains(rec.Body.String(), ">Dobozok</div>")
+
+	rec = get("/de/post/boxes", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Contains(rec.Body.String(), "This post has no translation in de.")
+
+	rec = get("/post/plain", "hu")
+	s.Equal(http.StatusOK, rec.Code)
+	body = rec.Body.String()
+	s.Contains(body, `<html lang="en">`, "a post without a language is in the language of the site")
+	s.NotContains(body, `hreflang=`, "a post without translations has no alternates")
+
+	rec = get("/posts", "hu")
+	s.Contains(rec.Body.String(), `<html lang="hu">`, "the other pages should speak the language of the reader")
+	rec = get("/tags/nothing", "hu")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Contains(rec.Body.String(), "Nincs #nothing címkéjű bejegyzés.")
+}
+
+// test /projects and /projects/:id endpoint handlers
+func (s *AppServerTestSuite) TestProjects() {
+	rec := s.doRequest(http.MethodGet, "/projects", nil, "")
+	s.Equal(http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	s.Contains(body, `hx-get="/projects" hx-target="#subcontent" hx-swap="outerHTML" hx-push-url="/projects">Projects</a>`, "the header should link to the projects")
+	s.Contains(body, `hx-get="/projects/htmx-kit"`)
+	s.Contains(body, "Components for htmx pages.")
+	s.Contains(body, ">experimental</span>")
+	s.Contains(body, ">Stars</a>")
+
+	rec = s.doHtmxRequest(http.MethodGet, "/projects/htmx-kit")
+	s.Equal(http.StatusOK, rec.Code)
+	body = rec.Body.String()
+	s.NotContains(body, "<html", "htmx navigation should only swap the sub content")
+	s.Contains(body, `<a href="https://github.com/example/htmx-kit" class="underline hover:text-white">Source code</a>`)
+	s.Contains(body, "The kit is written in <em>Go</em>.", "the detail file should be rendered")
+	s.Contains(body, ">First post</a>", "posts sharing a tag should be linked")
+	s.NotContains(body, ">Second post</a>")
+
+	rec = s.doHtmxRequest(http.MethodGet, "/projects/stars")
+	s.Equal(http.StatusOK, rec.Code)
+	s.NotContains(rec.Body.String(), "Posts on the same topics", "a project without posts should not list them")
+
+	rec = s.doHtmxRequest(http.MethodGet, "/post/first-post")
+	s.Contains(rec.Body.String(), "Related projects")
+	s.Contains(rec.Body.String(), `hx-get="/projects/htmx-kit"`, "posts should link the projects sharing a tag")
+	rec = s.doHtmxRequest(http.MethodGet, "/post/second-post")
+	s.NotContains(rec.Body.String(), "Related projects")
+
+	rec = s.doHtmxRequest(http.MethodGet, "/projects/unknown")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Contains(rec.Body.String(), "There is no project called unknown.")
+}
+
+// test /search endpoint handler
+func (s *AppServerTestSuite) TestSearchHandler() {
+	rec := s.doHtmxRequest(http.MethodGet, "/search?q=hello+tag:htmx")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /search")
+	body := rec.Body.String()
+	s.Contains(body, "1 results", "200 GET /search")
+	s.Contains(body, "First post", "200 GET /search")
+	s.Contains(body, "<mark", "matches should be highlighted")
+	s.NotContains(body, "<html", "htmx search should only swap the sub content")
+
+	rec = s.doRequest(http.MethodGet, "/search", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /search without query")
+}
+
+// test /tags endpoint handler
+func (s *AppServerTestSuite) TestTagsHandler() {
+	rec := s.doRequest(http.MethodGet, "/tags", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /tags")
+	body := rec.Body.String()
+	s.Contains(body, "#go (2)", "200 GET /tags")
+	s.Less(strings.Index(body, "#go (2)"), strings.Index(body, "#htmx (1)"), "tags should be ordered by count")
+
+	rec = s.doRequest(http.MethodGet, "/tags?sort=name", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /tags?sort=name")
+
+	rec = s.doRequest(http.MethodGet, "/tags?sort=color", nil, "")
+	s.Equal(http.StatusBadRequest, rec.Code, "400 GET /tags?sort=color")
+}
+
+// test /tags/:tag endpoint handler
+func (s *AppServerTestSuite) TestTagHandler() {
+	rec := s.doRequest(http.MethodGet, "/tags/htmx", nil, "")
+	s.Equal(http.StatusOK, rec.Code, "200 GET /tags/htmx")
+	s.Contains(rec.Body.String(), "First post", "200 GET /tags/htmx")
+	s.NotContains(rec.Body.String(), "Second post", "200 GET /tags/htmx")
+
+	rec = s.doRequest(http.MethodGet, "/tags/unknown", nil, "")
+	s.Equal(http.StatusNotFound, rec.Code, "404 GET /tags/unknown")
+	s.Contains(rec.Body.String(), "Not found", "404 GET /tags/unknown")
+}
+
 func TestAppTestSuite(t *testing.T) {
 	suite.Run(t, new(AppServerTestSuite))
 }
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/ui"
+)
+
+// AuthorsHandler is the handler for the /authors endpoint
+func (a *appServer) AuthorsHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AuthorsHandler: serving authors endpoint")
+	err := presentSubContent(c, ui.AuthorList(a.site.Authors.All()))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering authors failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// AuthorHandler is the handler for the /authors/:id endpoint
+func (a *appServer) AuthorHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("AuthorHandler: serving authors/id endpoint")
+	id := c.Param("id")
+	author, ok := a.site.Authors.Get(id)
+	if !ok {
+		log(logger.ErrorLevel).Msgf("AuthorHandler: unknown author %s", id)
+		a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.author", id))
+		return
+	}
+	bio, err := render.Convert([]byte(author.Bio))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("converting bio of %s failed", id)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	posts, err := a.pService.GetPostsByAuthor(log, id)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting posts by author failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	view := ui.AuthorView{Author: author, BioHTML: bio, Posts: posts, CommentCounts: a.commentCounts(log)}
+	err = presentSubContent(c, ui.AuthorPage(view))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("rendering authors/%s failed", id)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
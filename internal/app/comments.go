@@ -0,0 +1,169 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/comment"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/ui"
+)
+
+// CommentHandler is the handler for POST /post/:id/comments
+// A new comment waits in the moderation queue, the form is shown again with the outcome:
+// alone to htmx, or else on the post page, with 422 if the comment was rejected.
+func (a *appServer) CommentHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("CommentHandler: serving post/id/comments endpoint")
+	id := c.Param("id")
+	commented, err := a.pService.GetPost(log, id)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting post failed")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.post"))
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	// the form speaks the language of the post it is shown under
+	lang := commented.Lang
+	if lang == "" {
+		lang = a.site.Lang
+	}
+	c.Request = c.Request.WithContext(ui.WithLang(c.Request.Context(), lang))
+
+	form := a.newCommentForm(id)
+	form.Author, form.Body = c.PostForm("author"), c.PostForm("body")
+	newComment, err := comment.Validate(comment.Comment{PostID: id, Author: form.Author, Body: form.Body, Time: time.Now().UTC(), Status: comment.StatusPending})
+	if err == nil {
+		err = a.commentGuard.Check(comment.Submission{PostID: id, IP: c.ClientIP(), Token: c.PostForm("token"), Honeypot: c.PostForm("website")})
+	}
+	var validationError *comment.ValidationError
+	switch {
+	case err == nil:
+		saved, err := a.comments.Add(newComment)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Msg("adding comment failed")
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+			return
+		}
+		log(logger.InfoLevel).Str("id", saved.ID).Str("post", id).Msg("comment awaits moderation")
+		form = a.newCommentForm(id)
+		form.Message = ui.T(c.Request.Context(), "comment.awaitsModeration")
+	case errors.As(err, &validationError):
+		form.Errors = validationError.Fields
+	case errors.Is(err, comment.ErrHoneypot):
+		// a bot should not learn that it was caught
+		form = a.newCommentForm(id)
+		form.Message = ui.T(c.Request.Context(), "comment.awaitsModeration")
+	case errors.Is(err, comment.ErrTooFast):
+		form.Message = ui.T(c.Request.Context(), "comment.tooFast")
+	case errors.Is(err, comment.ErrRateLimited):
+		form.Message = ui.T(c.Request.Context(), "comment.rateLimited")
+	case errors.Is(err, comment.ErrReusedForm):
+		form.Message = ui.T(c.Request.Context(), "comment.reusedForm")
+	default:
+		form.Message = ui.T(c.Request.Context(), "comment.expiredForm")
+	}
+	if err != nil {
+		log(logger.WarnLevel).Err(err).Str("ip", c.ClientIP()).Msgf("comment on post/%s rejected", id)
+	}
+
+	if c.GetHeader("HX-Request") != "true" {
+		status := http.StatusOK
+		if err != nil && !errors.Is(err, comment.ErrHoneypot) {
+			status = http.StatusUnprocessableEntity
+		}
+		a.presentPostWithStatus(c, status, commented, nil, &form)
+		return
+	}
+	if err := presentFragment(c, ui.CommentForm(form)); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering comment form failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// CommentQueueHandler is the handler for GET /admin/comments
+func (a *appServer) CommentQueueHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("CommentQueueHandler: serving comment queue page")
+	a.presentCommentQueue(c, "")
+}
+
+// CommentApproveHandler is the handler for POST /admin/comments/:id/approve
+func (a *appServer) CommentApproveHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("CommentApproveHandler: serving comment approve endpoint")
+	a.moderateComment(c, a.comments.Approve, "The comment has been approved.")
+}
+
+// CommentDeleteHandler is the handler for POST /admin/comments/:id/delete
+func (a *appServer) CommentDeleteHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("CommentDeleteHandler: serving comment delete endpoint")
+	a.moderateComment(c, a.comments.Delete, "The comment has been deleted.")
+}
+
+// moderateComment applies a moderation action to the comment of the request and shows the queue again.
+func (a *appServer) moderateComment(c *gin.Context, action func(id string) error, message string) {
+	log := a.logger.ContextLoggingFn(c)
+	id := c.Param("id")
+	if err := action(id); err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("moderating comment %s failed", id)
+		if !errors.Is(err, comment.ErrNotFound) {
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+			return
+		}
+		message = "The comment does not exist anymore."
+	} else {
+		log(logger.InfoLevel).Str("id", id).Str("user", c.GetString(adminUserKey)).Msg(message)
+	}
+	a.presentCommentQueue(c, message)
+}
+
+// presentCommentQueue presents the comments waiting for moderation with a message.
+func (a *appServer) presentCommentQueue(c *gin.Context, message string) {
+	log := a.logger.ContextLoggingFn(c)
+	pending, err := a.comments.Pending()
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("listing pending comments failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	if err := presentSubContent(c, ui.CommentQueue(ui.CommentQueueView{Pending: pending, Message: message})); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering comment queue failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// requireComments is a middleware answering 404 when the comments are disabled.
+func (a *appServer) requireComments(c *gin.Context) {
+	if a.comments == nil {
+		a.presentNotFound(c, ui.T(c.Request.Context(), "comment.disabled"))
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// newCommentForm returns an empty comment form of a post.
+func (a *appServer) newCommentForm(postID string) ui.CommentFormView {
+	return ui.CommentFormView{PostID: postID, Token: a.commentGuard.Token(postID)}
+}
+
+// commentCounts returns the number of approved comments by post ID, empty when the comments are disabled.
+func (a *appServer) commentCounts(log logger.LoggingFn) map[string]int {
+	if a.comments == nil {
+		return nil
+	}
+	counts, err := a.comments.Counts()
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("counting comments failed")
+	}
+	return counts
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/ui"
+)
+
+// EditorHandler is the handler for GET /admin/editor and /admin/editor/:id
+// Without an ID the editor starts a new post.
+func (a *appServer) EditorHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("EditorHandler: serving editor page")
+	view := ui.EditorView{}
+	if id := c.Param("id"); id != "" {
+		p, err := a.pService.GetUnpublishedPost(log, id)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Msg("getting post failed")
+			var keyError *post.KeyError
+			if errors.As(err, &keyError) {
+				a.presentNotFound(c, "Post not found")
+				return
+			}
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+			return
+		}
+		markdown, err := a.readMarkdown(p)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Msgf("reading markdown of post/%s failed", id)
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+			return
+		}
+		view.Original = id
+		view.Input = post.NewPostInput(p, markdown, a.dateFormat.Location)
+	}
+	a.presentEditor(c, view)
+}
+
+// EditorSaveHandler is the handler for POST /admin/editor
+// It creates or updates the post and shows the editor again with the validation errors or the saved post.
+func (a *appServer) EditorSaveHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("EditorSaveHandler: serving editor save endpoint")
+	view := ui.EditorView{Original: c.PostForm("original"), Input: editorInput(c)}
+	if part := strings.TrimSpace(c.PostForm("seriesPart")); part != "" {
+		n, err := strconv.Atoi(part)
+		if err != nil || view.Input.Series == nil {
+			view.Errors = map[string]string{"series": "part must be a number of a named series"}
+			a.presentEditor(c, view)
+			return
+		}
+		view.Input.Series.Part = n
+	}
+
+	var saved post.Post
+	var err error
+	if view.Original == "" {
+		saved, err = a.pService.CreatePost(log, view.Input)
+	} else {
+		// the editor has no fields for the summary and the extra metadata, they are kept as they are
+		if current, err := a.pService.GetUnpublishedPost(log, view.Original); err == nil {
+			view.Input.Content, view.Input.Extra = current.Content, current.Extra
+		}
+		a.recordBaseline(c, log, view.Original)
+		saved, err = a.pService.UpdatePost(log, view.Original, view.Input)
+	}
+	var validationError *post.ValidationError
+	var keyError *post.KeyError
+	switch {
+	case err == nil:
+		log(logger.InfoLevel).Str("id", saved.ID).Msg("post saved")
+		a.postSaved(c, saved, view.Input.Markdown, "")
+		view.Original, view.Message = saved.ID, "Saved."
+		view.Input.ID = saved.ID
+		c.Header("HX-Push-Url", "/admin/editor/"+saved.ID)
+	case errors.As(err, &validationError):
+		view.Errors = validationError.Fields
+	case errors.As(err, &keyError) && errors.Is(keyError.Err, post.ErrKeyExists):
+		view.Errors = map[string]string{"id": "is taken by another post"}
+	case errors.As(err, &keyError):
+		view.Message = "The post does not exist anymore."
+	case errors.Is(err, post.ErrReadOnly):
+		view.Message = err.Error()
+	default:
+		log(logger.ErrorLevel).Err(err).Msg("saving post failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	if err != nil {
+		log(logger.WarnLevel).Err(err).Msg("post not saved")
+	}
+	a.presentEditor(c, view)
+}
+
+// EditorPreviewHandler is the handler for POST /admin/editor/preview
+// It renders the markdown of the editor with the same pipeline as the posts.
+func (a *appServer) EditorPreviewHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("EditorPreviewHandler: serving editor preview endpoint")
+	html, err := render.Convert([]byte(c.PostForm("markdown")))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering preview failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	if err := presentFragment(c, ui.EditorPreview(html)); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering preview failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// presentEditor presents the editor with the preview of its markdown.
+func (a *appServer) presentEditor(c *gin.Context, view ui.EditorView) {
+	log := a.logger.ContextLoggingFn(c)
+	html, err := render.Convert([]byte(view.Input.Markdown))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering preview failed")
+	}
+	view.Preview = html
+	if err := presentSubContent(c, ui.Editor(view)); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering editor failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// readMarkdown returns the markdown body of a post, empty if it has no markdown file.
+func (a *appServer) readMarkdown(p post.Post) (string, error) {
+	if p.FileName == "" {
+		return "", nil
+	}
+	markdown, err := fs.ReadFile(a.content, p.FileName)
+	return string(markdown), err
+}
+
+// editorInput returns the post submitted by the editor form, tags and authors are separated by commas.
+func editorInput(c *gin.Context) post.PostInput {
+	in := post.PostInput{
+		ID:        c.PostForm("id"),
+		Title:     c.PostForm("title"),
+		Date:      c.PostForm("date"),
+		PublishAt: c.PostForm("publishAt"),
+		Draft:     c.PostForm("draft") == "true",
+		Markdown:  strings.ReplaceAll(c.PostForm("markdown"), "\r\n", "\n"),
+
+		Lang:           c.PostForm("lang"),
+		TranslationKey: c.PostForm("translationKey"),
+	}
+	for _, tag := range strings.Split(c.PostForm("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			in.Tags = append(in.Tags, tag)
+		}
+	}
+	for _, id := range strings.Split(c.PostForm("authors"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			in.Authors = append(in.Authors, id)
+		}
+	}
+	if name := strings.TrimSpace(c.PostForm("seriesName")); name != "" {
+		in.Series = &post.Series{Name: name}
+	}
+	return in
+}
@@ -3,10 +3,15 @@ package app
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/gin-gonic/gin"
 	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/preview"
+	"github.com/kegliz/silent-blog/internal/render"
 	"github.com/kegliz/silent-blog/internal/server/logger"
 	"github.com/kegliz/silent-blog/ui"
 )
@@ -17,6 +22,9 @@ var internalServerErrorMsg = "Internal Server Error - please contact the adminis
 const (
 	webTitleDev  = "Silent Secret DEV"
 	webTitleProd = "Silent Secret"
+
+	// searchLimit is the maximum number of search results shown
+	searchLimit = 50
 )
 
 var webTitle string = webTitleDev
@@ -46,6 +54,13 @@ func (a *appServer) HealthHandler(c *gin.Context) {
 	c.String(http.StatusOK, "OK")
 }
 
+// RenderStatsHandler is the handler for the /health/render endpoint
+func (a *appServer) RenderStatsHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("RenderStatsHandler: serving render stats endpoint")
+	c.JSON(http.StatusOK, a.renderer.Stats())
+}
+
 // AboutHandler is the handler for the /about endpoint
 func (a *appServer) AboutHandler(c *gin.Context) {
 	log := a.logger.ContextLoggingFn(c)
@@ -59,16 +74,32 @@ func (a *appServer) AboutHandler(c *gin.Context) {
 }
 
 // PostsHandler is the handler for the /posts endpoint
+// htmx requests for a following page (?page= or ?cursor=) only get the posts to append.
 func (a *appServer) PostsHandler(c *gin.Context) {
 	log := a.logger.ContextLoggingFn(c)
 	log(logger.DebugLevel).Msg("PostHandler: serving post endpoint")
-	posts, err := a.pService.GetPosts(log)
+	req, ok := a.pageRequest(c)
+	if !ok {
+		log(logger.ErrorLevel).Msgf("PostsHandler: invalid page %s", c.Query("page"))
+		c.String(http.StatusBadRequest, badRequestErrorMsg)
+		return
+	}
+	page, err := a.pService.GetPostsPage(log, req)
 	if err != nil {
 		log(logger.ErrorLevel).Err(err).Msg("getting posts failed")
+		if errors.Is(err, post.ErrInvalidPage) {
+			c.String(http.StatusBadRequest, badRequestErrorMsg)
+			return
+		}
 		c.String(http.StatusInternalServerError, internalServerErrorMsg)
 		return
 	}
-	err = presentSubContent(c, ui.PostList(posts))
+	view := ui.PostListView{Page: page, BaseURL: "/posts", CommentCounts: a.commentCounts(log)}
+	if isNextPageRequest(c, req) {
+		err = presentFragment(c, ui.PostListPage(view))
+	} else {
+		err = presentSubContent(c, ui.PostList(view))
+	}
 	if err != nil {
 		log(logger.ErrorLevel).Err(err).Msg("rendering posts failed")
 		c.String(http.StatusInternalServerError, internalServerErrorMsg)
@@ -76,7 +107,117 @@ func (a *appServer) PostsHandler(c *gin.Context) {
 	}
 }
 
-// PresentPost is the handler for the /post/:id endpoint
+// TagsHandler is the handler for the /tags endpoint
+func (a *appServer) TagsHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("TagsHandler: serving tags endpoint")
+	order := post.TagOrder(c.DefaultQuery("sort", string(post.TagOrderCount)))
+	if order != post.TagOrderCount && order != post.TagOrderName {
+		log(logger.ErrorLevel).Msgf("TagsHandler: unknown sort order %s", order)
+		c.String(http.StatusBadRequest, badRequestErrorMsg)
+		return
+	}
+	tags, err := a.pService.GetTags(log, order)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting tags failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	err = presentSubContent(c, ui.TagList(tags, order))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering tags failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// TagHandler is the handler for the /tags/:tag endpoint
+func (a *appServer) TagHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("TagHandler: serving tags/tag endpoint")
+	tag := c.Param("tag")
+	req, ok := a.pageRequest(c)
+	if !ok {
+		log(logger.ErrorLevel).Msgf("TagHandler: invalid page %s", c.Query("page"))
+		c.String(http.StatusBadRequest, badRequestErrorMsg)
+		return
+	}
+	page, err := a.pService.GetPostsByTagPage(log, tag, req)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting posts by tag failed")
+		var keyError *post.KeyError
+		switch {
+		case errors.As(err, &keyError):
+			a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.tag", tag))
+		case errors.Is(err, post.ErrInvalidPage):
+			c.String(http.StatusBadRequest, badRequestErrorMsg)
+		default:
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		}
+		return
+	}
+	view := ui.PostListView{Page: page, BaseURL: ui.TagURL(tag), CommentCounts: a.commentCounts(log)}
+	if isNextPageRequest(c, req) {
+		err = presentFragment(c, ui.PostListPage(view))
+	} else {
+		err = presentSubContent(c, ui.TaggedPostList(tag, view))
+	}
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("rendering tags/%s failed", tag)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// SeriesHandler is the handler for the /series/:name endpoint
+func (a *appServer) SeriesHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("SeriesHandler: serving series/name endpoint")
+	name := c.Param("name")
+	posts, err := a.pService.GetSeries(log, name)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting series failed")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.series", name))
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	err = presentSubContent(c, ui.SeriesPage(name, posts))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("rendering series/%s failed", name)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// SearchHandler is the handler for the /search endpoint
+func (a *appServer) SearchHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("SearchHandler: serving search endpoint")
+	query := strings.TrimSpace(c.Query("q"))
+	var results []post.SearchResult
+	if query != "" {
+		var err error
+		results, err = a.pService.Search(log, query, searchLimit)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Msg("searching posts failed")
+			c.String(http.StatusInternalServerError, internalServerErrorMsg)
+			return
+		}
+	}
+	err := presentSubContent(c, ui.SearchResults(query, results))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering search results failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// PresentPost is the handler for the /post/:id and /:lang/post/:id endpoints
+// It presents the translation of the post in the language of the prefix, or else the one the reader prefers.
 func (a *appServer) PresentPost(c *gin.Context) {
 	log := a.logger.ContextLoggingFn(c)
 	log(logger.DebugLevel).Msg("PresentPost: serving post/id endpoint")
@@ -88,32 +229,148 @@ func (a *appServer) PresentPost(c *gin.Context) {
 	}
 	log(logger.DebugLevel).Msgf("PresentPost: extracted id %s", id)
 
-	postToPresent, err := a.pService.GetPost(log, id)
+	translations, err := a.pService.GetTranslations(log, id)
 	if err != nil {
 		log(logger.ErrorLevel).Err(err).Msg("getting post failed")
 		var keyError *post.KeyError
 		if errors.As(err, &keyError) {
-			c.String(http.StatusNotFound, "Post not found")
+			a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.post"))
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	for i := range translations {
+		if translations[i].Lang == "" {
+			translations[i].Lang = a.site.Lang
+		}
+	}
+
+	lang := c.Param("lang")
+	postToPresent, ok := pickTranslation(translations, id, lang, c.GetHeader("Accept-Language"))
+	if !ok {
+		log(logger.DebugLevel).Msgf("PresentPost: post/%s has no translation in %s", id, lang)
+		a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.translation", ui.LanguageName(lang)))
+		return
+	}
+	a.presentPost(c, postToPresent, translations)
+}
+
+// PreviewHandler is the handler for the /preview/:id endpoint
+// It presents unpublished posts to anyone holding a valid signed token.
+func (a *appServer) PreviewHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("PreviewHandler: serving preview/id endpoint")
+	id := c.Param("id")
+	if a.previewSigner == nil {
+		log(logger.WarnLevel).Msg("PreviewHandler: previews are disabled, no preview.key configured")
+		a.presentNotFound(c, ui.T(c.Request.Context(), "preview.disabled"))
+		return
+	}
+	c.Header("X-Robots-Tag", "noindex")
+
+	if err := a.previewSigner.Verify(id, c.Query("token")); err != nil {
+		log(logger.WarnLevel).Err(err).Msgf("PreviewHandler: rejected token for preview/%s", id)
+		if errors.Is(err, preview.ErrExpiredToken) {
+			a.presentError(c, http.StatusGone, ui.T(c.Request.Context(), "preview.expired.title"), ui.T(c.Request.Context(), "preview.expired"))
+			return
+		}
+		a.presentError(c, http.StatusForbidden, ui.T(c.Request.Context(), "preview.invalid.title"), ui.T(c.Request.Context(), "preview.invalid"))
+		return
+	}
+
+	postToPresent, err := a.pService.GetUnpublishedPost(log, id)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting post for preview failed")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.post"))
 			return
 		}
 		c.String(http.StatusInternalServerError, internalServerErrorMsg)
 		return
 	}
+	a.presentPost(c, postToPresent, nil)
+}
+
+// presentPost renders the markdown of a post and presents it in its language,
+// linking its translations if it is one of several
+func (a *appServer) presentPost(c *gin.Context, postToPresent post.Post, translations []post.Post) {
+	a.presentPostWithStatus(c, http.StatusOK, postToPresent, translations, nil)
+}
 
-	var content string
+// presentPostWithStatus is presentPost with a given status code and comment form, a new form if it is nil
+func (a *appServer) presentPostWithStatus(c *gin.Context, status int, postToPresent post.Post, translations []post.Post, form *ui.CommentFormView) {
+	log := a.logger.ContextLoggingFn(c)
+	id := postToPresent.ID
+	if postToPresent.Lang == "" {
+		postToPresent.Lang = a.site.Lang
+	}
+	ctx := ui.WithLang(c.Request.Context(), postToPresent.Lang)
+	c.Header("Content-Language", postToPresent.Lang)
+	var doc render.Document
+	var err error
 	if postToPresent.FileName != "" {
 		log(logger.DebugLevel).Msgf("PresentPost: converting md file to html for post/%s from the file %s", id, postToPresent.FileName)
-		content, err = ui.ConvertMdFileToHTML(postToPresent.FileName)
+		doc, err = a.renderer.RenderDocument(log, postToPresent.FileName)
 		if err != nil {
 			log(logger.ErrorLevel).Err(err).Msgf("converting md file to html failed for post/%s", id)
 			c.String(http.StatusInternalServerError, internalServerErrorMsg)
 			return
 		}
 	} else {
-		content = postToPresent.Content
+		doc.HTML = postToPresent.Content
 	}
 
-	err = presentSubContent(c, ui.Post(postToPresent, content))
+	view := ui.PostView{
+		Post:        postToPresent,
+		HTMLContent: doc.HTML,
+		TOC:         render.NewTOC(doc.Headings, postToPresent.TOCDepth(a.tocDepth)),
+	}
+	if len(translations) > 1 {
+		ctx = ui.WithAlternates(ctx, a.postAlternates(translations, postToPresent))
+		for _, t := range translations {
+			if t.ID != id {
+				view.Translations = append(view.Translations, t)
+			}
+		}
+	}
+	c.Request = c.Request.WithContext(ctx)
+	if postToPresent.Series != nil {
+		view.Series, err = a.pService.GetSeries(log, postToPresent.Series.Name)
+		if err != nil {
+			// an unpublished post can be the only part of its series
+			log(logger.DebugLevel).Err(err).Msgf("no published series for post/%s", id)
+		}
+	}
+	view.Neighbours, err = a.pService.GetNeighbours(log, id)
+	if err != nil {
+		// the post may have been removed by a reload since it was fetched
+		log(logger.DebugLevel).Err(err).Msgf("no neighbours for post/%s", id)
+	}
+	view.Projects, err = a.projects.GetProjectsByTags(log, postToPresent.Tags)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("getting projects of post/%s failed", id)
+	}
+	if a.webmentions != nil && postToPresent.IsPublished(time.Now()) {
+		c.Header("Link", "<"+a.baseURL+"/webmention>; rel=\"webmention\"")
+		view.Mentions, err = a.webmentions.Mentions(id)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Msgf("getting webmentions of post/%s failed", id)
+		}
+	}
+	if a.comments != nil && postToPresent.IsPublished(time.Now()) {
+		view.Comments = &ui.CommentsView{Form: a.newCommentForm(id)}
+		if form != nil {
+			view.Comments.Form = *form
+		}
+		view.Comments.Comments, err = a.comments.Approved(id)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Msgf("getting comments of post/%s failed", id)
+		}
+	}
+
+	err = presentSubContentWithStatus(c, status, ui.Post(view))
 	if err != nil {
 		log(logger.ErrorLevel).Err(err).Msgf("rendering post/%s failed", id)
 		c.String(http.StatusInternalServerError, internalServerErrorMsg)
@@ -121,10 +378,67 @@ func (a *appServer) PresentPost(c *gin.Context) {
 	}
 }
 
+// pageRequest builds the page request from the page and cursor query parameters
+func (a *appServer) pageRequest(c *gin.Context) (post.PageRequest, bool) {
+	req := post.PageRequest{
+		Size:   a.pageSize,
+		Cursor: c.Query("cursor"),
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return req, false
+	}
+	req.Page = page
+	return req, true
+}
+
+// isNextPageRequest reports whether an htmx request asks for a page to append to a list
+func isNextPageRequest(c *gin.Context, req post.PageRequest) bool {
+	return c.GetHeader("HX-Request") == "true" && (req.Cursor != "" || req.Page > 1)
+}
+
+// presentFragment is a helper function to present a fragment of the sub content to htmx
+func presentFragment(c *gin.Context, fragment templ.Component) error {
+	c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	return fragment.Render(c.Request.Context(), c.Writer)
+}
+
+// presentNotFound presents the 404 page with a message
+func (a *appServer) presentNotFound(c *gin.Context, message string) {
+	if err := presentSubContentWithStatus(c, http.StatusNotFound, ui.NotFound(message)); err != nil {
+		a.logger.Errorc(c).Err(err).Msg("rendering not found failed")
+	}
+}
+
+// presentError presents an error page with a status code, a title and a message
+func (a *appServer) presentError(c *gin.Context, status int, title string, message string) {
+	if err := presentSubContentWithStatus(c, status, ui.ErrorPage(title, message)); err != nil {
+		a.logger.Errorc(c).Err(err).Msg("rendering error page failed")
+	}
+}
+
+// withDateFormat is a middleware that makes the templates display dates in the configured format
+func (a *appServer) withDateFormat(c *gin.Context) {
+	c.Request = c.Request.WithContext(ui.WithDateFormat(c.Request.Context(), a.dateFormat))
+	c.Next()
+}
+
+// withSite is a middleware that makes the templates present the configured title and authors of the site
+func (a *appServer) withSite(c *gin.Context) {
+	c.Request = c.Request.WithContext(ui.WithSite(c.Request.Context(), a.site))
+	c.Next()
+}
+
 // presentSubContent is a helper function to present sub content
 func presentSubContent(c *gin.Context, subContent templ.Component) error {
+	return presentSubContentWithStatus(c, http.StatusOK, subContent)
+}
+
+// presentSubContentWithStatus is a helper function to present sub content with a given status code
+func presentSubContentWithStatus(c *gin.Context, status int, subContent templ.Component) error {
 	c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
-	c.Status(http.StatusOK)
+	c.Status(status)
 	var err error
 	if c.GetHeader("HX-Request") == "true" {
 		err = subContent.Render(c.Request.Context(), c.Writer)
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/ui"
+	"golang.org/x/text/language"
+)
+
+// withLang is a middleware that makes the templates speak the language of the reader,
+// the best match of the Accept-Language header among the site language and the message catalogs
+func (a *appServer) withLang(c *gin.Context) {
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	langs := append([]string{a.site.Lang}, ui.Languages()...)
+	lang := langs[0]
+	if i, ok := matchLang(c.GetHeader("Accept-Language"), langs); ok {
+		lang = langs[i]
+	}
+	c.Request = c.Request.WithContext(ui.WithLang(c.Request.Context(), lang))
+	c.Next()
+}
+
+// matchLang returns the index of the language of langs that best matches an Accept-Language header,
+// false if the header is invalid or none of them matches
+func matchLang(header string, langs []string) (int, bool) {
+	desired, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(desired) == 0 || len(langs) == 0 {
+		return 0, false
+	}
+	tags := make([]language.Tag, len(langs))
+	for i, lang := range langs {
+		tags[i] = language.Make(lang)
+	}
+	_, i, confidence := language.NewMatcher(tags).Match(desired...)
+	if confidence == language.No {
+		return 0, false
+	}
+	return i, true
+}
+
+// pickTranslation returns the translation of the post id to present: the one in the language
+// of the URL prefix if there is one, else the best match of the Accept-Language header,
+// else the requested post itself. It returns false if the post has no translation in the prefixed language.
+func pickTranslation(translations []post.Post, id string, prefix string, header string) (post.Post, bool) {
+	requested := 0
+	for i, t := range translations {
+		if t.ID == id {
+			requested = i
+		}
+	}
+	// the requested post comes first, so that it wins the ties
+	langs := []string{translations[requested].Lang}
+	candidates := []post.Post{translations[requested]}
+	for i, t := range translations {
+		if i != requested {
+			langs = append(langs, t.Lang)
+			candidates = append(candidates, t)
+		}
+	}
+
+	if prefix != "" {
+		i, ok := matchLang(prefix, langs)
+		return candidates[i], ok
+	}
+	if i, ok := matchLang(header, langs); ok {
+		return candidates[i], true
+	}
+	return candidates[0], true
+}
+
+// postAlternates returns the versions of a post in every language for the hreflang links of its page,
+// with an x-default version negotiating the language that starts from the post in the site language
+func (a *appServer) postAlternates(translations []post.Post, presented post.Post) []ui.Alternate {
+	fallback := presented.ID
+	alternates := make([]ui.Alternate, 0, len(translations)+1)
+	for _, t := range translations {
+		alternates = append(alternates, ui.Alternate{Lang: t.Lang, URL: a.baseURL + ui.TranslationURL(t)})
+		if t.Lang == a.site.Lang {
+			fallback = t.ID
+		}
+	}
+	return append(alternates, ui.Alternate{Lang: "x-default", URL: a.baseURL + "/post/" + fallback})
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/ui"
+)
+
+// ProjectsHandler is the handler for the /projects endpoint
+func (a *appServer) ProjectsHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("ProjectsHandler: serving projects endpoint")
+	projects, err := a.projects.GetProjects(log)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting projects failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	err = presentSubContent(c, ui.ProjectList(projects))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering projects failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// ProjectHandler is the handler for the /projects/:id endpoint
+func (a *appServer) ProjectHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("ProjectHandler: serving projects/id endpoint")
+	id := c.Param("id")
+	p, err := a.projects.GetProject(log, id)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting project failed")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			a.presentNotFound(c, ui.T(c.Request.Context(), "notFound.project", id))
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	detail, err := render.Convert([]byte(p.Markdown))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("converting detail of project %s failed", id)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	posts, err := a.postsByTags(log, p.Tags)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("getting posts of project %s failed", id)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	view := ui.ProjectView{Project: p, DetailHTML: detail, Posts: posts, CommentCounts: a.commentCounts(log)}
+	err = presentSubContent(c, ui.ProjectPage(view))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("rendering projects/%s failed", id)
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+}
+
+// postsByTags returns the posts having any of the tags, newest first
+func (a *appServer) postsByTags(log logger.LoggingFn, tags []string) ([]post.Post, error) {
+	seen := make(map[string]bool)
+	posts := make([]post.Post, 0)
+	for _, tag := range tags {
+		tagged, err := a.pService.GetPostsByTag(log, tag)
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range tagged {
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				posts = append(posts, p)
+			}
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Date.After(posts[j].Date)
+	})
+	return posts, nil
+}
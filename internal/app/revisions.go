@@ -0,0 +1,134 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/diff"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/ui"
+)
+
+// RevisionsHandler is the handler for GET /admin/revisions/:id
+func (a *appServer) RevisionsHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("RevisionsHandler: serving revisions page")
+	a.presentRevisions(c, c.Param("id"), "")
+}
+
+// RevisionDiffHandler is the handler for GET /admin/revisions/:id/diff?from=&to=
+func (a *appServer) RevisionDiffHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("RevisionDiffHandler: serving revision diff page")
+	id := c.Param("id")
+	from, err := a.revisions.Get(id, c.Query("from"))
+	if err == nil {
+		var to post.Revision
+		if to, err = a.revisions.Get(id, c.Query("to")); err == nil {
+			view := ui.DiffView{PostID: id, From: from, To: to, Lines: diff.Lines(from.Text(), to.Text())}
+			if err := presentSubContent(c, ui.RevisionDiff(view)); err != nil {
+				log(logger.ErrorLevel).Err(err).Msg("rendering revision diff failed")
+				c.String(http.StatusInternalServerError, internalServerErrorMsg)
+			}
+			return
+		}
+	}
+	log(logger.ErrorLevel).Err(err).Msg("getting revision failed")
+	var keyError *post.KeyError
+	if errors.As(err, &keyError) {
+		a.presentNotFound(c, "Revision not found")
+		return
+	}
+	c.String(http.StatusInternalServerError, internalServerErrorMsg)
+}
+
+// RevisionRestoreHandler is the handler for POST /admin/revisions/:id/:rev/restore
+// The restored version is saved as the post and kept as a new revision, a deleted post is created again.
+func (a *appServer) RevisionRestoreHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("RevisionRestoreHandler: serving revision restore endpoint")
+	id := c.Param("id")
+	rev, err := a.revisions.Get(id, c.Param("rev"))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting revision failed")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			a.presentNotFound(c, "Revision not found")
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+
+	saved, err := a.pService.UpdatePost(log, id, rev.Post)
+	var keyError *post.KeyError
+	if errors.As(err, &keyError) && errors.Is(keyError.Err, post.ErrKeyNotExist) {
+		saved, err = a.pService.CreatePost(log, rev.Post)
+	}
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msgf("restoring revision %s of post/%s failed", rev.ID, id)
+		a.presentRevisions(c, id, "The revision could not be restored: "+err.Error())
+		return
+	}
+	a.postSaved(c, saved, rev.Post.Markdown, "Restored from "+ui.FormatTime(c.Request.Context(), rev.Time))
+	a.presentRevisions(c, id, "The revision has been restored.")
+}
+
+// presentRevisions presents the revision history of a post with a message.
+func (a *appServer) presentRevisions(c *gin.Context, id string, message string) {
+	log := a.logger.ContextLoggingFn(c)
+	revisions, err := a.revisions.List(id)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("listing revisions failed")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			a.presentNotFound(c, "There are no revisions of "+id+".")
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	view := ui.RevisionsView{PostID: id, Revisions: revisions, Message: message}
+	if err := presentSubContent(c, ui.Revisions(view)); err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("rendering revisions failed")
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+	}
+}
+
+// recordBaseline keeps the stored version of a post as its first revision before it is changed,
+// so that the version from before the first edit can be restored. Posts with revisions already are left alone.
+// The post is about to be saved, a failure is only logged.
+func (a *appServer) recordBaseline(c *gin.Context, log logger.LoggingFn, id string) {
+	_, err := a.revisions.List(id)
+	var keyError *post.KeyError
+	if !errors.As(err, &keyError) {
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Str("id", id).Msg("listing revisions failed")
+		}
+		return
+	}
+	current, err := a.pService.GetUnpublishedPost(log, id)
+	if err != nil {
+		// the save fails on the missing post as well
+		return
+	}
+	markdown, err := a.readMarkdown(current)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Str("id", id).Msg("reading the original markdown failed")
+		return
+	}
+	a.recordRevision(c, current, markdown, "original")
+}
+
+// recordRevision keeps a saved post as a new revision by the logged in user.
+// The post is saved already, a failure is only logged.
+func (a *appServer) recordRevision(c *gin.Context, saved post.Post, markdown string, note string) {
+	rev, err := a.revisions.Add(c.GetString(adminUserKey), note, post.NewPostInput(saved, markdown, a.dateFormat.Location))
+	if err != nil {
+		a.logger.Errorc(c).Err(err).Str("id", saved.ID).Msg("recording revision failed")
+		return
+	}
+	a.logger.Debugc(c).Str("id", saved.ID).Str("revision", rev.ID).Msg("revision recorded")
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"net/http"
 
+	"github.com/gin-gonic/gin"
 	"github.com/kegliz/silent-blog/internal/server/router"
 )
 
@@ -20,6 +21,12 @@ func (a *appServer) routes() []*router.Route {
 			Pattern:     "/health",
 			HandlerFunc: a.HealthHandler,
 		},
+		{
+			Name:        "renderstats",
+			Method:      http.MethodGet,
+			Pattern:     "/health/render",
+			HandlerFunc: a.RenderStatsHandler,
+		},
 		{
 			Name:        "about",
 			Method:      http.MethodGet,
@@ -32,11 +39,215 @@ func (a *appServer) routes() []*router.Route {
 			Pattern:     "/posts",
 			HandlerFunc: a.PostsHandler,
 		},
+		{
+			Name:        "tags",
+			Method:      http.MethodGet,
+			Pattern:     "/tags",
+			HandlerFunc: a.TagsHandler,
+		},
+		{
+			Name:        "tag",
+			Method:      http.MethodGet,
+			Pattern:     "/tags/:tag",
+			HandlerFunc: a.TagHandler,
+		},
+		{
+			Name:        "authors",
+			Method:      http.MethodGet,
+			Pattern:     "/authors",
+			HandlerFunc: a.AuthorsHandler,
+		},
+		{
+			Name:        "author",
+			Method:      http.MethodGet,
+			Pattern:     "/authors/:id",
+			HandlerFunc: a.AuthorHandler,
+		},
+		{
+			Name:        "projects",
+			Method:      http.MethodGet,
+			Pattern:     "/projects",
+			HandlerFunc: a.ProjectsHandler,
+		},
+		{
+			Name:        "project",
+			Method:      http.MethodGet,
+			Pattern:     "/projects/:id",
+			HandlerFunc: a.ProjectHandler,
+		},
+		{
+			Name:        "series",
+			Method:      http.MethodGet,
+			Pattern:     "/series/:name",
+			HandlerFunc: a.SeriesHandler,
+		},
+		{
+			Name:        "search",
+			Method:      http.MethodGet,
+			Pattern:     "/search",
+			HandlerFunc: a.SearchHandler,
+		},
 		{
 			Name:        "post",
 			Method:      http.MethodGet,
 			Pattern:     "/post/:id", // /post/13 ---- c.Param("id")
 			HandlerFunc: a.PresentPost,
 		},
+		{
+			Name:        "translation",
+			Method:      http.MethodGet,
+			Pattern:     "/:lang/post/:id", // /hu/post/13 ---- c.Param("lang"), c.Param("id")
+			HandlerFunc: a.PresentPost,
+		},
+		{
+			Name:        "webmention",
+			Method:      http.MethodPost,
+			Pattern:     "/webmention",
+			HandlerFunc: a.WebmentionHandler,
+			Middleware:  []gin.HandlerFunc{a.requireWebmentions},
+		},
+		{
+			Name:        "comment",
+			Method:      http.MethodPost,
+			Pattern:     "/post/:id/comments",
+			HandlerFunc: a.CommentHandler,
+			Middleware:  []gin.HandlerFunc{a.requireComments},
+		},
+		{
+			Name:        "preview",
+			Method:      http.MethodGet,
+			Pattern:     "/preview/:id", // /preview/13?token=...
+			HandlerFunc: a.PreviewHandler,
+		},
+		{
+			Name:        "login",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/login",
+			HandlerFunc: a.LoginPageHandler,
+		},
+		{
+			Name:        "loginform",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/login",
+			HandlerFunc: a.LoginHandler,
+		},
+		{
+			Name:        "logout",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/logout",
+			HandlerFunc: a.LogoutPageHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "logoutform",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/logout",
+			HandlerFunc: a.LogoutHandler,
+		},
+		{
+			Name:        "admin",
+			Method:      http.MethodGet,
+			Pattern:     "/admin",
+			HandlerFunc: a.AdminHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "editor",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/editor",
+			HandlerFunc: a.EditorHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "editpost",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/editor/:id",
+			HandlerFunc: a.EditorHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "editorsave",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/editor",
+			HandlerFunc: a.EditorSaveHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "editorpreview",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/editor/preview",
+			HandlerFunc: a.EditorPreviewHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "commentqueue",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/comments",
+			HandlerFunc: a.CommentQueueHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin, a.requireComments},
+		},
+		{
+			Name:        "commentapprove",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/comments/:id/approve",
+			HandlerFunc: a.CommentApproveHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin, a.requireComments},
+		},
+		{
+			Name:        "commentdelete",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/comments/:id/delete",
+			HandlerFunc: a.CommentDeleteHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin, a.requireComments},
+		},
+		{
+			Name:        "revisions",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/revisions/:id",
+			HandlerFunc: a.RevisionsHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "revisiondiff",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/revisions/:id/diff",
+			HandlerFunc: a.RevisionDiffHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "revisionrestore",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/revisions/:id/:rev/restore",
+			HandlerFunc: a.RevisionRestoreHandler,
+			Middleware:  []gin.HandlerFunc{a.requireLogin},
+		},
+		{
+			Name:        "adminpost",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/api/posts/:id",
+			HandlerFunc: a.AdminGetPostHandler,
+			Middleware:  []gin.HandlerFunc{a.requireAdmin},
+		},
+		{
+			Name:        "admincreatepost",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/api/posts",
+			HandlerFunc: a.AdminCreatePostHandler,
+			Middleware:  []gin.HandlerFunc{a.requireAdmin},
+		},
+		{
+			Name:        "adminupdatepost",
+			Method:      http.MethodPut,
+			Pattern:     "/admin/api/posts/:id",
+			HandlerFunc: a.AdminUpdatePostHandler,
+			Middleware:  []gin.HandlerFunc{a.requireAdmin},
+		},
+		{
+			Name:        "admindeletepost",
+			Method:      http.MethodDelete,
+			Pattern:     "/admin/api/posts/:id",
+			HandlerFunc: a.AdminDeletePostHandler,
+			Middleware:  []gin.HandlerFunc{a.requireAdmin},
+		},
 	}
 }
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/kegliz/silent-blog/internal/webmention"
+	"github.com/kegliz/silent-blog/ui"
+	"golang.org/x/text/language"
+)
+
+const (
+	// sendTimeout limits the time spent notifying the pages linked from a post
+	sendTimeout = 2 * time.Minute
+	// publicationCheckInterval is how often the posts are checked for having been published, as scheduled by publishAt
+	publicationCheckInterval = time.Minute
+)
+
+// WebmentionHandler is the handler for POST /webmention
+// A valid mention of a published post is accepted and verified in the background.
+func (a *appServer) WebmentionHandler(c *gin.Context) {
+	log := a.logger.ContextLoggingFn(c)
+	log(logger.DebugLevel).Msg("WebmentionHandler: serving webmention endpoint")
+	source, target := c.PostForm("source"), c.PostForm("target")
+	id, ok := a.postIDOf(target)
+	if !ok {
+		log(logger.WarnLevel).Str("target", target).Msg("webmention of an unknown target")
+		c.String(http.StatusBadRequest, webmention.ErrInvalidTarget.Error())
+		return
+	}
+	if _, err := a.pService.GetPost(log, id); err != nil {
+		log(logger.WarnLevel).Err(err).Str("target", target).Msg("webmention of an unknown post")
+		var keyError *post.KeyError
+		if errors.As(err, &keyError) {
+			c.String(http.StatusBadRequest, webmention.ErrInvalidTarget.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, internalServerErrorMsg)
+		return
+	}
+	if err := a.webmentions.Accept(source, target, id); err != nil {
+		log(logger.WarnLevel).Err(err).Str("source", source).Str("target", target).Msg("webmention not accepted")
+		if errors.Is(err, webmention.ErrQueueFull) || errors.Is(err, webmention.ErrClosed) {
+			c.String(http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	log(logger.InfoLevel).Str("source", source).Str("target", target).Msg("webmention accepted")
+	c.String(http.StatusAccepted, "The webmention will be verified.")
+}
+
+// requireWebmentions is a middleware answering 404 when receiving webmentions is disabled.
+func (a *appServer) requireWebmentions(c *gin.Context) {
+	if a.webmentions == nil {
+		a.presentNotFound(c, ui.T(c.Request.Context(), "mention.disabled"))
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// postIDOf returns the ID of the post at the public URL target, false if target is not a post URL of the site.
+// The URL may start with a language, like the URLs of translated posts.
+func (a *appServer) postIDOf(target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	u.RawQuery, u.Fragment = "", ""
+	path, ok := strings.CutPrefix(u.String(), a.baseURL+"/")
+	if !ok {
+		return "", false
+	}
+	if lang, rest, found := strings.Cut(path, "/"); found && lang != "post" {
+		if _, err := language.Parse(lang); err != nil {
+			return "", false
+		}
+		path = rest
+	}
+	escaped, ok := strings.CutPrefix(path, "post/")
+	if !ok || escaped == "" || strings.Contains(escaped, "/") {
+		return "", false
+	}
+	id, err := url.PathUnescape(escaped)
+	return id, err == nil
+}
+
+// postURL returns the public URL of a post.
+func (a *appServer) postURL(id string) string {
+	return a.baseURL + "/post/" + url.PathEscape(id)
+}
+
+// watchPublications notifies the pages linked from the posts once they are published,
+// checking when the posts are reloaded and every publicationCheckInterval until a.stop is closed.
+func (a *appServer) watchPublications() {
+	ticker := time.NewTicker(publicationCheckInterval)
+	defer ticker.Stop()
+	for {
+		a.checkPublications()
+		select {
+		case <-a.stop:
+			return
+		case <-a.reloaded:
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkPublications notifies the pages linked from the published posts which were never notified.
+// A fresh log takes the posts published so far as notified, so that the first start does not notify them all again.
+func (a *appServer) checkPublications() {
+	a.publicationsMu.Lock()
+	defer a.publicationsMu.Unlock()
+	log := a.logger.BackgroundLoggingFn()
+	posts, err := a.pService.GetPosts(log)
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Msg("getting posts for webmentions failed")
+		return
+	}
+	now := time.Now()
+	if a.sentWebmentions.Fresh() {
+		ids := make([]string, 0, len(posts))
+		for _, p := range posts {
+			if p.IsPublished(now) {
+				ids = append(ids, p.ID)
+			}
+		}
+		if err := a.sentWebmentions.MarkSent(now, ids...); err != nil {
+			log(logger.ErrorLevel).Err(err).Msg("recording published posts failed")
+		}
+		return
+	}
+	for _, p := range posts {
+		if !p.IsPublished(now) || a.sentWebmentions.Sent(p.ID) {
+			continue
+		}
+		markdown, err := a.readMarkdown(p)
+		if err != nil {
+			log(logger.ErrorLevel).Err(err).Str("id", p.ID).Msg("reading post for webmentions failed")
+			continue
+		}
+		a.sendWebmentions(log, p, markdown)
+	}
+}
+
+// sendWebmentions notifies the pages linked from a published post in the background and records it as notified.
+func (a *appServer) sendWebmentions(log logger.LoggingFn, saved post.Post, markdown string) {
+	if a.webmentionSender == nil || !saved.IsPublished(time.Now()) {
+		return
+	}
+	content, err := render.Convert([]byte(markdown))
+	if err != nil {
+		log(logger.ErrorLevel).Err(err).Str("id", saved.ID).Msg("rendering post for webmentions failed")
+		return
+	}
+	source := a.postURL(saved.ID)
+	started := a.goBackground(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		for _, result := range a.webmentionSender.Send(ctx, source, content) {
+			switch {
+			case errors.Is(result.Err, webmention.ErrNoEndpoint):
+				a.logger.Debug().Str("source", source).Str("target", result.Target).Msg("no webmention endpoint")
+			case result.Err != nil:
+				a.logger.Warn().Err(result.Err).Str("source", source).Str("target", result.Target).Msg("sending webmention failed")
+			default:
+				a.logger.Info().Str("source", source).Str("target", result.Target).Int("status", result.Status).Msg("webmention sent")
+			}
+		}
+	})
+	if !started {
+		log(logger.WarnLevel).Str("id", saved.ID).Msg("not sending webmentions while shutting down")
+		return
+	}
+	if err := a.sentWebmentions.MarkSent(time.Now(), saved.ID); err != nil {
+		log(logger.ErrorLevel).Err(err).Str("id", saved.ID).Msg("recording sent webmentions failed")
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// requestWith returns a request carrying the cookies set on rec.
+func requestWith(rec *httptest.ResponseRecorder) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+// TestSessions tests issuing, verifying, rotating and clearing sessions
+func TestSessions(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := NewSessions(SessionOptions{Key: []byte("secret"), TTL: time.Hour})
+	assert.Nil(err)
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	issued := m.Issue(rec, "admin.user")
+	cookie := rec.Result().Cookies()[0]
+	assert.Equal(CookieName, cookie.Name)
+	assert.True(cookie.HttpOnly)
+	assert.Equal(3600, cookie.MaxAge)
+
+	s, err := m.Verify(requestWith(rec))
+	assert.Nil(err)
+	assert.Equal("admin.user", s.User)
+	assert.True(issued.ExpiresAt.Equal(s.ExpiresAt))
+
+	_, err = m.Verify(httptest.NewRequest(http.MethodGet, "/admin", nil))
+	assert.ErrorIs(err, ErrNoSession)
+	_, err = m.parse(strings.Replace(cookie.Value, cookie.Value[:4], "cm9v", 1))
+	assert.ErrorIs(err, ErrInvalidSession, "the user should be signed")
+	other, _ := NewSessions(SessionOptions{Key: []byte("other secret"), TTL: time.Hour})
+	_, err = other.Verify(requestWith(rec))
+	assert.ErrorIs(err, ErrInvalidSession, "the session should be bound to the key")
+
+	now = now.Add(20 * time.Minute)
+	_, rotated := m.Refresh(httptest.NewRecorder(), s)
+	assert.False(rotated, "a fresh session should not be rotated")
+	now = now.Add(20 * time.Minute)
+	refreshRec := httptest.NewRecorder()
+	s, rotated = m.Refresh(refreshRec, s)
+	assert.True(rotated, "a session past half of its lifetime should be rotated")
+	assert.True(now.Add(time.Hour).Equal(s.ExpiresAt))
+
+	now = now.Add(30 * time.Minute)
+	_, err = m.Verify(requestWith(rec))
+	assert.ErrorIs(err, ErrExpiredSession)
+	_, err = m.Verify(requestWith(refreshRec))
+	assert.Nil(err, "the rotated session should outlive the first one")
+
+	clearRec := httptest.NewRecorder()
+	m.Clear(clearRec)
+	assert.Equal(-1, clearRec.Result().Cookies()[0].MaxAge)
+
+	_, err = NewSessions(SessionOptions{TTL: time.Hour})
+	assert.ErrorIs(err, ErrNoKey)
+}
+
+// TestCheckCredentials tests comparing logins with a bcrypt hash
+func TestCheckCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	hash, err := HashPassword("correct horse")
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(hash, "$2a$"))
+
+	assert.Nil(CheckCredentials("admin", "correct horse", "admin", hash))
+	assert.ErrorIs(CheckCredentials("admin", "wrong", "admin", hash), ErrInvalidCredentials)
+	assert.ErrorIs(CheckCredentials("root", "correct horse", "admin", hash), ErrInvalidCredentials)
+	assert.ErrorIs(CheckCredentials("admin", "", "admin", ""), ErrInvalidCredentials, "login should fail without a configured hash")
+
+	_, err = HashPassword("")
+	assert.Error(err)
+}
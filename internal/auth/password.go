@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid user or password")
+
+// dummyHash is compared against when the user is unknown, so that a login takes as long whether the user exists or not.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("silent-blog"), bcrypt.DefaultCost)
+	return hash
+})
+
+// HashPassword returns the bcrypt hash of a password, as written in admin.passwordhash.
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("the password must not be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckCredentials compares a login with the configured user and password hash.
+// It returns ErrInvalidCredentials whether the user or the password is wrong.
+func CheckCredentials(user string, password string, wantUser string, wantHash string) error {
+	hash := []byte(wantHash)
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+	if !userOK || wantHash == "" {
+		hash = dummyHash()
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !userOK || wantHash == "" {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieName is the name of the session cookie.
+const CookieName = "silent_session"
+
+var (
+	ErrNoSession      = errors.New("no session")
+	ErrInvalidSession = errors.New("invalid session")
+	ErrExpiredSession = errors.New("session expired")
+	ErrNoKey          = errors.New("no session signing key configured")
+)
+
+type (
+	// SessionOptions is a struct that contains the options for constructing a Sessions.
+	// TTL is the lifetime of a session, Secure restricts the cookie to HTTPS.
+	SessionOptions struct {
+		Key    []byte
+		TTL    time.Duration
+		Secure bool
+	}
+
+	// Sessions issues and verifies the HMAC-signed session cookies of the admin.
+	// A session is rotated, reissued with a fresh expiry, once half of its lifetime has passed,
+	// so that an active admin stays logged in and an idle one is logged out after TTL.
+	Sessions struct {
+		key    []byte
+		ttl    time.Duration
+		secure bool
+		// now is the clock deciding whether a session is expired
+		now func() time.Time
+	}
+
+	// Session is a logged in user.
+	Session struct {
+		User      string
+		IssuedAt  time.Time
+		ExpiresAt time.Time
+	}
+)
+
+// NewSessions returns a new Sessions using key for the HMAC.
+func NewSessions(opts SessionOptions) (*Sessions, error) {
+	if len(opts.Key) == 0 {
+		return nil, ErrNoKey
+	}
+	if opts.TTL <= 0 {
+		return nil, errors.New("the session lifetime must be positive")
+	}
+	return &Sessions{key: opts.Key, ttl: opts.TTL, secure: opts.Secure, now: time.Now}, nil
+}
+
+// Issue starts a new session of user and sets its cookie.
+func (m *Sessions) Issue(w http.ResponseWriter, user string) Session {
+	now := m.now()
+	s := Session{User: user, IssuedAt: now, ExpiresAt: now.Add(m.ttl)}
+	m.setCookie(w, m.token(s), s.ExpiresAt)
+	return s
+}
+
+// Verify returns the session of the request's cookie.
+func (m *Sessions) Verify(r *http.Request) (Session, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return Session{}, ErrNoSession
+	}
+	return m.parse(cookie.Value)
+}
+
+// Refresh rotates the session if half of its lifetime has passed and reports whether it did.
+func (m *Sessions) Refresh(w http.ResponseWriter, s Session) (Session, bool) {
+	if m.now().Before(s.IssuedAt.Add(m.ttl / 2)) {
+		return s, false
+	}
+	return m.Issue(w, s.User), true
+}
+
+// Clear removes the session cookie.
+func (m *Sessions) Clear(w http.ResponseWriter) {
+	m.setCookie(w, "", time.Unix(0, 0))
+}
+
+// setCookie sets the session cookie to value until expires.
+func (m *Sessions) setCookie(w http.ResponseWriter, value string, expires time.Time) {
+	maxAge := int(expires.Sub(m.now()).Seconds())
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// token returns the signed cookie value of a session: the user, the issue and expiry times and the signature.
+func (m *Sessions) token(s Session) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(s.User)) + "." +
+		strconv.FormatInt(s.IssuedAt.Unix(), 10) + "." +
+		strconv.FormatInt(s.ExpiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(m.mac(payload))
+}
+
+// parse verifies the signature and the expiry of a cookie value.
+func (m *Sessions) parse(token string) (Session, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return Session{}, ErrInvalidSession
+	}
+	payload := token[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(sig, m.mac(payload)) {
+		return Session{}, ErrInvalidSession
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return Session{}, ErrInvalidSession
+	}
+	user, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Session{}, ErrInvalidSession
+	}
+	issued, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Session{}, ErrInvalidSession
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Session{}, ErrInvalidSession
+	}
+	s := Session{User: string(user), IssuedAt: time.Unix(issued, 0), ExpiresAt: time.Unix(expires, 0)}
+	if !m.now().Before(s.ExpiresAt) {
+		return Session{}, ErrExpiredSession
+	}
+	return s, nil
+}
+
+// mac computes the HMAC of a session payload.
+func (m *Sessions) mac(payload string) []byte {
+	h := hmac.New(sha256.New, m.key)
+	h.Write([]byte("session\n" + payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,136 @@
+package author
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// validID are the IDs an author can have, they are used in URLs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+type (
+	// Author is a writer of the blog, as described in the authors file.
+	Author struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		// Bio is written in markdown.
+		Bio string `json:"bio"`
+		// Avatar is the URL of the picture of the author, absolute or relative to the site.
+		Avatar string `json:"avatar"`
+		Links  []Link `json:"links"`
+	}
+
+	// Link is a page of an author elsewhere, like a profile or a homepage.
+	Link struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	}
+
+	// Directory holds the authors of the blog. The nil Directory has no authors.
+	Directory struct {
+		authors []Author
+		byID    map[string]Author
+	}
+)
+
+// NewDirectory returns the directory of authors, kept in the given order.
+// It returns an error if an author has an invalid or duplicate ID, no name, or a link that is not http(s).
+func NewDirectory(authors []Author) (*Directory, error) {
+	d := &Directory{byID: make(map[string]Author, len(authors))}
+	for i, a := range authors {
+		a.ID, a.Name = strings.TrimSpace(a.ID), strings.TrimSpace(a.Name)
+		switch {
+		case !validID.MatchString(a.ID):
+			return nil, fmt.Errorf("author #%d: invalid id %q", i+1, a.ID)
+		case d.byID[a.ID].ID != "":
+			return nil, fmt.Errorf("author %q: duplicate id", a.ID)
+		case a.Name == "":
+			return nil, fmt.Errorf("author %q: name is required", a.ID)
+		case a.Avatar != "" && !validURL(a.Avatar, true):
+			return nil, fmt.Errorf("author %q: invalid avatar %q", a.ID, a.Avatar)
+		}
+		for _, link := range a.Links {
+			if !validURL(link.URL, false) {
+				return nil, fmt.Errorf("author %q: invalid link %q", a.ID, link.URL)
+			}
+		}
+		d.authors = append(d.authors, a)
+		d.byID[a.ID] = a
+	}
+	return d, nil
+}
+
+// Load reads the directory from the json file fileName of fsys, a list of authors.
+// An empty fileName yields a directory without authors.
+func Load(fsys fs.FS, fileName string) (*Directory, error) {
+	if fileName == "" {
+		return NewDirectory(nil)
+	}
+	data, err := fs.ReadFile(fsys, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Load: cannot read authors file : %v", err)
+	}
+	var authors []Author
+	if err := json.Unmarshal(data, &authors); err != nil {
+		return nil, fmt.Errorf("Load: cannot unmarshal authors file : %v", err)
+	}
+	d, err := NewDirectory(authors)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %v", err)
+	}
+	return d, nil
+}
+
+// Get returns the author with the given ID.
+func (d *Directory) Get(id string) (Author, bool) {
+	if d == nil {
+		return Author{}, false
+	}
+	a, ok := d.byID[id]
+	return a, ok
+}
+
+// All returns every author in the order of the authors file.
+func (d *Directory) All() []Author {
+	if d == nil {
+		return nil
+	}
+	return d.authors
+}
+
+// Find returns the authors with the given IDs in order, skipping the unknown ones.
+func (d *Directory) Find(ids []string) []Author {
+	var authors []Author
+	for _, id := range ids {
+		if a, ok := d.Get(id); ok {
+			authors = append(authors, a)
+		}
+	}
+	return authors
+}
+
+// Unknown returns the first of ids that is not the ID of an author, false if all of them are.
+func (d *Directory) Unknown(ids []string) (string, bool) {
+	for _, id := range ids {
+		if _, ok := d.Get(id); !ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// validURL reports whether u is an http(s) URL, or a path of the site if relative is allowed.
+func validURL(u string, relative bool) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" && parsed.Host == "" {
+		return relative && strings.HasPrefix(parsed.Path, "/")
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
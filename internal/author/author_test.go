@@ -0,0 +1,52 @@
+package author
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoad tests reading and validating the authors file
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys := fstest.MapFS{
+		"authors.json": {Data: []byte(`[
+			{"id": "ann", "name": "Ann Other", "bio": "Writes *Go*.", "avatar": "/static/ann.png", "links": [{"title": "GitHub", "url": "https://github.com/ann"}]},
+			{"id": "bob", "name": " Bob "}
+		]`)},
+		"duplicate.json": {Data: []byte(`[{"id": "ann", "name": "Ann"}, {"id": "ann", "name": "Ann again"}]`)},
+		"nameless.json":  {Data: []byte(`[{"id": "ann"}]`)},
+		"badid.json":     {Data: []byte(`[{"id": "ann other", "name": "Ann"}]`)},
+		"badlink.json":   {Data: []byte(`[{"id": "ann", "name": "Ann", "links": [{"title": "x", "url": "javascript:alert(1)"}]}]`)},
+		"badavatar.json": {Data: []byte(`[{"id": "ann", "name": "Ann", "avatar": "data:image/png;base64,AAAA"}]`)},
+	}
+
+	d, err := Load(fsys, "authors.json")
+	assert.Nil(err)
+	assert.Len(d.All(), 2)
+	ann, ok := d.Get("ann")
+	assert.True(ok)
+	assert.Equal("Ann Other", ann.Name)
+	assert.Equal("https://github.com/ann", ann.Links[0].URL)
+	assert.Equal("Bob", d.All()[1].Name, "names should be trimmed")
+	assert.Equal([]Author{d.All()[1], ann}, d.Find([]string{"bob", "eve", "ann"}))
+	unknown, found := d.Unknown([]string{"ann", "eve"})
+	assert.True(found)
+	assert.Equal("eve", unknown)
+	_, found = d.Unknown([]string{"ann", "bob"})
+	assert.False(found)
+
+	for _, fileName := range []string{"duplicate.json", "nameless.json", "badid.json", "badlink.json", "badavatar.json", "missing.json"} {
+		_, err := Load(fsys, fileName)
+		assert.NotNil(err, fileName)
+	}
+
+	empty, err := Load(fsys, "")
+	assert.Nil(err)
+	assert.Empty(empty.All())
+	var none *Directory
+	_, found = none.Unknown([]string{"ann"})
+	assert.True(found, "the nil directory should have no authors")
+}
@@ -0,0 +1,112 @@
+// Package comment keeps the comments of the readers on the posts, which are shown once approved by the admin.
+package comment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// StatusPending comments wait in the moderation queue.
+	StatusPending Status = "pending"
+	// StatusApproved comments are shown under their post.
+	StatusApproved Status = "approved"
+
+	// maxAuthorLength and maxBodyLength limit the size of a comment, in characters.
+	maxAuthorLength = 80
+	maxBodyLength   = 4000
+)
+
+var (
+	ErrNotFound = errors.New("comment not found")
+)
+
+type (
+	// Status is the moderation state of a comment.
+	Status string
+
+	// Comment is a comment of a reader on a post.
+	Comment struct {
+		ID     string `json:"id"`
+		PostID string `json:"postId"`
+		Author string `json:"author"`
+		// Body is the markdown-lite source of the comment, see Render.
+		Body   string    `json:"body"`
+		Time   time.Time `json:"time"`
+		Status Status    `json:"status"`
+	}
+
+	// Store keeps the comments.
+	Store interface {
+		// Add stores a new comment with a new ID, the ID of c is ignored.
+		Add(c Comment) (Comment, error)
+		// Approved returns the approved comments of a post, oldest first.
+		Approved(postID string) ([]Comment, error)
+		// Pending returns the comments waiting for moderation on every post, oldest first.
+		Pending() ([]Comment, error)
+		// Approve shows a pending comment under its post.
+		// It returns ErrNotFound if the comment does not exist.
+		Approve(id string) error
+		// Delete removes a comment.
+		// It returns ErrNotFound if the comment does not exist.
+		Delete(id string) error
+		// Counts returns the number of approved comments by post ID.
+		Counts() (map[string]int, error)
+		// Close releases the resources of the store.
+		Close() error
+	}
+
+	// ValidationError lists the invalid fields of a comment.
+	ValidationError struct {
+		// Fields maps the invalid fields to their problem
+		Fields map[string]string
+	}
+)
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, problem := range e.Fields {
+		fields = append(fields, field+" "+problem)
+	}
+	sort.Strings(fields)
+	return "invalid comment: " + strings.Join(fields, ", ")
+}
+
+// Validate trims the fields of a new comment and checks them.
+func Validate(c Comment) (Comment, error) {
+	c.Author = strings.TrimSpace(c.Author)
+	c.Body = strings.TrimSpace(strings.ReplaceAll(c.Body, "\r\n", "\n"))
+	fields := map[string]string{}
+	switch {
+	case c.Author == "":
+		fields["author"] = "is required"
+	case utf8.RuneCountInString(c.Author) > maxAuthorLength:
+		fields["author"] = fmt.Sprintf("must be at most %d characters", maxAuthorLength)
+	}
+	switch {
+	case c.Body == "":
+		fields["body"] = "is required"
+	case utf8.RuneCountInString(c.Body) > maxBodyLength:
+		fields["body"] = fmt.Sprintf("must be at most %d characters", maxBodyLength)
+	}
+	if len(fields) > 0 {
+		return c, &ValidationError{Fields: fields}
+	}
+	return c, nil
+}
+
+// newID returns a random comment ID.
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
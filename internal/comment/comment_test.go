@@ -0,0 +1,140 @@
+package comment
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStores tests adding, moderating and counting comments in every store
+func TestStores(t *testing.T) {
+	for name, open := range map[string]func(dir string) (Store, error){
+		"file": func(dir string) (Store, error) { return NewFileStore(filepath.Join(dir, "comments.json")) },
+		"sqlite": func(dir string) (Store, error) {
+			return NewSQLiteStore(filepath.Join(dir, "comments.db"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			dir := t.TempDir()
+			s, err := open(dir)
+			assert.Nil(err)
+
+			start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			var added []Comment
+			for i, postID := range []string{"first", "first", "second"} {
+				c, err := s.Add(Comment{PostID: postID, Author: "reader", Body: "hello", Time: start.Add(time.Duration(i) * time.Minute), Status: StatusPending})
+				assert.Nil(err)
+				assert.NotEmpty(c.ID)
+				added = append(added, c)
+			}
+			pending, err := s.Pending()
+			assert.Nil(err)
+			assert.Equal(added, pending, "pending comments should be listed oldest first")
+
+			assert.Nil(s.Approve(added[1].ID))
+			assert.Nil(s.Approve(added[2].ID))
+			assert.ErrorIs(s.Approve("missing"), ErrNotFound)
+			assert.Nil(s.Delete(added[0].ID))
+			assert.ErrorIs(s.Delete(added[0].ID), ErrNotFound)
+
+			approved, err := s.Approved("first")
+			assert.Nil(err)
+			assert.Len(approved, 1)
+			assert.Equal(added[1].ID, approved[0].ID)
+			assert.Equal(StatusApproved, approved[0].Status)
+			counts, err := s.Counts()
+			assert.Nil(err)
+			assert.Equal(map[string]int{"first": 1, "second": 1}, counts)
+			assert.Nil(s.Close())
+
+			// the comments are kept
+			s, err = open(dir)
+			assert.Nil(err)
+			pending, err = s.Pending()
+			assert.Nil(err)
+			assert.Empty(pending)
+			approved, err = s.Approved("second")
+			assert.Nil(err)
+			assert.Len(approved, 1)
+			assert.True(start.Add(2 * time.Minute).Equal(approved[0].Time))
+			assert.Nil(s.Close())
+		})
+	}
+}
+
+// TestValidate tests the checks of new comments
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := Validate(Comment{Author: "  reader ", Body: "line\r\nnext\n"})
+	assert.Nil(err)
+	assert.Equal("reader", c.Author)
+	assert.Equal("line\nnext", c.Body)
+
+	_, err = Validate(Comment{Author: " ", Body: string(make([]byte, maxBodyLength+1))})
+	var validationError *ValidationError
+	assert.ErrorAs(err, &validationError)
+	assert.Equal(map[string]string{"author": "is required", "body": "must be at most 4000 characters"}, validationError.Fields)
+}
+
+// TestRender tests the markdown-lite rendering of comments
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("<p>Nice <em>post</em>, <strong>thanks</strong>!<br>Use <code>go vet</code>.</p><p>Second</p>",
+		Render("Nice *post*, **thanks**!\nUse `go vet`.\n\n \nSecond"))
+	assert.Equal(`<p>See <a href="https://example.com/a?b=1&amp;c=2" rel="nofollow ugc noopener">https://example.com/a?b=1&amp;c=2</a>.</p>`,
+		Render("See https://example.com/a?b=1&c=2."))
+	assert.Equal("<p>&lt;script&gt;alert(1)&lt;/script&gt; <code>&lt;b&gt;*x*&lt;/b&gt;</code></p>",
+		Render("<script>alert(1)</script> `<b>*x*</b>`"))
+	assert.Equal("<p>javascript:alert(1) &lt;a href=&#34;javascript:x&#34;&gt;</p>", Render(`javascript:alert(1) <a href="javascript:x">`))
+	assert.Equal(`<p><a href="http://example.com/*a*" rel="nofollow ugc noopener">http://example.com/*a*</a></p>`, Render("http://example.com/*a*"))
+	assert.Equal("", Render(" \n "))
+}
+
+// TestGuard tests the spam defenses of the comment forms
+func TestGuard(t *testing.T) {
+	assert := assert.New(t)
+
+	g, err := NewGuard(GuardOptions{Key: []byte("secret"), MinDelay: 3 * time.Second, MaxAge: time.Hour, Rate: 2, Window: 10 * time.Minute})
+	assert.Nil(err)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	token := g.Token("post")
+	submission := Submission{PostID: "post", IP: "192.0.2.1", Token: token}
+	assert.ErrorIs(g.Check(submission), ErrTooFast)
+	now = now.Add(5 * time.Second)
+	assert.ErrorIs(g.Check(Submission{PostID: "post", IP: "192.0.2.1", Token: token, Honeypot: "http://spam"}), ErrHoneypot)
+	assert.ErrorIs(g.Check(Submission{PostID: "other", IP: "192.0.2.1", Token: token}), ErrInvalidForm, "the token should be bound to the post")
+	assert.ErrorIs(g.Check(Submission{PostID: "post", IP: "192.0.2.1", Token: "1." + token}), ErrInvalidForm)
+	assert.ErrorIs(g.Check(Submission{PostID: "post", IP: "192.0.2.1"}), ErrInvalidForm)
+
+	assert.Nil(g.Check(submission))
+	assert.ErrorIs(g.Check(submission), ErrReusedForm, "a token should only be accepted once")
+	assert.ErrorIs(g.Check(Submission{PostID: "post", IP: "192.0.2.2", Token: token}), ErrReusedForm, "a token should not be accepted again from another address")
+
+	// every comment is sent with the token of a new form
+	send := func(ip string) error {
+		token := g.Token("post")
+		now = now.Add(5 * time.Second)
+		return g.Check(Submission{PostID: "post", IP: ip, Token: token})
+	}
+	assert.Nil(send("192.0.2.1"))
+	assert.ErrorIs(send("192.0.2.1"), ErrRateLimited)
+	assert.Nil(send("192.0.2.2"), "the limit should be per IP address")
+	now = now.Add(11 * time.Minute)
+	assert.Nil(send("192.0.2.1"), "the limit should be lifted after the window")
+	expired := Submission{PostID: "post", IP: "192.0.2.3", Token: g.Token("post")}
+	now = now.Add(2 * time.Hour)
+	assert.ErrorIs(g.Check(expired), ErrExpiredForm)
+	assert.Nil(send("192.0.2.3"))
+	assert.Len(g.used, 1, "the expired tokens should be forgotten")
+
+	other, err := NewGuard(GuardOptions{})
+	assert.Nil(err)
+	assert.ErrorIs(other.Check(submission), ErrInvalidForm, "the token should be bound to the key")
+}
@@ -0,0 +1,152 @@
+package comment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/kegliz/silent-blog/internal/fileutil"
+)
+
+// fileStore is a Store keeping the comments in a json file, loaded in memory.
+type fileStore struct {
+	fileName string
+	mu       sync.RWMutex
+	comments []Comment
+}
+
+// NewFileStore returns a Store keeping the comments in the json file fileName, created on the first comment.
+func NewFileStore(fileName string) (Store, error) {
+	s := &fileStore{fileName: fileName}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NewFileStore: cannot read comments : %v", err)
+	}
+	if err := json.Unmarshal(data, &s.comments); err != nil {
+		return nil, fmt.Errorf("NewFileStore: cannot decode comments : %v", err)
+	}
+	return s, nil
+}
+
+// Add implements Store.
+func (s *fileStore) Add(c Comment) (Comment, error) {
+	id, err := newID()
+	if err != nil {
+		return Comment{}, fmt.Errorf("fileStore::Add: cannot create ID : %v", err)
+	}
+	c.ID = id
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(append(s.comments, c)); err != nil {
+		return Comment{}, fmt.Errorf("fileStore::Add: %v", err)
+	}
+	return c, nil
+}
+
+// Approved implements Store.
+func (s *fileStore) Approved(postID string) ([]Comment, error) {
+	return s.filter(func(c Comment) bool {
+		return c.PostID == postID && c.Status == StatusApproved
+	}), nil
+}
+
+// Pending implements Store.
+func (s *fileStore) Pending() ([]Comment, error) {
+	return s.filter(func(c Comment) bool {
+		return c.Status == StatusPending
+	}), nil
+}
+
+// Approve implements Store.
+func (s *fileStore) Approve(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	comments := append([]Comment(nil), s.comments...)
+	comments[i].Status = StatusApproved
+	if err := s.save(comments); err != nil {
+		return fmt.Errorf("fileStore::Approve: %v", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *fileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	comments := append(append([]Comment(nil), s.comments[:i]...), s.comments[i+1:]...)
+	if err := s.save(comments); err != nil {
+		return fmt.Errorf("fileStore::Delete: %v", err)
+	}
+	return nil
+}
+
+// Counts implements Store.
+func (s *fileStore) Counts() (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := map[string]int{}
+	for _, c := range s.comments {
+		if c.Status == StatusApproved {
+			counts[c.PostID]++
+		}
+	}
+	return counts, nil
+}
+
+// Close implements Store.
+func (s *fileStore) Close() error {
+	return nil
+}
+
+// filter returns the comments matching keep, oldest first.
+func (s *fileStore) filter(keep func(Comment) bool) []Comment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var comments []Comment
+	for _, c := range s.comments {
+		if keep(c) {
+			comments = append(comments, c)
+		}
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Time.Before(comments[j].Time)
+	})
+	return comments
+}
+
+// index returns the position of the comment id, -1 if there is none.
+func (s *fileStore) index(id string) int {
+	for i, c := range s.comments {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the comments to the file and keeps them if the write succeeded.
+func (s *fileStore) save(comments []Comment) error {
+	data, err := json.MarshalIndent(comments, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode comments : %v", err)
+	}
+	if err := fileutil.WriteAtomic(s.fileName, append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write comments : %v", err)
+	}
+	s.comments = comments
+	return nil
+}
@@ -0,0 +1,183 @@
+package comment
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrHoneypot    = errors.New("the honeypot field was filled in")
+	ErrInvalidForm = errors.New("invalid comment form token")
+	ErrExpiredForm = errors.New("comment form expired")
+	ErrReusedForm  = errors.New("comment form sent already")
+	ErrTooFast     = errors.New("comment submitted too fast")
+	ErrRateLimited = errors.New("too many comments")
+)
+
+type (
+	// GuardOptions are the limits of a Guard.
+	GuardOptions struct {
+		// Key signs the form tokens, a random key is used if empty
+		Key []byte
+		// MinDelay is the shortest time between showing the form and submitting it
+		MinDelay time.Duration
+		// MaxAge is the longest time between showing the form and submitting it
+		MaxAge time.Duration
+		// Rate is the number of comments accepted from an IP address in Window, unlimited if 0
+		Rate   int
+		Window time.Duration
+	}
+
+	// Guard keeps spam away from the comments.
+	// Forms carry a signed token of the time they were shown, accepted only once, a hidden honeypot field left empty by humans,
+	// and each IP address can only comment a few times in a while.
+	Guard struct {
+		opts GuardOptions
+		// now is the clock of the tokens and of the rate limit
+		now func() time.Time
+
+		mu sync.Mutex
+		// recent holds the times of the recent comments by IP address
+		recent    map[string][]time.Time
+		lastSweep time.Time
+		// used holds the tokens of the accepted forms with the time they were shown, until they expire
+		used          map[string]time.Time
+		lastUsedSweep time.Time
+	}
+
+	// Submission is a submitted comment form as seen by the Guard.
+	Submission struct {
+		PostID   string
+		IP       string
+		Token    string
+		Honeypot string
+	}
+)
+
+// NewGuard returns a new Guard.
+func NewGuard(opts GuardOptions) (*Guard, error) {
+	if len(opts.Key) == 0 {
+		opts.Key = make([]byte, 32)
+		if _, err := rand.Read(opts.Key); err != nil {
+			return nil, err
+		}
+	}
+	return &Guard{opts: opts, now: time.Now, recent: map[string][]time.Time{}, used: map[string]time.Time{}}, nil
+}
+
+// Token returns the token of a comment form on the post postID shown now.
+// A random nonce tells apart the forms shown at the same time, as every token is only accepted once.
+func (g *Guard) Token(postID string) string {
+	issued := strconv.FormatInt(g.now().UnixMilli(), 10) + "." + nonce()
+	return issued + "." + base64.RawURLEncoding.EncodeToString(g.mac(postID, issued))
+}
+
+// Check returns the reason to reject a submission, nil if it is accepted.
+// Accepted submissions count against the rate limit of their IP address and use up their token.
+func (g *Guard) Check(s Submission) error {
+	if s.Honeypot != "" {
+		return ErrHoneypot
+	}
+	dot := strings.LastIndex(s.Token, ".")
+	if dot < 0 {
+		return ErrInvalidForm
+	}
+	issued, sig := s.Token[:dot], s.Token[dot+1:]
+	millis, _, ok := strings.Cut(issued, ".")
+	if !ok {
+		return ErrInvalidForm
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return ErrInvalidForm
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, g.mac(s.PostID, issued)) {
+		return ErrInvalidForm
+	}
+	now := g.now()
+	shown := time.UnixMilli(ms)
+	age := now.Sub(shown)
+	if age < g.opts.MinDelay {
+		return ErrTooFast
+	}
+	if g.opts.MaxAge > 0 && age > g.opts.MaxAge {
+		return ErrExpiredForm
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sweepUsed(now)
+	if _, ok := g.used[s.Token]; ok {
+		return ErrReusedForm
+	}
+	if err := g.allow(s.IP, now); err != nil {
+		return err
+	}
+	g.used[s.Token] = shown
+	return nil
+}
+
+// sweepUsed forgets the used tokens which have expired anyway, at most once in MaxAge.
+// Without MaxAge the tokens never expire and are all kept. The caller holds mu.
+func (g *Guard) sweepUsed(now time.Time) {
+	if g.opts.MaxAge <= 0 || now.Sub(g.lastUsedSweep) < g.opts.MaxAge {
+		return
+	}
+	for token, shown := range g.used {
+		if now.Sub(shown) > g.opts.MaxAge {
+			delete(g.used, token)
+		}
+	}
+	g.lastUsedSweep = now
+}
+
+// allow records a comment of ip at now, unless ip reached the rate limit. The caller holds mu.
+func (g *Guard) allow(ip string, now time.Time) error {
+	if g.opts.Rate <= 0 {
+		return nil
+	}
+	since := now.Add(-g.opts.Window)
+	if now.Sub(g.lastSweep) > g.opts.Window {
+		// forget the addresses which have not commented lately
+		for addr, times := range g.recent {
+			if len(times) == 0 || times[len(times)-1].Before(since) {
+				delete(g.recent, addr)
+			}
+		}
+		g.lastSweep = now
+	}
+	var recent []time.Time
+	for _, t := range g.recent[ip] {
+		if t.After(since) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= g.opts.Rate {
+		g.recent[ip] = recent
+		return ErrRateLimited
+	}
+	g.recent[ip] = append(recent, now)
+	return nil
+}
+
+// nonce returns a short random string.
+func nonce() string {
+	b := make([]byte, 9)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// mac computes the HMAC of a post ID and the time its form was shown with its nonce.
+func (g *Guard) mac(postID string, issued string) []byte {
+	m := hmac.New(sha256.New, g.opts.Key)
+	m.Write([]byte("comment\n" + postID + "\n" + issued))
+	return m.Sum(nil)
+}
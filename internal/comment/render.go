@@ -0,0 +1,70 @@
+package comment
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+	codeSpan       = regexp.MustCompile("`([^`\n]+)`")
+	autoLink       = regexp.MustCompile(`https?://[^\s<>"']+[^\s<>"'.,;:!?)]`)
+	strong         = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	emphasis       = regexp.MustCompile(`\*([^*\n]+)\*`)
+)
+
+// Render returns the HTML of a comment body written in markdown-lite:
+// paragraphs separated by blank lines, line breaks, *emphasis*, **strong**, `code` and http(s) links.
+// Everything else, HTML included, is shown as text, so the result is safe to embed in a page.
+func Render(body string) string {
+	body = strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if body == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, paragraph := range paragraphBreak.Split(body, -1) {
+		b.WriteString("<p>")
+		for i, line := range strings.Split(strings.TrimSpace(paragraph), "\n") {
+			if i > 0 {
+				b.WriteString("<br>")
+			}
+			b.WriteString(renderInline(line))
+		}
+		b.WriteString("</p>")
+	}
+	return b.String()
+}
+
+// renderInline renders the code spans of a line, the text between them gets links and emphasis.
+func renderInline(line string) string {
+	return replaceBetween(codeSpan, line, func(code []string) string {
+		return "<code>" + html.EscapeString(code[1]) + "</code>"
+	}, func(text string) string {
+		return replaceBetween(autoLink, text, func(link []string) string {
+			url := html.EscapeString(link[0])
+			return `<a href="` + url + `" rel="nofollow ugc noopener">` + url + `</a>`
+		}, func(text string) string {
+			text = html.EscapeString(text)
+			text = strong.ReplaceAllString(text, "<strong>$1</strong>")
+			return emphasis.ReplaceAllString(text, "<em>$1</em>")
+		})
+	})
+}
+
+// replaceBetween replaces the matches of re in s with match, and the text around them with between.
+func replaceBetween(re *regexp.Regexp, s string, match func([]string) string, between func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(between(s[last:m[0]]))
+		groups := make([]string, 0, len(m)/2)
+		for i := 0; i < len(m); i += 2 {
+			groups = append(groups, s[m[i]:m[i+1]])
+		}
+		b.WriteString(match(groups))
+		last = m[1]
+	}
+	b.WriteString(between(s[last:]))
+	return b.String()
+}
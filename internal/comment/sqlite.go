@@ -0,0 +1,139 @@
+package comment
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	// registers the "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the comments table, times are unix nanoseconds.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS comments (
+	id      TEXT PRIMARY KEY,
+	post_id TEXT NOT NULL,
+	author  TEXT NOT NULL,
+	body    TEXT NOT NULL,
+	time    INTEGER NOT NULL,
+	status  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS comments_post ON comments (post_id, status, time);
+`
+
+const commentColumns = `id, post_id, author, body, time, status`
+
+// sqlStore is a Store keeping the comments in a SQLite database.
+type sqlStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore returns a Store keeping the comments in the SQLite database fileName, creating its table if needed.
+func NewSQLiteStore(fileName string) (Store, error) {
+	db, err := sql.Open("sqlite", fileName+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("NewSQLiteStore: cannot open database : %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("NewSQLiteStore: cannot create tables : %v", err)
+	}
+	return &sqlStore{db: db}, nil
+}
+
+// Add implements Store.
+func (s *sqlStore) Add(c Comment) (Comment, error) {
+	id, err := newID()
+	if err != nil {
+		return Comment{}, fmt.Errorf("sqlStore::Add: cannot create ID : %v", err)
+	}
+	c.ID = id
+	_, err = s.db.Exec(`INSERT INTO comments (`+commentColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.ID, c.PostID, c.Author, c.Body, c.Time.UnixNano(), string(c.Status))
+	if err != nil {
+		return Comment{}, fmt.Errorf("sqlStore::Add: cannot insert comment : %v", err)
+	}
+	return c, nil
+}
+
+// Approved implements Store.
+func (s *sqlStore) Approved(postID string) ([]Comment, error) {
+	return s.query(`SELECT `+commentColumns+` FROM comments WHERE post_id = ? AND status = ? ORDER BY time`, postID, string(StatusApproved))
+}
+
+// Pending implements Store.
+func (s *sqlStore) Pending() ([]Comment, error) {
+	return s.query(`SELECT `+commentColumns+` FROM comments WHERE status = ? ORDER BY time`, string(StatusPending))
+}
+
+// Approve implements Store.
+func (s *sqlStore) Approve(id string) error {
+	return s.exec("Approve", `UPDATE comments SET status = ? WHERE id = ?`, string(StatusApproved), id)
+}
+
+// Delete implements Store.
+func (s *sqlStore) Delete(id string) error {
+	return s.exec("Delete", `DELETE FROM comments WHERE id = ?`, id)
+}
+
+// Counts implements Store.
+func (s *sqlStore) Counts() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT post_id, COUNT(*) FROM comments WHERE status = ? GROUP BY post_id`, string(StatusApproved))
+	if err != nil {
+		return nil, fmt.Errorf("sqlStore::Counts: cannot query counts : %v", err)
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var postID string
+		var n int
+		if err := rows.Scan(&postID, &n); err != nil {
+			return nil, fmt.Errorf("sqlStore::Counts: cannot read count : %v", err)
+		}
+		counts[postID] = n
+	}
+	return counts, rows.Err()
+}
+
+// Close implements Store.
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// exec runs a statement changing one comment, it returns ErrNotFound if no comment was changed.
+func (s *sqlStore) exec(method string, query string, args ...interface{}) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("sqlStore::%s: %v", method, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlStore::%s: %v", method, err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// query returns the comments selected by a query on commentColumns.
+func (s *sqlStore) query(query string, args ...interface{}) ([]Comment, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlStore: cannot query comments : %v", err)
+	}
+	defer rows.Close()
+	var comments []Comment
+	for rows.Next() {
+		var c Comment
+		var t int64
+		var status string
+		if err := rows.Scan(&c.ID, &c.PostID, &c.Author, &c.Body, &t, &status); err != nil {
+			return nil, fmt.Errorf("sqlStore: cannot read comment : %v", err)
+		}
+		c.Time, c.Status = time.Unix(0, t).UTC(), Status(status)
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
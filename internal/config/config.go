@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -51,3 +53,14 @@ func (conf *Config) readFullConfig() error {
 	}
 	return nil
 }
+
+// BaseURL returns the public URL of the server, site.url or else the URL of the domain.
+func (conf *Config) BaseURL() string {
+	if url := conf.GetString("site.url"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	if conf.GetBool("tls") {
+		return "https://" + conf.GetString("domain")
+	}
+	return fmt.Sprintf("http://%s:%d", conf.GetString("domain"), conf.GetInt("port"))
+}
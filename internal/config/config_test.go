@@ -60,3 +60,17 @@ func TestNewConfig(t *testing.T) {
 	assert.Equal(standuppers[0], "TestBela")
 	os.Setenv("BELA_BACSI", "susu")
 }
+
+func TestBaseURL(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := NewNakedConfig()
+	conf.Set("domain", "localhost")
+	conf.Set("port", 3000)
+	assert.Equal("http://localhost:3000", conf.BaseURL())
+	conf.Set("tls", true)
+	conf.Set("domain", "blog.example")
+	assert.Equal("https://blog.example", conf.BaseURL())
+	conf.Set("site.url", "https://www.blog.example/")
+	assert.Equal("https://www.blog.example", conf.BaseURL())
+}
@@ -13,6 +13,7 @@ var (
 	stringType configVarType = "string"
 	intType    configVarType = "int"
 	boolType   configVarType = "bool"
+	listType   configVarType = "list"
 )
 
 var configVars = map[string]configVar{
@@ -31,6 +32,11 @@ var configVars = map[string]configVar{
 		Default: false,
 		EnvVar:  "DEBUG",
 	},
+	"trustedproxies": {
+		Type:    listType,
+		Default: []string{},
+		EnvVar:  "TRUSTEDPROXIES",
+	},
 	"localonly": {
 		Type:    boolType,
 		Default: true,
@@ -56,4 +62,199 @@ var configVars = map[string]configVar{
 		Default: "md",
 		EnvVar:  "POSTS_MDDIR",
 	},
+	"render.cachesize": {
+		Type:    intType,
+		Default: 128,
+		EnvVar:  "RENDER_CACHESIZE",
+	},
+	"posts.pagesize": {
+		Type:    intType,
+		Default: 10,
+		EnvVar:  "POSTS_PAGESIZE",
+	},
+	"posts.tocdepth": {
+		Type:    intType,
+		Default: 2,
+		EnvVar:  "POSTS_TOCDEPTH",
+	},
+	"posts.showdrafts": {
+		Type:    boolType,
+		Default: false,
+		EnvVar:  "POSTS_SHOWDRAFTS",
+	},
+	"preview.key": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "PREVIEW_KEY",
+	},
+	"preview.ttl": {
+		Type:    stringType,
+		Default: "72h",
+		EnvVar:  "PREVIEW_TTL",
+	},
+	"posts.watch": {
+		Type:    boolType,
+		Default: true,
+		EnvVar:  "POSTS_WATCH",
+	},
+	"site.url": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "SITE_URL",
+	},
+	"site.title": {
+		Type:    stringType,
+		Default: "Silent Blog",
+		EnvVar:  "SITE_TITLE",
+	},
+	"site.lang": {
+		Type:    stringType,
+		Default: "en",
+		EnvVar:  "SITE_LANG",
+	},
+	"site.timezone": {
+		Type:    stringType,
+		Default: "UTC",
+		EnvVar:  "SITE_TIMEZONE",
+	},
+	"site.dateformat": {
+		Type:    stringType,
+		Default: "2006-01-02",
+		EnvVar:  "SITE_DATEFORMAT",
+	},
+	"site.relativedates": {
+		Type:    boolType,
+		Default: false,
+		EnvVar:  "SITE_RELATIVEDATES",
+	},
+	"authors.file": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "AUTHORS_FILE",
+	},
+	"projects.file": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "PROJECTS_FILE",
+	},
+	"posts.storage": {
+		Type:    stringType,
+		Default: "filesystem",
+		EnvVar:  "POSTS_STORAGE",
+	},
+	"posts.archive": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "POSTS_ARCHIVE",
+	},
+	"posts.backend": {
+		Type:    stringType,
+		Default: "files",
+		EnvVar:  "POSTS_BACKEND",
+	},
+	"posts.database": {
+		Type:    stringType,
+		Default: "posts.db",
+		EnvVar:  "POSTS_DATABASE",
+	},
+	"posts.revisions": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "POSTS_REVISIONS",
+	},
+	"admin.token": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "ADMIN_TOKEN",
+	},
+	"admin.user": {
+		Type:    stringType,
+		Default: "admin",
+		EnvVar:  "ADMIN_USER",
+	},
+	"admin.passwordhash": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "ADMIN_PASSWORDHASH",
+	},
+	"admin.sessionkey": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "ADMIN_SESSIONKEY",
+	},
+	"admin.sessionttl": {
+		Type:    stringType,
+		Default: "12h",
+		EnvVar:  "ADMIN_SESSIONTTL",
+	},
+	"comments.enabled": {
+		Type:    boolType,
+		Default: true,
+		EnvVar:  "COMMENTS_ENABLED",
+	},
+	"comments.store": {
+		Type:    stringType,
+		Default: "file",
+		EnvVar:  "COMMENTS_STORE",
+	},
+	"comments.file": {
+		Type:    stringType,
+		Default: "comments.json",
+		EnvVar:  "COMMENTS_FILE",
+	},
+	"comments.database": {
+		Type:    stringType,
+		Default: "comments.db",
+		EnvVar:  "COMMENTS_DATABASE",
+	},
+	"comments.key": {
+		Type:    stringType,
+		Default: "",
+		EnvVar:  "COMMENTS_KEY",
+	},
+	"comments.mindelay": {
+		Type:    stringType,
+		Default: "3s",
+		EnvVar:  "COMMENTS_MINDELAY",
+	},
+	"comments.maxage": {
+		Type:    stringType,
+		Default: "24h",
+		EnvVar:  "COMMENTS_MAXAGE",
+	},
+	"comments.ratelimit": {
+		Type:    intType,
+		Default: 5,
+		EnvVar:  "COMMENTS_RATELIMIT",
+	},
+	"comments.ratewindow": {
+		Type:    stringType,
+		Default: "10m",
+		EnvVar:  "COMMENTS_RATEWINDOW",
+	},
+	"webmention.enabled": {
+		Type:    boolType,
+		Default: true,
+		EnvVar:  "WEBMENTION_ENABLED",
+	},
+	"webmention.file": {
+		Type:    stringType,
+		Default: "webmentions.json",
+		EnvVar:  "WEBMENTION_FILE",
+	},
+	"webmention.send": {
+		Type:    boolType,
+		Default: true,
+		EnvVar:  "WEBMENTION_SEND",
+	},
+	"webmention.sentfile": {
+		Type:    stringType,
+		Default: "webmentions-sent.json",
+		EnvVar:  "WEBMENTION_SENTFILE",
+	},
+	"webmention.allowprivate": {
+		Type:    boolType,
+		Default: false,
+		EnvVar:  "WEBMENTION_ALLOWPRIVATE",
+	},
 }
@@ -0,0 +1,102 @@
+// Package diff computes line-level differences between two texts.
+package diff
+
+import "strings"
+
+const (
+	// Equal lines are in both texts.
+	Equal Op = iota
+	// Delete lines are only in the first text.
+	Delete
+	// Insert lines are only in the second text.
+	Insert
+)
+
+type (
+	// Op is the kind of a line of a diff.
+	Op int
+
+	// Line is a line of a diff.
+	Line struct {
+		Op   Op
+		Text string
+	}
+)
+
+// Lines returns the shortest edit turning a into b, line by line, with the unchanged lines in between.
+// It uses the Myers algorithm, which is fast for similar texts like two versions of a post.
+func Lines(a string, b string) []Line {
+	x, y := splitLines(a), splitLines(b)
+	n, m := len(x), len(y)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k] is the furthest x reached on diagonal k, trace keeps v after every step for the backtrack
+	offset := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				return backtrack(x, y, trace, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace of Lines back from the end of both texts and returns the edit in order.
+func backtrack(x []string, y []string, trace [][]int, offset int) []Line {
+	i, j := len(x), len(y)
+	var lines []Line
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := v[offset+prevK]
+		prevJ := prevI - prevK
+		for i > prevI && j > prevJ {
+			i, j = i-1, j-1
+			lines = append(lines, Line{Op: Equal, Text: x[i]})
+		}
+		if d > 0 {
+			if i == prevI {
+				lines = append(lines, Line{Op: Insert, Text: y[prevJ]})
+			} else {
+				lines = append(lines, Line{Op: Delete, Text: x[prevI]})
+			}
+		}
+		i, j = prevI, prevJ
+	}
+	for l, r := 0, len(lines)-1; l < r; l, r = l+1, r-1 {
+		lines[l], lines[r] = lines[r], lines[l]
+	}
+	return lines
+}
+
+// splitLines splits a text into lines, a final newline does not start an empty line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
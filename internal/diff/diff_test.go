@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// apply rebuilds both texts from a diff.
+func apply(lines []Line) (string, string) {
+	var a, b []string
+	for _, l := range lines {
+		if l.Op != Insert {
+			a = append(a, l.Text)
+		}
+		if l.Op != Delete {
+			b = append(b, l.Text)
+		}
+	}
+	return strings.Join(a, "\n"), strings.Join(b, "\n")
+}
+
+// TestLines tests line diffs of edited texts
+func TestLines(t *testing.T) {
+	assert := assert.New(t)
+
+	lines := Lines("title: Old\ndate: 2024-01-01\n---\nfirst\nsecond\n", "title: New\ndate: 2024-01-01\n---\nfirst\nsecond\nthird\n")
+	assert.Equal([]Line{
+		{Op: Delete, Text: "title: Old"},
+		{Op: Insert, Text: "title: New"},
+		{Op: Equal, Text: "date: 2024-01-01"},
+		{Op: Equal, Text: "---"},
+		{Op: Equal, Text: "first"},
+		{Op: Equal, Text: "second"},
+		{Op: Insert, Text: "third"},
+	}, lines)
+
+	assert.Nil(Lines("", ""))
+	assert.Equal([]Line{{Op: Insert, Text: "new"}}, Lines("", "new"))
+	assert.Equal([]Line{{Op: Delete, Text: "old"}}, Lines("old", ""))
+	assert.Equal([]Line{{Op: Equal, Text: "same"}}, Lines("same\n", "same"))
+
+	for _, texts := range [][2]string{
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc"},
+		{"one\ntwo\nthree", "three\ntwo\none"},
+		{"x\ny", "p\nq\nr\ns"},
+	} {
+		lines := Lines(texts[0], texts[1])
+		a, b := apply(lines)
+		assert.Equal(texts[0], a)
+		assert.Equal(texts[1], b)
+	}
+	changes := 0
+	for _, l := range Lines("a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc") {
+		if l.Op != Equal {
+			changes++
+		}
+	}
+	assert.Equal(5, changes, "the edit should be the shortest")
+}
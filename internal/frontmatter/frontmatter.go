@@ -0,0 +1,229 @@
+package frontmatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Format is the syntax of a front matter block.
+	Format string
+
+	// Matter holds the decoded key/value pairs of a front matter block.
+	Matter map[string]interface{}
+)
+
+const (
+	// FormatNone is returned when the document has no front matter.
+	FormatNone Format = ""
+	// FormatYAML is front matter delimited by "---" lines.
+	FormatYAML Format = "yaml"
+	// FormatTOML is front matter delimited by "+++" lines.
+	FormatTOML Format = "toml"
+)
+
+var ErrUnterminated = errors.New("front matter is not terminated")
+
+// localDateTime is the layout of the datetimes written without a zone.
+const localDateTime = "2006-01-02T15:04:05"
+
+var delimiters = map[Format]string{
+	FormatYAML: "---",
+	FormatTOML: "+++",
+}
+
+// Split separates the front matter block from the body of a markdown document.
+// If the document has no front matter, the whole input is returned as body.
+func Split(src []byte) (Format, []byte, []byte, error) {
+	for format, delim := range delimiters {
+		if !hasDelimiterLine(src, delim) {
+			continue
+		}
+		rest := src[lineEnd(src):]
+		for offset := 0; offset < len(rest); {
+			end := offset + lineEnd(rest[offset:])
+			if strings.TrimRight(string(rest[offset:end]), "\r\n") == delim {
+				return format, rest[:offset], rest[end:], nil
+			}
+			offset = end
+		}
+		return format, nil, nil, ErrUnterminated
+	}
+	return FormatNone, nil, src, nil
+}
+
+// Strip returns the body of a markdown document without its front matter.
+func Strip(src []byte) []byte {
+	_, _, body, err := Split(src)
+	if err != nil {
+		return src
+	}
+	return body
+}
+
+// Parse splits the document and decodes its front matter.
+// The returned Matter is nil if the document has no front matter.
+func Parse(src []byte) (Matter, []byte, error) {
+	format, meta, body, err := Split(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == FormatNone {
+		return nil, body, nil
+	}
+	m := Matter{}
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(meta, &m)
+	case FormatTOML:
+		err = toml.Unmarshal(meta, &m)
+	}
+	if err == nil && format == FormatYAML {
+		err = keepLocalTimes(meta, m)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("frontmatter: cannot decode %s: %v", format, err)
+	}
+	return m, body, nil
+}
+
+// keepLocalTimes replaces the YAML timestamps written without a zone, which the parser decodes in UTC,
+// by their text without a zone, so that they are read in the site timezone like the local datetimes of TOML.
+func keepLocalTimes(meta []byte, m Matter) error {
+	hasTimes := false
+	for _, v := range m {
+		if _, ok := v.(time.Time); ok {
+			hasTimes = true
+		}
+	}
+	if !hasTimes {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(meta, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	pairs := doc.Content[0].Content
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key, text := pairs[i].Value, pairs[i+1].Value
+		t, ok := m[key].(time.Time)
+		if !ok || len(text) <= len(time.DateOnly) || strings.ContainsAny(text[len(time.DateOnly):], "Zz+-") {
+			continue
+		}
+		m[key] = t.Format(localDateTime)
+	}
+	return nil
+}
+
+// String returns the value of key as a string.
+// Dates decoded by the YAML or TOML parsers are formatted back to their textual form.
+func (m Matter) String(key string) string {
+	switch v := m[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Int returns the value of key as an int, 0 if it is missing or not a number.
+func (m Matter) Int(key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	default:
+		return 0
+	}
+}
+
+// Matter returns the value of key as a nested Matter, nil if it is not a table.
+func (m Matter) Matter(key string) Matter {
+	switch v := m[key].(type) {
+	case map[string]interface{}:
+		return Matter(v)
+	case Matter:
+		return v
+	default:
+		return nil
+	}
+}
+
+// Bool returns the value of key as a bool, false if it is missing or not a boolean.
+func (m Matter) Bool(key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	default:
+		return false
+	}
+}
+
+// Strings returns the value of key as a list of strings.
+// A single string value is split on commas.
+func (m Matter) Strings(key string) []string {
+	switch v := m[key].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				result = append(result, s)
+			}
+		}
+		return result
+	case []string:
+		return v
+	default:
+		var result []string
+		for _, item := range strings.Split(m.String(key), ",") {
+			if s := strings.TrimSpace(item); s != "" {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+}
+
+// hasDelimiterLine reports whether src starts with a line that is exactly delim.
+func hasDelimiterLine(src []byte, delim string) bool {
+	return bytes.HasPrefix(src, []byte(delim)) &&
+		strings.TrimRight(string(src[:lineEnd(src)]), "\r\n") == delim
+}
+
+// lineEnd returns the index just after the first newline in src, or len(src).
+func lineEnd(src []byte) int {
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		return i + 1
+	}
+	return len(src)
+}
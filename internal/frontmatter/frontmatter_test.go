@@ -0,0 +1,68 @@
+package frontmatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseYAML tests parsing a document with YAML front matter
+func TestParseYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	matter, body, err := Parse([]byte("---\ntitle: Hello\ntags: [a, b]\ndate: 2023-12-20\n---\n# Body\n"))
+	assert.Nil(err)
+	assert.Equal("Hello", matter.String("title"))
+	assert.Equal([]string{"a", "b"}, matter.Strings("tags"))
+	assert.Equal("2023-12-20", matter.String("date"))
+	assert.Equal("# Body\n", string(body))
+}
+
+// TestParseTOML tests parsing a document with TOML front matter
+func TestParseTOML(t *testing.T) {
+	assert := assert.New(t)
+
+	matter, body, err := Parse([]byte("+++\ntitle = \"Hello\"\ntags = \"a, b\"\ndate = 2023-12-20\n+++\n# Body\n"))
+	assert.Nil(err)
+	assert.Equal("Hello", matter.String("title"))
+	assert.Equal([]string{"a", "b"}, matter.Strings("tags"))
+	assert.Equal("2023-12-20", matter.String("date"))
+	assert.Equal("# Body\n", string(body))
+}
+
+// TestParseLocalDateTimes tests that datetimes without a zone are kept without a zone
+func TestParseLocalDateTimes(t *testing.T) {
+	assert := assert.New(t)
+
+	matter, _, err := Parse([]byte("---\ndate: 2024-03-01 10:00:00\npublishAt: 2024-03-01T12:30:00\nzoned: 2024-03-01T10:00:00Z\noffset: 2024-03-01T10:00:00+02:00\n---\n"))
+	assert.Nil(err)
+	assert.Equal("2024-03-01T10:00:00", matter.String("date"))
+	assert.Equal("2024-03-01T12:30:00", matter.String("publishAt"))
+	assert.Equal("2024-03-01T10:00:00Z", matter.String("zoned"))
+	assert.Equal("2024-03-01T10:00:00+02:00", matter.String("offset"))
+
+	matter, _, err = Parse([]byte("+++\ndate = 2024-03-01T10:00:00\nzoned = 2024-03-01T10:00:00Z\n+++\n"))
+	assert.Nil(err)
+	assert.Equal("2024-03-01T10:00:00", matter.String("date"))
+	assert.Equal("2024-03-01T10:00:00Z", matter.String("zoned"))
+}
+
+// TestParseWithoutFrontMatter tests that documents without front matter are returned unchanged
+func TestParseWithoutFrontMatter(t *testing.T) {
+	assert := assert.New(t)
+
+	src := []byte("# Body\n\n---\n")
+	matter, body, err := Parse(src)
+	assert.Nil(err)
+	assert.Nil(matter)
+	assert.Equal(src, body)
+	assert.Equal(src, Strip(src))
+}
+
+// TestParseUnterminated tests that a front matter without closing delimiter is an error
+func TestParseUnterminated(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := Parse([]byte("---\ntitle: Hello\n# Body\n"))
+	assert.ErrorIs(err, ErrUnterminated)
+}
@@ -0,0 +1,109 @@
+package post
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// dateLayouts are the accepted layouts of the date and publishAt fields.
+// Times without a zone are taken in the site timezone.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+}
+
+// jsonPost is the json form of a post, with the dates kept as text.
+// Draft is nil unless the entry sets it, so that an explicit false can be told from a missing flag.
+type jsonPost struct {
+	postFields
+	Date      string `json:"date"`
+	PublishAt string `json:"publishAt,omitempty"`
+	Draft     *bool  `json:"draft,omitempty"`
+}
+
+// postFields has the fields of Post without its methods, so that jsonPost can embed it.
+type postFields Post
+
+// parseDate parses a date in any of the dateLayouts, in loc if it has no zone.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// formatDate returns the textual form of a date, without the time if it is midnight in loc,
+// the timezone the dates without a time are parsed in. The zero time is formatted as an empty string.
+func formatDate(t time.Time, loc *time.Location) string {
+	local := t.In(loc)
+	switch {
+	case t.IsZero():
+		return ""
+	case local.Hour() == 0 && local.Minute() == 0 && local.Second() == 0 && local.Nanosecond() == 0:
+		return local.Format(time.DateOnly)
+	default:
+		return t.Format(time.RFC3339)
+	}
+}
+
+// post converts the json form to a post, parsing its dates in loc.
+func (jp jsonPost) post(loc *time.Location) (Post, error) {
+	p := Post(jp.postFields)
+	p.Date, p.PublishAt, p.Draft = time.Time{}, nil, jp.Draft != nil && *jp.Draft
+	if jp.Date != "" {
+		date, err := parseDate(jp.Date, loc)
+		if err != nil {
+			return Post{}, fmt.Errorf("post %q: %v", jp.ID, err)
+		}
+		p.Date = date
+	}
+	if jp.PublishAt != "" {
+		publishAt, err := parseDate(jp.PublishAt, loc)
+		if err != nil {
+			return Post{}, fmt.Errorf("post %q: publishAt: %v", jp.ID, err)
+		}
+		p.PublishAt = &publishAt
+	}
+	return p, nil
+}
+
+// newJsonPost returns the json form of a post, its date without the time if it is midnight in loc.
+func newJsonPost(p Post, loc *time.Location) jsonPost {
+	jp := jsonPost{postFields: postFields(p), Date: formatDate(p.Date, loc)}
+	if p.Draft {
+		jp.Draft = &p.Draft
+	}
+	if p.PublishAt != nil {
+		jp.PublishAt = p.PublishAt.Format(time.RFC3339)
+	}
+	return jp
+}
+
+// MarshalJSON implements json.Marshaler, writing the dates in their textual form as UTC like UnmarshalJSON reads them.
+func (p Post) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJsonPost(p, time.UTC))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, dates without a zone are taken as UTC.
+func (p *Post) UnmarshalJSON(data []byte) error {
+	var jp jsonPost
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	post, err := jp.post(time.UTC)
+	if err != nil {
+		return err
+	}
+	*p = post
+	return nil
+}
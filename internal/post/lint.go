@@ -0,0 +1,182 @@
+package post
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/render"
+)
+
+type (
+	// LintOptions are the content sources checked by Lint, as in ServiceOptions.
+	LintOptions struct {
+		Storage  Storage
+		FileName string
+		MdDir    string
+		Location *time.Location
+		Authors  *author.Directory
+	}
+
+	// Issue is a problem of the content tree found by Lint.
+	Issue struct {
+		FileName string
+		ID       string
+		Message  string
+	}
+)
+
+// postLink matches the destination of a link to a post page, with or without a language prefix.
+var postLink = regexp.MustCompile(`^/?(?:[A-Za-z]{2,3}(?:-[A-Za-z0-9]+)*/)?post/([^/]+)/?$`)
+
+// Lint loads the content the way NewService does and reports every problem it finds
+// instead of stopping at the first one. It only returns an error if the markdown
+// directory cannot be walked.
+func Lint(opts LintOptions) ([]Issue, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	storage := opts.Storage
+	if storage == nil {
+		storage = NewLocalStorage()
+	}
+	var issues []Issue
+	report := func(fileName, id, format string, args ...interface{}) {
+		issues = append(issues, Issue{FileName: fileName, ID: id, Message: fmt.Sprintf(format, args...)})
+	}
+
+	opts.MdDir = cleanMdDir(opts.MdDir)
+	var mdPosts []Post
+	withoutFrontMatter := make(map[string]bool)
+	if opts.MdDir != "" {
+		files, err := mdFiles(storage, opts.MdDir)
+		if err != nil {
+			return nil, fmt.Errorf("Lint: cannot walk %s: %v", opts.MdDir, err)
+		}
+		mdIDs := make(map[string]string)
+		for _, fileName := range files {
+			p, ok, err := readPostFromMdFile(storage, fileName, loc)
+			switch {
+			case err != nil:
+				report(fileName, "", "%v", err)
+			case !ok:
+				withoutFrontMatter[fileName] = true
+			case mdIDs[p.ID] != "":
+				report(fileName, p.ID, "duplicate id, also used by %s", mdIDs[p.ID])
+			default:
+				mdIDs[p.ID] = fileName
+				mdPosts = append(mdPosts, p)
+			}
+		}
+	}
+
+	var jsonPosts []Post
+	var jsonDrafts map[string]bool
+	if opts.FileName != "" {
+		entries, err := readJsonPosts(storage, opts.FileName)
+		if err != nil {
+			report(opts.FileName, "", "%v", err)
+		}
+		jsonIDs := make(map[string]bool)
+		jsonDrafts = make(map[string]bool)
+		for i, entry := range entries {
+			p, err := entry.post(loc)
+			switch {
+			case entry.ID == "":
+				report(opts.FileName, "", "entry #%d has an empty id", i+1)
+			case jsonIDs[entry.ID]:
+				report(opts.FileName, entry.ID, "duplicate id")
+			case err != nil:
+				report(opts.FileName, "", "%v", err)
+			default:
+				jsonIDs[entry.ID] = true
+				jsonDrafts[entry.ID] = entry.Draft != nil
+				p.FileName = resolveFileName(p.FileName, opts.MdDir)
+				jsonPosts = append(jsonPosts, p)
+			}
+		}
+	}
+
+	store, _ := mergePosts(mdPosts, jsonPosts, jsonDrafts, loc)
+	for _, p := range store {
+		delete(withoutFrontMatter, p.FileName)
+	}
+	for fileName := range withoutFrontMatter {
+		report(fileName, "", "no front matter and not listed in the posts file")
+	}
+
+	for _, p := range store {
+		if p.Title == "" {
+			report(p.FileName, p.ID, "empty title")
+		}
+		if p.Lang != "" {
+			if _, err := parseLang(p.Lang); err != nil {
+				report(p.FileName, p.ID, "%v", err)
+			}
+		}
+		for _, id := range p.Authors {
+			if _, ok := opts.Authors.Get(id); !ok {
+				report(p.FileName, p.ID, "unknown author %s", id)
+			}
+		}
+		if p.FileName == "" {
+			continue
+		}
+		src, err := fs.ReadFile(storage, p.FileName)
+		if err != nil {
+			report(p.FileName, p.ID, "cannot read markdown file: %v", err)
+			continue
+		}
+		refs := render.FindReferences(src)
+		for _, image := range refs.Images {
+			if image.Alt == "" {
+				report(p.FileName, p.ID, "image %s has no alt text", image.Destination)
+			}
+		}
+		for _, link := range refs.Links {
+			if id, ok := linkedPostID(link); ok {
+				if _, found := store[id]; !found {
+					report(p.FileName, p.ID, "link to unknown post %s", id)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].FileName != issues[j].FileName {
+			return issues[i].FileName < issues[j].FileName
+		}
+		return issues[i].ID < issues[j].ID
+	})
+	return issues, nil
+}
+
+// linkedPostID returns the ID of the post a link points to, if it is a link to a post page of the site.
+func linkedPostID(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	m := postLink.FindStringSubmatch(u.Path)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// String implements the fmt.Stringer interface.
+func (i Issue) String() string {
+	switch {
+	case i.FileName == "":
+		return fmt.Sprintf("post %s: %s", i.ID, i.Message)
+	case i.ID == "":
+		return fmt.Sprintf("%s: %s", i.FileName, i.Message)
+	default:
+		return fmt.Sprintf("%s: post %s: %s", i.FileName, i.ID, i.Message)
+	}
+}
@@ -0,0 +1,372 @@
+package post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/frontmatter"
+	"golang.org/x/text/language"
+)
+
+// frontMatterKeys are the front matter fields that map to dedicated Post fields.
+var frontMatterKeys = []string{"id", "title", "tags", "authors", "lang", "translationKey", "date", "content", "draft", "publishAt", "series", "part"}
+
+// loadPosts builds a store from the markdown directory and the optional json file.
+// Entries of the json file override the front matter of the markdown file they describe,
+// every disagreement between the two sources is returned as a Conflict.
+// Dates without a zone are parsed in loc, every post must be written by authors.
+func loadPosts(storage Storage, fileName string, mdDir string, loc *time.Location, authors *author.Directory) (map[string]Post, []Conflict, error) {
+	var mdPosts, jsonPosts []Post
+	var err error
+	mdDir = cleanMdDir(mdDir)
+	if mdDir != "" {
+		if mdPosts, err = readPostsFromMdDir(storage, mdDir, loc); err != nil {
+			return nil, nil, fmt.Errorf("cannot init posts from markdown: %v", err)
+		}
+	}
+	var jsonDrafts map[string]bool
+	if fileName != "" {
+		if jsonPosts, jsonDrafts, err = readPostsFromJson(storage, fileName, mdDir, loc); err != nil {
+			return nil, nil, fmt.Errorf("cannot init posts from json: %v", err)
+		}
+	}
+	store, conflicts := mergePosts(mdPosts, jsonPosts, jsonDrafts, loc)
+	ids := make([]string, 0, len(store))
+	for id := range store {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if store[id], err = validatePost(store[id], authors); err != nil {
+			return nil, nil, err
+		}
+	}
+	return store, conflicts, nil
+}
+
+// validatePost returns the post with its language in canonical form.
+// It returns an error if the post names an author missing from authors or has an invalid language.
+func validatePost(p Post, authors *author.Directory) (Post, error) {
+	if id, found := authors.Unknown(p.Authors); found {
+		return Post{}, fmt.Errorf("post %q: unknown author %q", p.ID, id)
+	}
+	if p.Lang != "" {
+		lang, err := parseLang(p.Lang)
+		if err != nil {
+			return Post{}, fmt.Errorf("post %q: %v", p.ID, err)
+		}
+		p.Lang = lang
+	}
+	return p, nil
+}
+
+// parseLang returns the canonical form of a BCP 47 language tag, like en-US for en_us.
+func parseLang(lang string) (string, error) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "", fmt.Errorf("invalid lang %q", lang)
+	}
+	return tag.String(), nil
+}
+
+// readPostsFromJson reads the posts described in a json file, with the IDs of the entries setting draft, even to false.
+// File names are resolved relative to mdDir, dates without a zone are parsed in loc.
+func readPostsFromJson(storage Storage, fileName string, mdDir string, loc *time.Location) ([]Post, map[string]bool, error) {
+	jsonPosts, err := readJsonPosts(storage, fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	posts := make([]Post, len(jsonPosts))
+	drafts := make(map[string]bool)
+	for i, jp := range jsonPosts {
+		if posts[i], err = jp.post(loc); err != nil {
+			return nil, nil, fmt.Errorf("readPostsFromJson: %v", err)
+		}
+		posts[i].FileName = resolveFileName(posts[i].FileName, mdDir)
+		if jp.Draft != nil {
+			drafts[jp.ID] = true
+		}
+	}
+	return posts, drafts, nil
+}
+
+// readJsonPosts decodes the entries of a json file, leaving their dates unparsed.
+func readJsonPosts(storage Storage, fileName string) ([]jsonPost, error) {
+	file, err := storage.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("readPostsFromJson: cannot open file : %v", err)
+	}
+	defer file.Close()
+
+	var jsonPosts []jsonPost
+	if err = json.NewDecoder(file).Decode(&jsonPosts); err != nil {
+		return nil, fmt.Errorf("readPostsFromJson: cannot unmarshal json file : %v", err)
+	}
+	return jsonPosts, nil
+}
+
+// resolveFileName returns the path of a markdown file named in posts.json.
+// The path is cleaned like the paths found by mdFiles, so that both name the same file alike.
+func resolveFileName(fileName string, mdDir string) string {
+	if fileName != "" && mdDir != "" {
+		return path.Join(mdDir, fileName)
+	}
+	return fileName
+}
+
+// cleanMdDir returns mdDir without "./" prefix or trailing slash, an unset mdDir stays empty.
+func cleanMdDir(mdDir string) string {
+	if mdDir == "" {
+		return ""
+	}
+	return path.Clean(mdDir)
+}
+
+// readPostsFromMdDir walks mdDir and builds a post from every markdown file that has front matter.
+// Files without front matter are left to be described by the json file.
+// A missing directory yields no posts, two files with the same ID are an error.
+func readPostsFromMdDir(storage Storage, mdDir string, loc *time.Location) ([]Post, error) {
+	files, err := mdFiles(storage, mdDir)
+	if err != nil {
+		return nil, err
+	}
+	var posts []Post
+	fileByID := make(map[string]string)
+	for _, fileName := range files {
+		p, ok, err := readPostFromMdFile(storage, fileName, loc)
+		if err != nil {
+			return nil, fmt.Errorf("readPostsFromMdDir: %s: %v", fileName, err)
+		}
+		if !ok {
+			continue
+		}
+		if other, found := fileByID[p.ID]; found {
+			return nil, fmt.Errorf("readPostsFromMdDir: %s: post %q: duplicate id, also used by %s", fileName, p.ID, other)
+		}
+		fileByID[p.ID] = fileName
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
+
+// mdFiles returns the paths of the markdown files under mdDir, prefixed with mdDir.
+// A missing directory has no files.
+func mdFiles(storage Storage, mdDir string) ([]string, error) {
+	mdDir = cleanMdDir(mdDir)
+	if _, err := fs.Stat(storage, mdDir); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	var files []string
+	err := fs.WalkDir(storage, mdDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".md" {
+			return nil
+		}
+		files = append(files, name)
+		return nil
+	})
+	return files, err
+}
+
+// readPostFromMdFile builds a post from the front matter of a markdown file.
+// It returns false if the file has no front matter.
+func readPostFromMdFile(storage Storage, fileName string, loc *time.Location) (Post, bool, error) {
+	src, err := fs.ReadFile(storage, fileName)
+	if err != nil {
+		return Post{}, false, fmt.Errorf("cannot read file : %v", err)
+	}
+	matter, _, err := frontmatter.Parse(src)
+	if err != nil {
+		return Post{}, false, err
+	}
+	if matter == nil {
+		return Post{}, false, nil
+	}
+	p, err := postFromFrontMatter(matter, fileName, loc)
+	if err != nil {
+		return Post{}, false, err
+	}
+	return p, true, nil
+}
+
+// postFromFrontMatter builds a post from decoded front matter.
+// The ID defaults to the file name without its extension, dates without a zone are parsed in loc.
+func postFromFrontMatter(matter frontmatter.Matter, fileName string, loc *time.Location) (Post, error) {
+	p := Post{
+		ID:             matter.String("id"),
+		Title:          matter.String("title"),
+		Tags:           matter.Strings("tags"),
+		Authors:        matter.Strings("authors"),
+		Lang:           matter.String("lang"),
+		TranslationKey: matter.String("translationKey"),
+		Content:        matter.String("content"),
+		FileName:       fileName,
+		Draft:          matter.Bool("draft"),
+	}
+	if p.ID == "" {
+		p.ID = strings.TrimSuffix(path.Base(fileName), path.Ext(fileName))
+	}
+	if date := matter.String("date"); date != "" {
+		t, err := parseDate(date, loc)
+		if err != nil {
+			return Post{}, fmt.Errorf("post %q: %v", p.ID, err)
+		}
+		p.Date = t
+	}
+	if publishAt := matter.String("publishAt"); publishAt != "" {
+		t, err := parseDate(publishAt, loc)
+		if err != nil {
+			return Post{}, fmt.Errorf("post %q: publishAt: %v", p.ID, err)
+		}
+		p.PublishAt = &t
+	}
+	p.Series = seriesFromFrontMatter(matter)
+	for key, value := range matter {
+		if slices.Contains(frontMatterKeys, key) {
+			continue
+		}
+		if p.Extra == nil {
+			p.Extra = make(map[string]interface{})
+		}
+		p.Extra[key] = value
+	}
+	return p, nil
+}
+
+// seriesFromFrontMatter reads the series of a post, written either as a table
+// (series: {name: go-tour, part: 2}) or as a name with a separate part field.
+func seriesFromFrontMatter(matter frontmatter.Matter) *Series {
+	if table := matter.Matter("series"); table != nil {
+		if name := table.String("name"); name != "" {
+			return &Series{Name: name, Part: table.Int("part")}
+		}
+		return nil
+	}
+	if name := matter.String("series"); name != "" {
+		return &Series{Name: name, Part: matter.Int("part")}
+	}
+	return nil
+}
+
+// mergePosts combines the posts of the two sources, json entries taking precedence.
+// A json entry matches a front matter post by ID or, failing that, by file name.
+// jsonDrafts has the IDs of the json entries setting draft, which then wins over the front matter.
+// The conflicting dates are reported as written in loc.
+func mergePosts(mdPosts []Post, jsonPosts []Post, jsonDrafts map[string]bool, loc *time.Location) (map[string]Post, []Conflict) {
+	store := make(map[string]Post, len(mdPosts)+len(jsonPosts))
+	fromMd := make(map[string]Post, len(mdPosts))
+	idByFile := make(map[string]string, len(mdPosts))
+	for _, p := range mdPosts {
+		store[p.ID] = p
+		fromMd[p.ID] = p
+		idByFile[p.FileName] = p.ID
+	}
+
+	var conflicts []Conflict
+	for _, jp := range jsonPosts {
+		if jp.ID == "" {
+			continue
+		}
+		mp, found := fromMd[jp.ID]
+		if !found && jp.FileName != "" {
+			var mdID string
+			if mdID, found = idByFile[jp.FileName]; found {
+				mp = fromMd[mdID]
+				conflicts = append(conflicts, Conflict{
+					ID: jp.ID, FileName: jp.FileName, Field: "id", JsonValue: jp.ID, FrontMatterValue: mdID,
+				})
+				delete(store, mdID)
+			}
+		}
+		if found {
+			var fieldConflicts []Conflict
+			jp, fieldConflicts = mergePost(jp, mp, jsonDrafts[jp.ID], loc)
+			conflicts = append(conflicts, fieldConflicts...)
+		}
+		store[jp.ID] = jp
+	}
+	return store, conflicts
+}
+
+// mergePost fills the empty fields of the json post jp from the front matter post mp.
+// The draft flag is only taken from mp if jp does not set it, as told by setsDraft.
+func mergePost(jp Post, mp Post, setsDraft bool, loc *time.Location) (Post, []Conflict) {
+	var conflicts []Conflict
+	conflict := func(field, jsonValue, mdValue string) {
+		conflicts = append(conflicts, Conflict{
+			ID: jp.ID, FileName: mp.FileName, Field: field, JsonValue: jsonValue, FrontMatterValue: mdValue,
+		})
+	}
+	mergeString := func(field string, jv *string, mv string) {
+		switch {
+		case *jv == "":
+			*jv = mv
+		case mv != "" && *jv != mv:
+			conflict(field, *jv, mv)
+		}
+	}
+
+	mergeString("title", &jp.Title, mp.Title)
+	mergeString("content", &jp.Content, mp.Content)
+	mergeString("filename", &jp.FileName, mp.FileName)
+	mergeString("lang", &jp.Lang, mp.Lang)
+	mergeString("translationKey", &jp.TranslationKey, mp.TranslationKey)
+	switch {
+	case jp.Date.IsZero():
+		jp.Date = mp.Date
+	case !mp.Date.IsZero() && !jp.Date.Equal(mp.Date):
+		conflict("date", formatDate(jp.Date, loc), formatDate(mp.Date, loc))
+	}
+	switch {
+	case !setsDraft:
+		jp.Draft = mp.Draft
+	case !jp.Draft && mp.Draft:
+		conflict("draft", "false", "true")
+	}
+	switch {
+	case jp.Series == nil:
+		jp.Series = mp.Series
+	case mp.Series != nil && *jp.Series != *mp.Series:
+		conflict("series", fmt.Sprintf("%s #%d", jp.Series.Name, jp.Series.Part), fmt.Sprintf("%s #%d", mp.Series.Name, mp.Series.Part))
+	}
+	switch {
+	case jp.PublishAt == nil:
+		jp.PublishAt = mp.PublishAt
+	case mp.PublishAt != nil && !jp.PublishAt.Equal(*mp.PublishAt):
+		conflict("publishAt", jp.PublishAt.Format(time.RFC3339), mp.PublishAt.Format(time.RFC3339))
+	}
+	switch {
+	case len(jp.Tags) == 0:
+		jp.Tags = mp.Tags
+	case len(mp.Tags) != 0 && !reflect.DeepEqual(jp.Tags, mp.Tags):
+		conflict("tags", strings.Join(jp.Tags, ","), strings.Join(mp.Tags, ","))
+	}
+	switch {
+	case len(jp.Authors) == 0:
+		jp.Authors = mp.Authors
+	case len(mp.Authors) != 0 && !reflect.DeepEqual(jp.Authors, mp.Authors):
+		conflict("authors", strings.Join(jp.Authors, ","), strings.Join(mp.Authors, ","))
+	}
+	if len(mp.Extra) != 0 {
+		extra := make(map[string]interface{}, len(mp.Extra)+len(jp.Extra))
+		for k, v := range mp.Extra {
+			extra[k] = v
+		}
+		for k, v := range jp.Extra {
+			extra[k] = v
+		}
+		jp.Extra = extra
+	}
+	return jp, conflicts
+}
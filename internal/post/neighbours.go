@@ -0,0 +1,119 @@
+package post
+
+import (
+	"sort"
+
+	"github.com/kegliz/silent-blog/internal/server/logger"
+)
+
+// RelatedLimit is the maximum number of related posts returned by GetNeighbours.
+const RelatedLimit = 3
+
+// links are the precomputed neighbours of every post of a store.
+// They are computed over every post, visibility is applied when they are read
+// so that scheduled posts show up without rebuilding the store.
+type links struct {
+	// order holds the IDs of the posts in the order of sortPosts, newest first
+	order []string
+	// position is the index of every post in order
+	position map[string]int
+	// related holds for every post the IDs of the posts sharing a tag with it, best match first
+	related map[string][]string
+}
+
+// buildLinks computes the chronological order and the related posts of a store.
+// Related posts are ranked by the number of shared tags, then by recency.
+func buildLinks(store map[string]Post) links {
+	posts := make([]Post, 0, len(store))
+	for _, p := range store {
+		posts = append(posts, p)
+	}
+	sortPosts(posts)
+
+	l := links{
+		order:    make([]string, len(posts)),
+		position: make(map[string]int, len(posts)),
+		related:  make(map[string][]string, len(posts)),
+	}
+	for i, p := range posts {
+		l.order[i] = p.ID
+		l.position[p.ID] = i
+	}
+
+	for _, p := range posts {
+		type candidate struct {
+			id      string
+			overlap int
+		}
+		var candidates []candidate
+		// posts are ordered newest first, a stable sort keeps recency as the tie-breaker
+		for _, other := range posts {
+			if other.ID == p.ID {
+				continue
+			}
+			if overlap := sharedTags(p.Tags, other.Tags); overlap > 0 {
+				candidates = append(candidates, candidate{id: other.ID, overlap: overlap})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].overlap > candidates[j].overlap
+		})
+		ids := make([]string, len(candidates))
+		for i, c := range candidates {
+			ids[i] = c.id
+		}
+		l.related[p.ID] = ids
+	}
+	return l
+}
+
+// sharedTags returns the number of distinct tags in both a and b.
+func sharedTags(a []string, b []string) int {
+	count := 0
+	seen := make(map[string]bool, len(a))
+	for _, t := range a {
+		seen[t] = true
+	}
+	for _, t := range b {
+		if seen[t] {
+			count++
+			seen[t] = false
+		}
+	}
+	return count
+}
+
+// GetNeighbours implements Service.
+func (s *pService) GetNeighbours(l logger.LoggingFn, id string) (Neighbours, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetNeighbours")
+	s.RLock()
+	defer s.RUnlock()
+	pos, ok := s.links.position[id]
+	if !ok {
+		return Neighbours{}, &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+
+	var n Neighbours
+	// the order is newest first, so the previous post is further down the list
+	for i := pos + 1; i < len(s.links.order); i++ {
+		if p := s.store[s.links.order[i]]; s.isVisible(p) {
+			n.Previous = &p
+			break
+		}
+	}
+	for i := pos - 1; i >= 0; i-- {
+		if p := s.store[s.links.order[i]]; s.isVisible(p) {
+			n.Next = &p
+			break
+		}
+	}
+	for _, relatedID := range s.links.related[id] {
+		if len(n.Related) == RelatedLimit {
+			break
+		}
+		if p := s.store[relatedID]; s.isVisible(p) {
+			n.Related = append(n.Related, p)
+		}
+	}
+	return n, nil
+}
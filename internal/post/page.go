@@ -0,0 +1,104 @@
+package post
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageSize is the page size used when PageRequest.Size is not set.
+	DefaultPageSize = 10
+	// cursorSeparator separates the date and the ID encoded in a cursor.
+	cursorSeparator = "\x00"
+)
+
+// sortPosts orders posts by date, newest first. Posts of the same date are ordered by ID
+// so that the order, and with it every page, is stable.
+func sortPosts(posts []Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		return postBefore(posts[i], posts[j])
+	})
+}
+
+// sortTranslations orders the translations of an article by language, then by ID.
+func sortTranslations(posts []Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		if posts[i].Lang != posts[j].Lang {
+			return posts[i].Lang < posts[j].Lang
+		}
+		return posts[i].ID < posts[j].ID
+	})
+}
+
+// postBefore reports whether a comes before b in the order of sortPosts.
+func postBefore(a Post, b Post) bool {
+	if !a.Date.Equal(b.Date) {
+		return a.Date.After(b.Date)
+	}
+	return a.ID < b.ID
+}
+
+// paginate cuts the page selected by req out of the ordered posts.
+func paginate(posts []Post, req PageRequest) (PostPage, error) {
+	if req.Size < 1 {
+		req.Size = DefaultPageSize
+	}
+
+	start := 0
+	switch {
+	case req.Cursor != "":
+		after, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return PostPage{}, err
+		}
+		// the cursor post itself may be gone since, the page starts at the first post after it
+		start = sort.Search(len(posts), func(i int) bool {
+			return postBefore(after, posts[i])
+		})
+	case req.Page > 0:
+		start = (req.Page - 1) * req.Size
+	default:
+		return PostPage{}, fmt.Errorf("paginate: page %d: %w", req.Page, ErrInvalidPage)
+	}
+
+	page := PostPage{Total: len(posts)}
+	if start >= len(posts) {
+		page.Posts = []Post{}
+		return page, nil
+	}
+	end := min(start+req.Size, len(posts))
+	page.Posts = posts[start:end]
+	if end < len(posts) {
+		page.NextCursor = encodeCursor(posts[end-1])
+		if end%req.Size == 0 {
+			page.NextPage = end/req.Size + 1
+		}
+	}
+	return page, nil
+}
+
+// encodeCursor returns the cursor pointing after p.
+func encodeCursor(p Post) string {
+	date := p.Date.UTC().Format(time.RFC3339Nano)
+	return base64.RawURLEncoding.EncodeToString([]byte(date + cursorSeparator + p.ID))
+}
+
+// decodeCursor returns the date and ID of the post a cursor points after.
+func decodeCursor(cursor string) (Post, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Post{}, fmt.Errorf("decodeCursor: %w", ErrInvalidPage)
+	}
+	date, id, ok := strings.Cut(string(raw), cursorSeparator)
+	if !ok {
+		return Post{}, fmt.Errorf("decodeCursor: %w", ErrInvalidPage)
+	}
+	t, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return Post{}, fmt.Errorf("decodeCursor: %w", ErrInvalidPage)
+	}
+	return Post{ID: id, Date: t}, nil
+}
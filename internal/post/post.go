@@ -2,39 +2,198 @@ package post
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/frontmatter"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/search"
 	"github.com/kegliz/silent-blog/internal/server/logger"
 )
 
 var ErrKeyNotExist = errors.New("key does not exist")
+var ErrInvalidPage = errors.New("invalid page request")
+
+// WordsPerMinute is the reading speed the reading time of a post is estimated with.
+const WordsPerMinute = 200
+
+const (
+	// TagOrderName orders tags alphabetically.
+	TagOrderName TagOrder = "name"
+	// TagOrderCount orders tags by descending post count, then alphabetically.
+	TagOrderCount TagOrder = "count"
+)
 
 type (
 
 	// ServiceOptions is a struct that contains the options for constructing a Service.
+	// FileName is the optional posts.json override, MdDir is scanned for markdown files with front matter.
+	// Watch enables reloading the posts when any of them changes.
+	// ShowDrafts makes drafts and scheduled posts visible, for local authoring.
+	// Location is the site timezone of dates written without a zone, UTC if not set.
+	// Storage is where FileName and MdDir are read from, the local disk if not set.
+	// Authors are the authors posts may name, a post naming any other author fails to load.
+	// OnReload is called after the watched posts have been reloaded.
 	ServiceOptions struct {
-		Logger   *logger.Logger
-		FileName string
-		MdDir    string
+		Logger     *logger.Logger
+		Storage    Storage
+		FileName   string
+		MdDir      string
+		Watch      bool
+		ShowDrafts bool
+		Location   *time.Location
+		Authors    *author.Directory
+		OnReload   func()
 	}
 
 	// Service is an interface that defines the methods of the Service.
 	Service interface {
-		// GetPosts returns all posts. No pagination is implemented.
+		// The methods below only return published posts unless the service shows drafts.
+
+		// GetPosts returns all posts ordered by date, newest first.
 		GetPosts(l logger.LoggingFn) ([]Post, error)
+		// GetPostsPage returns a page of the posts returned by GetPosts.
+		GetPostsPage(l logger.LoggingFn, req PageRequest) (PostPage, error)
 		// GetPost returns a post by its ID.
 		GetPost(l logger.LoggingFn, id string) (Post, error)
+		// GetUnpublishedPost returns a post by its ID even if it is a draft or scheduled, for previews.
+		GetUnpublishedPost(l logger.LoggingFn, id string) (Post, error)
 		// GetPostsByTag returns all posts with a given tag.
+		// It returns a KeyError if no post has the tag.
 		GetPostsByTag(l logger.LoggingFn, tag string) ([]Post, error)
+		// GetPostsByTagPage returns a page of the posts returned by GetPostsByTag.
+		GetPostsByTagPage(l logger.LoggingFn, tag string, req PageRequest) (PostPage, error)
+		// GetPostsByAuthor returns all posts written by an author, ordered by date.
+		// Unlike GetPostsByTag it returns no error if the author has no posts.
+		GetPostsByAuthor(l logger.LoggingFn, id string) ([]Post, error)
+		// GetTranslations returns the post and its translations, the posts sharing its TranslationKey, ordered by language.
+		// It returns a KeyError if the post does not exist.
+		GetTranslations(l logger.LoggingFn, id string) ([]Post, error)
+		// GetSeries returns the posts of a series ordered by part number.
+		// It returns a KeyError if the series has no posts.
+		GetSeries(l logger.LoggingFn, name string) ([]Post, error)
+		// GetNeighbours returns the chronological neighbours and the related posts of a post.
+		// It returns a KeyError if the post does not exist.
+		GetNeighbours(l logger.LoggingFn, id string) (Neighbours, error)
+		// GetTags returns every tag with the number of posts having it.
+		GetTags(l logger.LoggingFn, order TagOrder) ([]TagCount, error)
+		// Search returns at most limit posts matching the query, best match first.
+		// The query supports prefix matching and the tag: and year: filters.
+		Search(l logger.LoggingFn, query string, limit int) ([]SearchResult, error)
+
+		// The methods below change the posts and return ErrReadOnly if the content cannot be written.
+
+		// CreatePost adds a post with its markdown body and returns it as stored.
+		// It returns a *ValidationError for invalid input and a KeyError if the ID is taken.
+		CreatePost(l logger.LoggingFn, in PostInput) (Post, error)
+		// UpdatePost replaces the fields and the markdown body of a post, its ID cannot be changed.
+		// It returns a *ValidationError for invalid input and a KeyError if the post does not exist.
+		UpdatePost(l logger.LoggingFn, id string, in PostInput) (Post, error)
+		// DeletePost removes a post and its markdown file.
+		// It returns a KeyError if the post does not exist.
+		DeletePost(l logger.LoggingFn, id string) error
+
+		// Close stops watching the posts for changes.
+		Close() error
 	}
 
 	// Post is a struct that contains the fields of a post.
 	Post struct {
-		ID       string   `json:"id"`
-		Title    string   `json:"title"`
-		Tags     []string `json:"tags"`
-		Date     string   `json:"date"`
-		Content  string   `json:"content"`
-		FileName string   `json:"filename"`
+		ID    string   `json:"id"`
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+		// Authors are the IDs of the authors of the post, in byline order.
+		Authors []string `json:"authors,omitempty"`
+		// Lang is the BCP 47 language tag of the post, the site language if empty.
+		Lang string `json:"lang,omitempty"`
+		// TranslationKey names the article the post is a translation of, posts sharing it are translations of each other.
+		TranslationKey string `json:"translationKey,omitempty"`
+		// Date is the publication date, written as text in posts.json and front matter.
+		Date     time.Time `json:"date"`
+		Content  string    `json:"content"`
+		FileName string    `json:"filename"`
+		// Draft posts are hidden until the flag is removed.
+		Draft bool `json:"draft,omitempty"`
+		// PublishAt hides the post until the given time.
+		PublishAt *time.Time `json:"publishAt,omitempty"`
+		// Series places the post in a multi-part series, nil if it stands alone.
+		Series *Series `json:"series,omitempty"`
+		// Extra holds the front matter fields that have no dedicated field.
+		Extra map[string]interface{} `json:"extra,omitempty"`
+		// Stats are worked out from the markdown body whenever the posts are loaded, they are never stored.
+		Stats Stats `json:"-"`
+	}
+
+	// Stats are the length and the excerpt of the body of a post.
+	Stats struct {
+		Words int
+		// ReadingTime is the estimated reading time in minutes, at least 1 if the post has any words.
+		ReadingTime int
+		// Excerpt is the text before the <!--more--> marker of the body, or else the text of its first paragraph.
+		Excerpt string
+	}
+
+	// PageRequest selects a page of posts either by page number or by cursor.
+	// A non-empty Cursor takes precedence over Page.
+	PageRequest struct {
+		// Size is the number of posts per page, DefaultPageSize if not set.
+		Size int
+		// Page is the 1-based page number.
+		Page int
+		// Cursor is the NextCursor of the previous page.
+		Cursor string
+	}
+
+	// PostPage is a page of posts.
+	PostPage struct {
+		Posts []Post
+		// Total is the number of posts on all pages.
+		Total int
+		// NextPage is the number of the next page, 0 on the last page.
+		NextPage int
+		// NextCursor is the cursor of the next page, empty on the last page.
+		NextCursor string
+	}
+
+	// SearchResult is a post matching a search query with a highlighted snippet of its body.
+	SearchResult struct {
+		Post    Post
+		Snippet []search.Fragment
+	}
+
+	// Neighbours are the posts to read after a post.
+	Neighbours struct {
+		// Previous is the next older post, nil for the oldest one.
+		Previous *Post
+		// Next is the next newer post, nil for the newest one.
+		Next *Post
+		// Related are the posts sharing the most tags with the post, at most RelatedLimit.
+		Related []Post
+	}
+
+	// TagCount is a tag with the number of posts having it.
+	TagCount struct {
+		Tag   string
+		Count int
+	}
+
+	// TagOrder is the order of the tags returned by GetTags.
+	TagOrder string
+
+	// Series is the name of a series of posts and the part number of a post in it.
+	Series struct {
+		Name string `json:"name"`
+		Part int    `json:"part"`
+	}
+
+	// Conflict describes a field on which posts.json and the front matter of a markdown file disagree.
+	Conflict struct {
+		ID               string
+		FileName         string
+		Field            string
+		JsonValue        string
+		FrontMatterValue string
 	}
 
 	// KeyError is an error type that is returned when a key is not found in the store.
@@ -48,3 +207,36 @@ type (
 func (e *KeyError) Error() string {
 	return e.Err.Error() + ": " + e.Key
 }
+
+// IsPublished reports whether the post is visible to readers at the given time.
+func (p Post) IsPublished(now time.Time) bool {
+	return !p.Draft && (p.PublishAt == nil || !p.PublishAt.After(now))
+}
+
+// TOCDepth returns the number of heading levels in the table of contents of the post,
+// set by its tocDepth field or else def. It returns 0 if the post opts out with toc: false.
+func (p Post) TOCDepth(def int) int {
+	matter := frontmatter.Matter(p.Extra)
+	if _, ok := matter["toc"]; ok && !matter.Bool("toc") {
+		return 0
+	}
+	if depth := matter.Int("tocDepth"); depth > 0 {
+		return depth
+	}
+	return def
+}
+
+// newStats returns the stats of a post from the summary of its body.
+func newStats(summary render.Summary) Stats {
+	return Stats{
+		Words:       summary.Words,
+		ReadingTime: (summary.Words + WordsPerMinute - 1) / WordsPerMinute,
+		Excerpt:     summary.Excerpt,
+	}
+}
+
+// String implements the fmt.Stringer interface.
+func (c Conflict) String() string {
+	return fmt.Sprintf("post %s (%s): %s is %q in posts.json but %q in front matter",
+		c.ID, c.FileName, c.Field, c.JsonValue, c.FrontMatterValue)
+}
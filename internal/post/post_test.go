@@ -1,13 +1,19 @@
 package post
 
 import (
+	"archive/zip"
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/author"
 	"github.com/kegliz/silent-blog/internal/server/logger"
 	"github.com/stretchr/testify/suite"
 )
@@ -25,16 +31,18 @@ var testPostData = []Post{
 		Title:    "Example Post 1",
 		Content:  "This is the body of example post 1.\n\nIt has multiple lines.",
 		Tags:     []string{"example", "post"},
-		Date:     "2020-01-01",
+		Date:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 		FileName: "testdata/blog_1.md",
+		Stats:    Stats{Words: 50, ReadingTime: 1, Excerpt: "Bold text"},
 	},
 	{
 		ID:       "2",
 		Title:    "Example Post 2",
 		Content:  "This is the body of example post 2.\n\nIt has multiple lines.",
 		Tags:     []string{"example"},
-		Date:     "2020-01-02",
+		Date:     time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
 		FileName: "",
+		Stats:    Stats{Words: 12, ReadingTime: 1, Excerpt: "This is the body of example post 2."},
 	},
 }
 
@@ -47,7 +55,7 @@ func (s *PostServiceTestSuite) SetupSuite() {
 
 	// order posts by date
 	sort.Slice(testPostData, func(i, j int) bool {
-		return testPostData[i].Date > testPostData[j].Date
+		return testPostData[i].Date.After(testPostData[j].Date)
 	})
 }
 
@@ -145,6 +153,194 @@ func (s *PostServiceTestSuite) TestInitPostsFromJsonWhenWrongJson() {
 	s.Require().ErrorContains(err, "invalid character") // TODO check for specific error
 }
 
+// TestNewServiceFromMdDir tests the NewService method of the post service with initialization from front matter only
+func (s *PostServiceTestSuite) TestNewServiceFromMdDir() {
+	testService, err := NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  "testdata/md",
+	})
+	s.Require().NoError(err)
+
+	posts, err := testService.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2, "markdown files without front matter should be skipped")
+
+	post, err := testService.GetPost(s.LogFn, "yaml-post")
+	s.Require().NoError(err)
+	s.Require().Equal("Front matter in YAML", post.Title)
+	s.Require().Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), post.Date)
+	s.Require().Equal([]string{"example", "yaml"}, post.Tags)
+	s.Require().Equal("testdata/md/yaml-post.md", post.FileName)
+	s.Require().Equal("formats", post.Extra["subtitle"])
+
+	post, err = testService.GetPost(s.LogFn, "toml-post")
+	s.Require().NoError(err, "the id should default to the file name")
+	s.Require().Equal("Front matter in TOML", post.Title)
+	s.Require().Equal(time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC), post.Date)
+
+	_, err = NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  "testdata/dupids",
+	})
+	s.Require().ErrorContains(err, `testdata/dupids/b.md: post "same": duplicate id, also used by testdata/dupids/a.md`)
+}
+
+// TestLoadPostsMergesJsonAndFrontMatter tests that posts.json overrides the front matter and conflicts are reported
+func (s *PostServiceTestSuite) TestLoadPostsMergesJsonAndFrontMatter() {
+	store, conflicts, err := loadPosts(NewLocalStorage(), "testdata/md_posts.json", "testdata/md", time.UTC, nil)
+	s.Require().NoError(err)
+	s.Require().Len(store, 3)
+
+	s.Require().Equal("Legacy post", store["legacy"].Title)
+	s.Require().Equal("testdata/md/legacy.md", store["legacy"].FileName)
+
+	s.Require().Equal("Overridden title", store["yaml-post"].Title, "json should win")
+	s.Require().Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), store["yaml-post"].Date, "empty json fields should come from front matter")
+	s.Require().Equal([]string{"example", "yaml"}, store["yaml-post"].Tags)
+
+	s.Require().NotContains(store, "toml-post", "the json id should replace the front matter id")
+	s.Require().Equal("Front matter in TOML", store["renamed"].Title)
+
+	s.Require().ElementsMatch([]Conflict{
+		{ID: "yaml-post", FileName: "testdata/md/yaml-post.md", Field: "title", JsonValue: "Overridden title", FrontMatterValue: "Front matter in YAML"},
+		{ID: "renamed", FileName: "testdata/md/toml-post.md", Field: "id", JsonValue: "renamed", FrontMatterValue: "toml-post"},
+	}, conflicts)
+
+	for _, mdDir := range []string{"./testdata/md", "testdata/md/"} {
+		store, _, err := loadPosts(NewLocalStorage(), "testdata/md_posts.json", mdDir, time.UTC, nil)
+		s.Require().NoError(err, mdDir)
+		s.Require().Len(store, 3, "%s: json entries should describe the files found in the directory", mdDir)
+		s.Require().Equal("testdata/md/toml-post.md", store["renamed"].FileName, mdDir)
+
+		issues, err := Lint(LintOptions{MdDir: mdDir, FileName: "testdata/md_posts.json"})
+		s.Require().NoError(err, mdDir)
+		s.Require().Empty(issues, mdDir)
+	}
+}
+
+// TestLoadPostsDraft tests that posts.json can set the draft flag of a markdown file either way
+func (s *PostServiceTestSuite) TestLoadPostsDraft() {
+	dir := s.T().TempDir()
+	mdDir := filepath.Join(dir, "md")
+	s.Require().NoError(os.Mkdir(mdDir, 0755))
+	for name, draft := range map[string]string{"undrafted": "true", "drafted": "false", "kept": "true"} {
+		src := "---\nid: " + name + "\ntitle: " + name + "\ndate: 2024-03-01\ndraft: " + draft + "\n---\n"
+		s.Require().NoError(os.WriteFile(filepath.Join(mdDir, name+".md"), []byte(src), 0644))
+	}
+	jsonFile := filepath.Join(dir, "posts.json")
+	s.Require().NoError(os.WriteFile(jsonFile, []byte(`[{"id": "undrafted", "draft": false}, {"id": "drafted", "draft": true}, {"id": "kept"}]`), 0644))
+
+	store, conflicts, err := loadPosts(NewLocalStorage(), jsonFile, mdDir, time.UTC, nil)
+	s.Require().NoError(err)
+	s.Require().False(store["undrafted"].Draft, "an explicit false in json should win")
+	s.Require().True(store["drafted"].Draft)
+	s.Require().True(store["kept"].Draft, "a json entry without draft should keep the front matter flag")
+	s.Require().Equal([]Conflict{
+		{ID: "undrafted", FileName: filepath.Join(mdDir, "undrafted.md"), Field: "draft", JsonValue: "false", FrontMatterValue: "true"},
+	}, conflicts)
+}
+
+// TestWatchReloadsPosts tests that the store is rebuilt when the content changes and kept when the reload fails
+func (s *PostServiceTestSuite) TestWatchReloadsPosts() {
+	dir := s.T().TempDir()
+	mdDir := filepath.Join(dir, "posts")
+	s.Require().NoError(os.Mkdir(mdDir, 0755))
+	jsonFile := filepath.Join(dir, "posts.json")
+	s.Require().NoError(os.WriteFile(jsonFile, []byte(`[{"id": "json-post", "title": "From json"}]`), 0644))
+
+	reloaded := make(chan struct{}, 10)
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		FileName: jsonFile,
+		MdDir:    mdDir,
+		Watch:    true,
+		OnReload: func() { reloaded <- struct{}{} },
+	})
+	s.Require().NoError(err)
+	defer testService.Close()
+
+	err = os.WriteFile(filepath.Join(mdDir, "new-post.md"), []byte("---\ntitle: New post\n---\n# New\n"), 0644)
+	s.Require().NoError(err)
+	s.Require().Eventually(func() bool {
+		_, err := testService.GetPost(s.LogFn, "new-post")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "new markdown file should be picked up")
+	s.Require().Eventually(func() bool { return len(reloaded) > 0 }, 5*time.Second, 10*time.Millisecond, "the reload should be reported")
+
+	s.Require().NoError(os.WriteFile(jsonFile, []byte("wrong json"), 0644))
+	time.Sleep(4 * reloadDelay)
+	posts, err := testService.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2, "failed reload should keep the previous posts")
+
+	s.Require().NoError(os.WriteFile(jsonFile, []byte(`[]`), 0644))
+	s.Require().Eventually(func() bool {
+		_, err := testService.GetPost(s.LogFn, "json-post")
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond, "removed json post should disappear")
+
+	results, err := testService.Search(s.LogFn, "new", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1, "the search index should be rebuilt with the store")
+
+	s.Require().NoError(testService.Close())
+}
+
+// TestWatchMissingMdDir tests that a markdown directory created after the start is watched
+func (s *PostServiceTestSuite) TestWatchMissingMdDir() {
+	dir := s.T().TempDir()
+	mdDir := filepath.Join(dir, "posts")
+	testService, err := NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  mdDir,
+		Watch:  true,
+	})
+	s.Require().NoError(err)
+	defer testService.Close()
+
+	s.Require().NoError(os.Mkdir(mdDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(mdDir, "first.md"), []byte("---\ntitle: First\n---\n"), 0644))
+	s.Require().Eventually(func() bool {
+		_, err := testService.GetPost(s.LogFn, "first")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "the new directory should be loaded")
+	s.Require().NoError(os.WriteFile(filepath.Join(mdDir, "second.md"), []byte("---\ntitle: Second\n---\n"), 0644))
+	s.Require().Eventually(func() bool {
+		_, err := testService.GetPost(s.LogFn, "second")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "the new directory should be watched")
+
+	orphan, err := NewService(ServiceOptions{Logger: s.Logger, MdDir: filepath.Join(dir, "missing", "posts"), Watch: true})
+	s.Require().NoError(err, "a missing parent should only be warned about")
+	s.Require().NoError(orphan.Close())
+}
+
+// TestStats tests the word count, reading time and excerpt of the posts and their update on reload
+func (s *PostServiceTestSuite) TestStats() {
+	mdDir := s.T().TempDir()
+	fileName := filepath.Join(mdDir, "long.md")
+	s.Require().NoError(os.WriteFile(fileName, []byte("---\ntitle: Long\n---\n# Long\n\nAn *opening* paragraph.\n\nMore of it.\n\n<!--more-->\n\nThe rest.\n"), 0644))
+
+	testService, err := NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  mdDir,
+		Watch:  true,
+	})
+	s.Require().NoError(err)
+	defer testService.Close()
+
+	post, err := testService.GetPost(s.LogFn, "long")
+	s.Require().NoError(err)
+	s.Equal(Stats{Words: 9, ReadingTime: 1, Excerpt: "Long An opening paragraph. More of it."}, post.Stats)
+
+	body := "First paragraph.\n\n" + strings.Repeat("word ", 2*WordsPerMinute) + "\n"
+	s.Require().NoError(os.WriteFile(fileName, []byte("---\ntitle: Long\n---\n"+body), 0644))
+	s.Require().Eventually(func() bool {
+		post, err := testService.GetPost(s.LogFn, "long")
+		return err == nil && post.Stats == Stats{Words: 2*WordsPerMinute + 2, ReadingTime: 3, Excerpt: "First paragraph."}
+	}, 5*time.Second, 50*time.Millisecond, "the stats should be worked out again on reload")
+}
+
 // TestGetPost tests the GetPost method of the post service
 func (s *PostServiceTestSuite) TestGetPost() {
 	testService, err := NewService(ServiceOptions{
@@ -220,6 +416,635 @@ func (s *PostServiceTestSuite) TestGetPostsByTag() {
 	s.Require().Equal(testPostData[1], posts[0])
 }
 
+// TestGetPostsPage tests the GetPostsPage method of the post service with page numbers and cursors
+func (s *PostServiceTestSuite) TestGetPostsPage() {
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		FileName: "testdata/test_posts.json",
+	})
+	s.Require().NoError(err)
+
+	page, err := testService.GetPostsPage(s.LogFn, PageRequest{Size: 1, Page: 1})
+	s.Require().NoError(err)
+	s.Require().Equal([]Post{testPostData[0]}, page.Posts)
+	s.Require().Equal(2, page.Total)
+	s.Require().Equal(2, page.NextPage)
+	s.Require().NotEmpty(page.NextCursor)
+
+	next, err := testService.GetPostsPage(s.LogFn, PageRequest{Size: 1, Cursor: page.NextCursor})
+	s.Require().NoError(err)
+	s.Require().Equal([]Post{testPostData[1]}, next.Posts)
+	s.Require().Zero(next.NextPage, "last page should have no next page")
+	s.Require().Empty(next.NextCursor, "last page should have no next cursor")
+
+	byNumber, err := testService.GetPostsPage(s.LogFn, PageRequest{Size: 1, Page: 2})
+	s.Require().NoError(err)
+	s.Require().Equal(next, byNumber)
+
+	beyond, err := testService.GetPostsPage(s.LogFn, PageRequest{Size: 1, Page: 3})
+	s.Require().NoError(err)
+	s.Require().Empty(beyond.Posts)
+
+	_, err = testService.GetPostsPage(s.LogFn, PageRequest{Size: 1, Cursor: "%%%"})
+	s.Require().ErrorIs(err, ErrInvalidPage)
+	_, err = testService.GetPostsPage(s.LogFn, PageRequest{Size: 1})
+	s.Require().ErrorIs(err, ErrInvalidPage)
+}
+
+// TestGetPostsByTagPage tests the GetPostsByTagPage method of the post service
+func (s *PostServiceTestSuite) TestGetPostsByTagPage() {
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		FileName: "testdata/test_posts.json",
+	})
+	s.Require().NoError(err)
+
+	page, err := testService.GetPostsByTagPage(s.LogFn, "example", PageRequest{Page: 1})
+	s.Require().NoError(err)
+	s.Require().Equal(testPostData, page.Posts, "default page size should fit all posts")
+	s.Require().Empty(page.NextCursor)
+
+	_, err = testService.GetPostsByTagPage(s.LogFn, "unknown", PageRequest{Page: 1})
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+}
+
+// TestGetPostsByTagNotFound tests the GetPostsByTag method of the post service when no post has the tag
+func (s *PostServiceTestSuite) TestGetPostsByTagNotFound() {
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		FileName: "testdata/test_posts.json",
+	})
+	s.Require().NoError(err)
+
+	_, err = testService.GetPostsByTag(s.LogFn, "unknown")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+	s.Require().Equal("unknown", keyError.Key)
+}
+
+// TestGetTags tests the GetTags method of the post service
+func (s *PostServiceTestSuite) TestGetTags() {
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		FileName: "testdata/tag_posts.json",
+	})
+	s.Require().NoError(err)
+
+	tags, err := testService.GetTags(s.LogFn, TagOrderCount)
+	s.Require().NoError(err)
+	s.Require().Equal([]TagCount{
+		{Tag: "zebra", Count: 3},
+		{Tag: "banana", Count: 2},
+		{Tag: "mango", Count: 2},
+		{Tag: "apple", Count: 1},
+	}, tags, "tags with the same count should be ordered by name")
+
+	tags, err = testService.GetTags(s.LogFn, TagOrderName)
+	s.Require().NoError(err)
+	s.Require().Equal([]TagCount{
+		{Tag: "apple", Count: 1},
+		{Tag: "banana", Count: 2},
+		{Tag: "mango", Count: 2},
+		{Tag: "zebra", Count: 3},
+	}, tags)
+}
+
+// TestSearch tests the Search method of the post service over titles, tags and markdown bodies
+func (s *PostServiceTestSuite) TestSearch() {
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		FileName: "testdata/md_posts.json",
+		MdDir:    "testdata/md",
+	})
+	s.Require().NoError(err)
+
+	results, err := testService.Search(s.LogFn, "described", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 3, "every body says described")
+
+	results, err = testService.Search(s.LogFn, "front tag:toml", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1)
+	s.Require().Equal("renamed", results[0].Post.ID)
+	s.Require().NotEmpty(results[0].Snippet)
+
+	results, err = testService.Search(s.LogFn, "year:2021 legacy", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1)
+	s.Require().Equal("legacy", results[0].Post.ID)
+}
+
+// TestDraftsAndScheduledPosts tests that unpublished posts are hidden until their time comes
+func (s *PostServiceTestSuite) TestDraftsAndScheduledPosts() {
+	testService, err := NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  "testdata/drafts",
+	})
+	s.Require().NoError(err)
+	testService.(*pService).now = func() time.Time {
+		return time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	posts, err := testService.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 1)
+	s.Require().Equal("published", posts[0].ID)
+
+	_, err = testService.GetPost(s.LogFn, "draft")
+	s.Require().Error(err, "drafts should be hidden")
+	_, err = testService.GetPost(s.LogFn, "scheduled")
+	s.Require().Error(err, "scheduled posts should be hidden before their time")
+	_, err = testService.GetPostsByTag(s.LogFn, "secret")
+	s.Require().Error(err, "tags of drafts should be hidden")
+	tags, err := testService.GetTags(s.LogFn, TagOrderName)
+	s.Require().NoError(err)
+	s.Require().Equal([]TagCount{{Tag: "drafts", Count: 1}}, tags)
+	results, err := testService.Search(s.LogFn, "post", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1)
+
+	testService.(*pService).now = func() time.Time {
+		return time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	}
+	post, err := testService.GetPost(s.LogFn, "scheduled")
+	s.Require().NoError(err, "scheduled post should be published without reload")
+	s.Require().Equal(time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC), *post.PublishAt)
+}
+
+// TestShowDrafts tests that drafts are listed when the service shows drafts
+func (s *PostServiceTestSuite) TestShowDrafts() {
+	testService, err := NewService(ServiceOptions{
+		Logger:     s.Logger,
+		MdDir:      "testdata/drafts",
+		ShowDrafts: true,
+	})
+	s.Require().NoError(err)
+
+	posts, err := testService.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 3)
+	post, err := testService.GetPost(s.LogFn, "draft")
+	s.Require().NoError(err)
+	s.Require().True(post.Draft)
+}
+
+// TestGetSeries tests the GetSeries method of the post service
+func (s *PostServiceTestSuite) TestGetSeries() {
+	testService, err := NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  "testdata/series",
+	})
+	s.Require().NoError(err)
+
+	posts, err := testService.GetSeries(s.LogFn, "tour")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 3)
+	for i, id := range []string{"part-one", "part-two", "part-three"} {
+		s.Require().Equal(id, posts[i].ID, "series should be ordered by part")
+		s.Require().Equal(&Series{Name: "tour", Part: i + 1}, posts[i].Series)
+	}
+
+	standalone, err := testService.GetPost(s.LogFn, "standalone")
+	s.Require().NoError(err)
+	s.Require().Nil(standalone.Series)
+
+	_, err = testService.GetSeries(s.LogFn, "unknown")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+}
+
+// TestGetNeighbours tests the related posts and the chronological neighbours of a post
+func (s *PostServiceTestSuite) TestGetNeighbours() {
+	testService, err := NewService(ServiceOptions{Logger: s.Logger})
+	s.Require().NoError(err)
+	ps := testService.(*pService)
+	ps.setStore(map[string]Post{
+		"go-1":    {ID: "go-1", Tags: []string{"go"}, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"go-2":    {ID: "go-2", Tags: []string{"go", "htmx"}, Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		"go-3":    {ID: "go-3", Tags: []string{"go"}, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		"draft":   {ID: "draft", Tags: []string{"go", "htmx"}, Date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), Draft: true},
+		"htmx":    {ID: "htmx", Tags: []string{"go", "htmx"}, Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		"cooking": {ID: "cooking", Tags: []string{"food"}, Date: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	})
+
+	n, err := testService.GetNeighbours(s.LogFn, "go-2")
+	s.Require().NoError(err)
+	s.Require().Equal("go-1", n.Previous.ID)
+	s.Require().Equal("go-3", n.Next.ID)
+	ids := make([]string, len(n.Related))
+	for i, p := range n.Related {
+		ids[i] = p.ID
+	}
+	s.Require().Equal([]string{"htmx", "go-3", "go-1"}, ids, "related posts should be ranked by shared tags, then recency")
+
+	n, err = testService.GetNeighbours(s.LogFn, "go-3")
+	s.Require().NoError(err)
+	s.Require().Equal("htmx", n.Next.ID, "drafts should be skipped")
+
+	n, err = testService.GetNeighbours(s.LogFn, "cooking")
+	s.Require().NoError(err)
+	s.Require().Nil(n.Next)
+	s.Require().Equal("htmx", n.Previous.ID)
+	s.Require().Empty(n.Related)
+
+	n, err = testService.GetNeighbours(s.LogFn, "go-1")
+	s.Require().NoError(err)
+	s.Require().Nil(n.Previous)
+
+	_, err = testService.GetNeighbours(s.LogFn, "unknown")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+}
+
+// TestDates tests that dates are parsed in the site timezone and invalid dates are rejected
+func (s *PostServiceTestSuite) TestDates() {
+	budapest, err := time.LoadLocation("Europe/Budapest")
+	s.Require().NoError(err)
+	testService, err := NewService(ServiceOptions{
+		Logger:   s.Logger,
+		MdDir:    "testdata/dates",
+		Location: budapest,
+	})
+	s.Require().NoError(err)
+
+	posts, err := testService.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 3)
+	s.Require().Equal("with-time", posts[0].ID, "a later time on the same day should come first")
+	s.Require().True(time.Date(2023, 10, 2, 18, 30, 0, 0, budapest).Equal(posts[0].Date))
+	s.Require().True(time.Date(2023, 10, 2, 0, 0, 0, 0, budapest).Equal(posts[1].Date))
+	s.Require().Equal("unquoted", posts[2].ID)
+	s.Require().True(time.Date(2023, 10, 1, 9, 15, 0, 0, budapest).Equal(posts[2].Date), "an unquoted time should be in the site timezone too")
+
+	_, err = NewService(ServiceOptions{
+		Logger: s.Logger,
+		MdDir:  "testdata/baddate",
+	})
+	s.Require().ErrorContains(err, `post "bad-date": invalid date "someday"`)
+}
+
+// TestTOCDepth tests the depth of the table of contents set in the metadata of a post
+func (s *PostServiceTestSuite) TestTOCDepth() {
+	s.Require().Equal(2, Post{}.TOCDepth(2))
+	s.Require().Equal(3, Post{Extra: map[string]interface{}{"tocDepth": 3}}.TOCDepth(2))
+	s.Require().Equal(1, Post{Extra: map[string]interface{}{"tocDepth": float64(1), "toc": true}}.TOCDepth(2), "json numbers should be read")
+	s.Require().Equal(0, Post{Extra: map[string]interface{}{"toc": false, "tocDepth": 3}}.TOCDepth(2))
+}
+
+// TestPostJson tests that the json form of a post keeps dates as text
+func (s *PostServiceTestSuite) TestPostJson() {
+	publishAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(Post{ID: "1", Date: time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC), PublishAt: &publishAt})
+	s.Require().NoError(err)
+	s.Require().Contains(string(data), `"date":"2024-04-20"`)
+	s.Require().Contains(string(data), `"publishAt":"2024-05-01T09:00:00Z"`)
+
+	var post Post
+	s.Require().NoError(json.Unmarshal(data, &post))
+	s.Require().True(post.Date.Equal(time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC)))
+	s.Require().True(post.PublishAt.Equal(publishAt))
+
+	s.Require().ErrorContains(json.Unmarshal([]byte(`{"id":"x","date":"2024-13-45"}`), &post), `post "x"`)
+}
+
+// TestFormatDate tests that the time is only dropped from dates at midnight in the site timezone
+func (s *PostServiceTestSuite) TestFormatDate() {
+	budapest, err := time.LoadLocation("Europe/Budapest")
+	s.Require().NoError(err)
+	midnight := time.Date(2023, 10, 2, 0, 0, 0, 0, budapest)
+	utcMidnight := time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	s.Require().Equal("2023-10-02", formatDate(midnight, budapest))
+	s.Require().Equal("2023-10-02", formatDate(midnight.UTC(), budapest), "the zone of the value should not matter")
+	s.Require().Equal("2023-10-01T22:00:00Z", formatDate(midnight.UTC(), time.UTC))
+	s.Require().Equal("2024-04-20T00:00:00Z", formatDate(utcMidnight, budapest), "midnight in another zone should keep its time")
+	s.Require().Equal("2024-04-20", NewPostInput(Post{Date: utcMidnight}, "", time.UTC).Date)
+
+	for _, t := range []time.Time{midnight, midnight.UTC(), utcMidnight} {
+		parsed, err := parseDate(formatDate(t, budapest), budapest)
+		s.Require().NoError(err)
+		s.Require().True(t.Equal(parsed), "%v should be read back, not %v", t, parsed)
+	}
+}
+
+// TestLint tests that Lint reports every problem of the content tree
+func (s *PostServiceTestSuite) TestLint() {
+	issues, err := Lint(LintOptions{
+		FileName: "testdata/lint/posts.json",
+		MdDir:    "testdata/lint/md",
+	})
+	s.Require().NoError(err)
+
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = issue.String()
+	}
+	s.Require().Equal([]string{
+		`testdata/lint/md/bad-date.md: post "bad-date": invalid date "someday"`,
+		`testdata/lint/md/bad-lang.md: post bad-lang: invalid lang "not a language"`,
+		"testdata/lint/md/bad-lang.md: post bad-lang: link to unknown post gone",
+		"testdata/lint/md/dup-b.md: post dup: duplicate id, also used by testdata/lint/md/dup-a.md",
+		"testdata/lint/md/missing.md: post other: cannot read markdown file: open testdata/lint/md/missing.md: no such file or directory",
+		"testdata/lint/md/no-title.md: post no-title: empty title",
+		"testdata/lint/md/no-title.md: post no-title: image diagram.png has no alt text",
+		"testdata/lint/md/no-title.md: post no-title: link to unknown post missing",
+		"testdata/lint/md/orphan.md: no front matter and not listed in the posts file",
+		"testdata/lint/posts.json: entry #2 has an empty id",
+		`testdata/lint/posts.json: post "when": invalid date "13/13/2024"`,
+		"testdata/lint/posts.json: post other: duplicate id",
+	}, messages)
+
+	issues, err = Lint(LintOptions{MdDir: "testdata/md", FileName: "testdata/md_posts.json"})
+	s.Require().NoError(err)
+	s.Require().Empty(issues, "valid content should have no issues")
+}
+
+// TestAuthors tests that posts name known authors and are listed by author
+func (s *PostServiceTestSuite) TestAuthors() {
+	authors, err := author.NewDirectory([]author.Author{{ID: "ann", Name: "Ann"}, {ID: "bob", Name: "Bob"}})
+	s.Require().NoError(err)
+	fsys := fstest.MapFS{
+		"posts.json": {Data: []byte(`[{"id": "both", "filename": "both.md", "authors": ["bob", "ann"]}, {"id": "none", "title": "No byline", "date": "2024-01-01"}]`)},
+		"md/ann.md":  {Data: []byte("---\ntitle: By Ann\ndate: 2024-03-01\nauthors: [ann]\n---\nBody.\n")},
+		"md/both.md": {Data: []byte("---\ntitle: By both\ndate: 2024-02-01\nauthors: [ann, bob]\n---\nBody.\n")},
+	}
+	opts := ServiceOptions{Logger: s.Logger, Storage: NewFSStorage(fsys), FileName: "posts.json", MdDir: "md", Authors: authors}
+	testService, err := NewService(opts)
+	s.Require().NoError(err)
+
+	posts, err := testService.GetPostsByAuthor(s.LogFn, "ann")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2)
+	s.Require().Equal("ann", posts[0].ID)
+	s.Require().Equal([]string{"bob", "ann"}, posts[1].Authors, "posts.json should set the byline order")
+	posts, err = testService.GetPostsByAuthor(s.LogFn, "eve")
+	s.Require().NoError(err)
+	s.Require().Empty(posts)
+
+	_, conflicts, err := loadPosts(opts.Storage, opts.FileName, opts.MdDir, time.UTC, authors)
+	s.Require().NoError(err)
+	s.Require().Equal([]Conflict{{ID: "both", FileName: "md/both.md", Field: "authors", JsonValue: "bob,ann", FrontMatterValue: "ann,bob"}}, conflicts)
+
+	fsys["md/eve.md"] = &fstest.MapFile{Data: []byte("---\ntitle: By Eve\nauthors: [eve]\n---\n")}
+	_, err = NewService(opts)
+	s.Require().ErrorContains(err, `post "eve": unknown author "eve"`)
+	issues, err := Lint(LintOptions{Storage: opts.Storage, FileName: opts.FileName, MdDir: opts.MdDir, Authors: authors})
+	s.Require().NoError(err)
+	s.Require().Len(issues, 1)
+	s.Require().Equal("md/eve.md: post eve: unknown author eve", issues[0].String())
+
+	_, err = PostInput{ID: "x", Title: "X", Date: "2024-01-01", Authors: []string{"ann", "", "eve"}}.post(time.UTC, authors)
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+	s.Require().Equal(map[string]string{"authors": "unknown author eve"}, validationError.Fields)
+}
+
+// TestTranslations tests the language of the posts and the lookup of their translations
+func (s *PostServiceTestSuite) TestTranslations() {
+	fsys := fstest.MapFS{
+		"md/boxes.md":    {Data: []byte("---\ntitle: Beyond boxes\ndate: 2024-03-01\nlang: en\ntranslationKey: boxes\n---\n")},
+		"md/boxes-hu.md": {Data: []byte("---\ntitle: A dobozokon túl\ndate: 2024-03-02\nlang: hu_hu\ntranslationKey: boxes\n---\n")},
+		"md/boxes-de.md": {Data: []byte("---\ntitle: Jenseits der Schachteln\ndate: 2024-03-03\nlang: de\ntranslationKey: boxes\ndraft: true\n---\n")},
+		"md/alone.md":    {Data: []byte("---\ntitle: Alone\ndate: 2024-03-04\n---\n")},
+	}
+	opts := ServiceOptions{Logger: s.Logger, Storage: NewFSStorage(fsys), MdDir: "md"}
+	testService, err := NewService(opts)
+	s.Require().NoError(err)
+
+	posts, err := testService.GetTranslations(s.LogFn, "boxes-hu")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2, "drafts should not be listed as translations")
+	s.Require().Equal("boxes", posts[0].ID)
+	s.Require().Equal("hu-HU", posts[1].Lang, "languages should be canonical")
+	posts, err = testService.GetTranslations(s.LogFn, "alone")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 1)
+	_, err = testService.GetTranslations(s.LogFn, "boxes-de")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+
+	fsys["md/bad.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Bad\nlang: not a language\n---\n")}
+	_, err = NewService(opts)
+	s.Require().ErrorContains(err, `post "bad": invalid lang "not a language"`)
+
+	_, err = PostInput{ID: "x", Title: "X", Date: "2024-01-01", Lang: "?"}.post(time.UTC, nil)
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+	s.Require().Contains(validationError.Fields, "lang")
+}
+
+// TestStorages tests that the same content loads from the local disk, a fs.FS and a zip archive
+func (s *PostServiceTestSuite) TestStorages() {
+	mapFS := fstest.MapFS{}
+	archive := filepath.Join(s.T().TempDir(), "content.zip")
+	f, err := os.Create(archive)
+	s.Require().NoError(err)
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"md_posts.json", "md/yaml-post.md", "md/toml-post.md", "md/legacy.md"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		s.Require().NoError(err)
+		mapFS[name] = &fstest.MapFile{Data: data}
+		w, err := zw.Create(name)
+		s.Require().NoError(err)
+		_, err = w.Write(data)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(zw.Close())
+	s.Require().NoError(f.Close())
+
+	archiveStorage, err := NewStorage(StorageOptions{Kind: StorageArchive, Archive: archive})
+	s.Require().NoError(err)
+	embedStorage, err := NewStorage(StorageOptions{Kind: StorageEmbed, Embedded: mapFS})
+	s.Require().NoError(err)
+	s.Require().False(embedStorage.Local())
+	localStorage, err := NewStorage(StorageOptions{})
+	s.Require().NoError(err)
+	s.Require().True(localStorage.Local())
+
+	for name, storage := range map[string]Storage{"archive": archiveStorage, "embed": embedStorage} {
+		testService, err := NewService(ServiceOptions{
+			Logger:   s.Logger,
+			Storage:  storage,
+			FileName: "md_posts.json",
+			MdDir:    "md",
+			Watch:    true,
+		})
+		s.Require().NoError(err, name)
+		posts, err := testService.GetPosts(s.LogFn)
+		s.Require().NoError(err, name)
+		s.Require().Len(posts, 3, name)
+		post, err := testService.GetPost(s.LogFn, "legacy")
+		s.Require().NoError(err, name)
+		s.Require().Equal("md/legacy.md", post.FileName, name)
+		results, err := testService.Search(s.LogFn, "legacy", 10)
+		s.Require().NoError(err, name)
+		s.Require().NotEmpty(results, "markdown bodies should be indexed from the storage")
+		s.Require().NoError(testService.Close())
+	}
+
+	_, err = NewStorage(StorageOptions{Kind: StorageEmbed})
+	s.Require().Error(err, "embed storage needs embedded content")
+	_, err = NewStorage(StorageOptions{Kind: "s3"})
+	s.Require().Error(err)
+	_, err = NewStorage(StorageOptions{Kind: StorageArchive, Archive: "testdata/md_posts.json"})
+	s.Require().Error(err, "a json file is not an archive")
+}
+
+// TestWritePosts tests creating, updating and deleting posts in the json file and the markdown directory
+func (s *PostServiceTestSuite) TestWritePosts() {
+	dir := s.T().TempDir()
+	mdDir := filepath.Join(dir, "md")
+	jsonFile := filepath.Join(dir, "posts.json")
+	for _, name := range []string{"md_posts.json", "md/yaml-post.md", "md/toml-post.md", "md/legacy.md"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		s.Require().NoError(err)
+		target := filepath.Join(dir, name)
+		if name == "md_posts.json" {
+			target = jsonFile
+		}
+		s.Require().NoError(os.MkdirAll(filepath.Dir(target), 0755))
+		s.Require().NoError(os.WriteFile(target, data, 0644))
+	}
+	testService, err := NewService(ServiceOptions{Logger: s.Logger, FileName: jsonFile, MdDir: mdDir})
+	s.Require().NoError(err)
+
+	_, err = testService.CreatePost(s.LogFn, PostInput{ID: "bad id", Date: "someday"})
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+	s.Require().Equal(map[string]string{
+		"id":    "may only contain letters, digits, - and _",
+		"title": "is required",
+		"date":  `invalid date "someday"`,
+	}, validationError.Fields)
+
+	created, err := testService.CreatePost(s.LogFn, PostInput{
+		ID: "new-post", Title: "New post", Tags: []string{"example", " new "}, Date: "2024-05-01", Markdown: "# New\n\nWritten by the API.\n",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"example", "new"}, created.Tags)
+	s.Require().Equal(filepath.Join(mdDir, "new-post.md"), created.FileName)
+	data, err := os.ReadFile(created.FileName)
+	s.Require().NoError(err)
+	s.Require().Equal("# New\n\nWritten by the API.\n", string(data))
+	results, err := testService.Search(s.LogFn, "written", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1, "the search index should include the new post")
+
+	_, err = testService.CreatePost(s.LogFn, PostInput{ID: "new-post", Title: "Again", Date: "2024-05-02"})
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+	s.Require().ErrorIs(keyError.Err, ErrKeyExists)
+
+	// a post described by front matter gets a json entry and loses its front matter
+	updated, err := testService.UpdatePost(s.LogFn, "yaml-post", PostInput{
+		Title: "Updated YAML", Date: "2021-03-04", Draft: true, Markdown: "Updated body.\n",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("Updated YAML", updated.Title)
+	s.Require().Empty(updated.Tags)
+	s.Require().Equal(filepath.Join(mdDir, "yaml-post.md"), updated.FileName)
+	_, err = testService.GetPost(s.LogFn, "yaml-post")
+	s.Require().Error(err, "the updated post is a draft")
+
+	_, err = testService.UpdatePost(s.LogFn, "yaml-post", PostInput{ID: "other", Title: "Renamed", Date: "2021-03-04"})
+	s.Require().ErrorAs(err, &validationError)
+	_, err = testService.UpdatePost(s.LogFn, "missing", PostInput{Title: "Missing", Date: "2021-03-04"})
+	s.Require().ErrorAs(err, &keyError)
+
+	s.Require().NoError(testService.DeletePost(s.LogFn, "legacy"))
+	s.Require().NoFileExists(filepath.Join(mdDir, "legacy.md"))
+	s.Require().ErrorAs(testService.DeletePost(s.LogFn, "legacy"), &keyError)
+
+	// the files written are loaded the same way by a new service
+	store, conflicts, err := loadPosts(NewLocalStorage(), jsonFile, mdDir, time.UTC, nil)
+	s.Require().NoError(err)
+	s.Require().Len(conflicts, 1, "only the renamed post of the test data should conflict")
+	s.Require().Len(store, 3)
+	s.Require().Equal("New post", store["new-post"].Title)
+	s.Require().True(store["yaml-post"].Draft)
+	s.Require().NotContains(store, "legacy")
+	entries, err := os.ReadDir(mdDir)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 3, "no temporary file should be left behind")
+
+	readOnly, err := NewService(ServiceOptions{Logger: s.Logger, Storage: NewFSStorage(os.DirFS("testdata")), FileName: "md_posts.json", MdDir: "md"})
+	s.Require().NoError(err)
+	_, err = readOnly.CreatePost(s.LogFn, PostInput{ID: "new-post", Title: "New post", Date: "2024-05-01"})
+	s.Require().ErrorIs(err, ErrReadOnly)
+}
+
+// TestWritePostsRollback tests that the markdown files are restored when the json file cannot be written
+func (s *PostServiceTestSuite) TestWritePostsRollback() {
+	dir := s.T().TempDir()
+	mdDir := filepath.Join(dir, "md")
+	// the name leaves no room for the temporary file written next to it, so only the json file cannot be replaced
+	jsonFile := filepath.Join(dir, strings.Repeat("p", 245)+".json")
+	data, err := os.ReadFile("testdata/md_posts.json")
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(jsonFile, data, 0644))
+	s.Require().NoError(os.MkdirAll(mdDir, 0755))
+	original, err := os.ReadFile("testdata/md/yaml-post.md")
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(mdDir, "yaml-post.md"), original, 0644))
+	testService, err := NewService(ServiceOptions{Logger: s.Logger, FileName: jsonFile, MdDir: mdDir})
+	s.Require().NoError(err)
+
+	_, err = testService.UpdatePost(s.LogFn, "yaml-post", PostInput{Title: "Updated YAML", Date: "2021-03-04", Markdown: "Lost body.\n"})
+	s.Require().Error(err)
+	data, err = os.ReadFile(filepath.Join(mdDir, "yaml-post.md"))
+	s.Require().NoError(err)
+	s.Require().Equal(string(original), string(data), "the previous markdown should be written back")
+	post, err := testService.GetPost(s.LogFn, "yaml-post")
+	s.Require().NoError(err)
+	s.Require().NotEqual("Updated YAML", post.Title)
+
+	_, err = testService.CreatePost(s.LogFn, PostInput{ID: "new-post", Title: "New post", Date: "2024-05-01", Markdown: "Lost too.\n"})
+	s.Require().Error(err)
+	s.Require().NoFileExists(filepath.Join(mdDir, "new-post.md"))
+	entries, err := os.ReadDir(mdDir)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1, "no temporary file should be left behind")
+}
+
+func (s *PostServiceTestSuite) TestRevisions() {
+	revisions := NewRevisions(s.T().TempDir())
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	revisions.now = func() time.Time { return now }
+
+	_, err := revisions.List("post")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+	_, err = revisions.Add("admin", "", PostInput{ID: "../post"})
+	s.Require().Error(err, "the post ID should not be a path")
+
+	in := PostInput{ID: "post", Title: "Post", Tags: []string{"go"}, Date: "2024-05-01", Markdown: "first\n"}
+	first, err := revisions.Add("admin", "", in)
+	s.Require().NoError(err)
+	s.Require().Equal("20240501T100000.000000000Z", first.ID)
+	in.Markdown = "second\n"
+	second, err := revisions.Add("editor", "Restored", in)
+	s.Require().NoError(err)
+	s.Require().NotEqual(first.ID, second.ID, "revisions of the same instant should be kept apart")
+
+	list, err := revisions.List("post")
+	s.Require().NoError(err)
+	s.Require().Len(list, 2)
+	s.Require().Equal(second.ID, list[0].ID, "the newest revision should be first")
+	s.Require().Equal("editor", list[0].Author)
+	s.Require().Equal("Restored", list[0].Note)
+
+	got, err := revisions.Get("post", first.ID)
+	s.Require().NoError(err)
+	s.Require().Equal("first\n", got.Post.Markdown)
+	_, err = revisions.Get("post", "../"+first.ID)
+	s.Require().ErrorAs(err, &keyError)
+	_, err = revisions.Get("post", "missing")
+	s.Require().ErrorAs(err, &keyError)
+
+	s.Require().Equal("title: Post\ntags: go\ndate: 2024-05-01\n---\nfirst\n", got.Text())
+}
+
 // TestKeyError tests the KeyError error type
 func (s *PostServiceTestSuite) TestKeyError() {
 	keyError := KeyError{Key: "test", Err: ErrKeyNotExist}
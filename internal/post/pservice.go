@@ -1,35 +1,80 @@
 package post
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"slices"
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/search"
 	"github.com/kegliz/silent-blog/internal/server/logger"
 )
 
 // pService is the implementation of the Service interface.
 type pService struct {
-	store map[string]Post
-	mdDir string
+	store    map[string]Post
+	index    *search.Index
+	links    links
+	storage  Storage
+	fileName string
+	mdDir    string
 	sync.RWMutex
-	logger *logger.Logger
+	// writeMu serializes the writes of the json file and the markdown directory
+	writeMu   sync.Mutex
+	logger    *logger.Logger
+	watcher   *fsnotify.Watcher
+	watchDone chan struct{}
+
+	showDrafts bool
+	// location is the site timezone of dates written without a zone
+	location *time.Location
+	// authors are the authors the posts may name
+	authors *author.Directory
+	// now is the clock deciding whether scheduled posts are published
+	now func() time.Time
+	// onReload is called after the watched posts have been reloaded, if set
+	onReload func()
 }
 
 // NewService returns a new Service.
-// If a filename is provided in options, the service will attempt to initialize the store from the file.
+// If a filename or a markdown directory is provided in options, the service will attempt to initialize the store from them.
+// With the Watch option the store is rebuilt in the background whenever they change.
 func NewService(opts ServiceOptions) (Service, error) {
 	p := pService{
-		store:  make(map[string]Post),
-		logger: opts.Logger,
-		mdDir:  opts.MdDir,
+		store:      make(map[string]Post),
+		index:      search.NewIndex(nil),
+		links:      buildLinks(nil),
+		logger:     opts.Logger,
+		fileName:   opts.FileName,
+		mdDir:      opts.MdDir,
+		showDrafts: opts.ShowDrafts,
+		location:   opts.Location,
+		authors:    opts.Authors,
+		storage:    opts.Storage,
+		now:        time.Now,
+		onReload:   opts.OnReload,
+	}
+	if p.location == nil {
+		p.location = time.UTC
 	}
-	if opts.FileName != "" {
-		if err := p.initPostsFromJson(opts.FileName, opts.MdDir); err != nil {
-			p.logger.Error().Err(err).Msg("initPostsFromJson")
-			return nil, fmt.Errorf("NewService: cannot init posts from json: %v", err)
+	if p.storage == nil {
+		p.storage = NewLocalStorage()
+	}
+	if opts.FileName != "" || opts.MdDir != "" {
+		if err := p.initPosts(opts.FileName, opts.MdDir); err != nil {
+			p.logger.Error().Err(err).Msg("initPosts")
+			return nil, fmt.Errorf("NewService: %v", err)
+		}
+		if opts.Watch && !p.storage.Local() {
+			p.logger.Warn().Msg("NewService: only posts on the local disk can be watched for changes")
+		} else if opts.Watch {
+			if err := p.watch(); err != nil {
+				p.logger.Error().Err(err).Msg("watch")
+				return nil, fmt.Errorf("NewService: cannot watch posts: %v", err)
+			}
 		}
 	}
 	return &p, nil
@@ -41,6 +86,18 @@ func (s *pService) GetPost(l logger.LoggingFn, id string) (Post, error) {
 	s.RLock()
 	defer s.RUnlock()
 	post, ok := s.store[id]
+	if !ok || !s.isVisible(post) {
+		return Post{}, &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+	return post, nil
+}
+
+// GetUnpublishedPost implements Service.
+func (s *pService) GetUnpublishedPost(l logger.LoggingFn, id string) (Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetUnpublishedPost")
+	s.RLock()
+	defer s.RUnlock()
+	post, ok := s.store[id]
 	if !ok {
 		return Post{}, &KeyError{Key: id, Err: ErrKeyNotExist}
 	}
@@ -50,28 +107,58 @@ func (s *pService) GetPost(l logger.LoggingFn, id string) (Post, error) {
 // GetPosts implements Service.
 func (s *pService) GetPosts(l logger.LoggingFn) ([]Post, error) {
 	l(logger.DebugLevel).Msg("PostService::GetPosts")
+	return s.posts(), nil
+}
+
+// GetPostsPage implements Service.
+func (s *pService) GetPostsPage(l logger.LoggingFn, req PageRequest) (PostPage, error) {
+	l(logger.DebugLevel).Int("page", req.Page).Str("cursor", req.Cursor).Msg("PostService::GetPostsPage")
+	return paginate(s.posts(), req)
+}
+
+// GetPostsByTag implements Service.
+func (s *pService) GetPostsByTag(l logger.LoggingFn, tag string) ([]Post, error) {
+	l(logger.DebugLevel).Str("tag", tag).Msg("PostService::GetPostsByTag")
+	posts := s.postsByTag(tag)
+	if len(posts) == 0 {
+		return nil, &KeyError{Key: tag, Err: ErrKeyNotExist}
+	}
+	return posts, nil
+}
+
+// GetPostsByTagPage implements Service.
+func (s *pService) GetPostsByTagPage(l logger.LoggingFn, tag string, req PageRequest) (PostPage, error) {
+	l(logger.DebugLevel).Str("tag", tag).Int("page", req.Page).Str("cursor", req.Cursor).Msg("PostService::GetPostsByTagPage")
+	posts := s.postsByTag(tag)
+	if len(posts) == 0 {
+		return PostPage{}, &KeyError{Key: tag, Err: ErrKeyNotExist}
+	}
+	return paginate(posts, req)
+}
+
+// posts returns every post of the store ordered by date.
+func (s *pService) posts() []Post {
 	s.RLock()
 	defer s.RUnlock()
 	posts := make([]Post, 0, len(s.store))
 	for _, post := range s.store {
-		posts = append(posts, post)
+		if s.isVisible(post) {
+			posts = append(posts, post)
+		}
 	}
-
-	// order posts by date
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date > posts[j].Date
-	})
-
-	return posts, nil
+	sortPosts(posts)
+	return posts
 }
 
-// GetPostsByTag implements Service.
-func (s *pService) GetPostsByTag(l logger.LoggingFn, tag string) ([]Post, error) {
-	l(logger.DebugLevel).Str("tag", tag).Msg("PostService::GetPostsByTag")
+// postsByTag returns the posts having a tag ordered by date.
+func (s *pService) postsByTag(tag string) []Post {
 	s.RLock()
 	defer s.RUnlock()
 	posts := make([]Post, 0, len(s.store))
 	for _, post := range s.store {
+		if !s.isVisible(post) {
+			continue
+		}
 		for _, t := range post.Tags {
 			if t == tag {
 				posts = append(posts, post)
@@ -79,40 +166,169 @@ func (s *pService) GetPostsByTag(l logger.LoggingFn, tag string) ([]Post, error)
 			}
 		}
 	}
+	sortPosts(posts)
+	return posts
+}
 
-	// order posts by date
+// GetPostsByAuthor implements Service.
+func (s *pService) GetPostsByAuthor(l logger.LoggingFn, id string) ([]Post, error) {
+	l(logger.DebugLevel).Str("author", id).Msg("PostService::GetPostsByAuthor")
+	s.RLock()
+	defer s.RUnlock()
+	posts := make([]Post, 0)
+	for _, post := range s.store {
+		if s.isVisible(post) && slices.Contains(post.Authors, id) {
+			posts = append(posts, post)
+		}
+	}
+	sortPosts(posts)
+	return posts, nil
+}
+
+// GetTranslations implements Service.
+func (s *pService) GetTranslations(l logger.LoggingFn, id string) ([]Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetTranslations")
+	s.RLock()
+	defer s.RUnlock()
+	post, ok := s.store[id]
+	if !ok || !s.isVisible(post) {
+		return nil, &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+	if post.TranslationKey == "" {
+		return []Post{post}, nil
+	}
+	posts := make([]Post, 0)
+	for _, p := range s.store {
+		if p.TranslationKey == post.TranslationKey && s.isVisible(p) {
+			posts = append(posts, p)
+		}
+	}
+	sortTranslations(posts)
+	return posts, nil
+}
+
+// GetSeries implements Service.
+func (s *pService) GetSeries(l logger.LoggingFn, name string) ([]Post, error) {
+	l(logger.DebugLevel).Str("name", name).Msg("PostService::GetSeries")
+	s.RLock()
+	posts := make([]Post, 0)
+	for _, post := range s.store {
+		if post.Series != nil && post.Series.Name == name && s.isVisible(post) {
+			posts = append(posts, post)
+		}
+	}
+	s.RUnlock()
+	if len(posts) == 0 {
+		return nil, &KeyError{Key: name, Err: ErrKeyNotExist}
+	}
+
+	// order by part, parts without a number follow the numbered ones oldest first
 	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date > posts[j].Date
+		pi, pj := posts[i].Series.Part, posts[j].Series.Part
+		if (pi == 0) != (pj == 0) {
+			return pj == 0
+		}
+		if pi != pj {
+			return pi < pj
+		}
+		if !posts[i].Date.Equal(posts[j].Date) {
+			return posts[i].Date.Before(posts[j].Date)
+		}
+		return posts[i].ID < posts[j].ID
 	})
-
 	return posts, nil
 }
 
+// GetTags implements Service.
+func (s *pService) GetTags(l logger.LoggingFn, order TagOrder) ([]TagCount, error) {
+	l(logger.DebugLevel).Str("order", string(order)).Msg("PostService::GetTags")
+	s.RLock()
+	counts := make(map[string]int)
+	for _, post := range s.store {
+		if !s.isVisible(post) {
+			continue
+		}
+		for _, t := range post.Tags {
+			counts[t]++
+		}
+	}
+	s.RUnlock()
+
+	tags := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if order == TagOrderCount && tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	return tags, nil
+}
+
+// isVisible reports whether a post can be shown to readers right now.
+func (s *pService) isVisible(p Post) bool {
+	return s.showDrafts || p.IsPublished(s.now())
+}
+
+// initPosts initializes the store from the markdown directory and the json file.
+// Conflicts between the two sources are logged as warnings, the json file wins.
+func (s *pService) initPosts(fileName string, mdDir string) error {
+	s.logger.Debug().Str("filename", fileName).Str("mddir", mdDir).Msg("initPosts")
+	store, conflicts, err := loadPosts(s.storage, fileName, mdDir, s.location, s.authors)
+	if err != nil {
+		return err
+	}
+	for _, c := range conflicts {
+		s.logger.Warn().
+			Str("id", c.ID).
+			Str("filename", c.FileName).
+			Str("field", c.Field).
+			Msg("post conflict: " + c.String())
+	}
+	s.setStore(store)
+	return nil
+}
+
+// setStore replaces the store and the search index and links built from it.
+// The stats of the posts are worked out on the way.
+func (s *pService) setStore(store map[string]Post) {
+	index := buildIndex(store, s.summarize(store))
+	links := buildLinks(store)
+	s.Lock()
+	defer s.Unlock()
+	s.store = store
+	s.index = index
+	s.links = links
+}
+
 // initPostsFromJson initializes the store from a json file.
 func (s *pService) initPostsFromJson(fileName string, mdDir string) error {
 	s.logger.Debug().Str("filename", fileName).Msg("initPostsFromJson")
-	file, err := os.Open(fileName)
+	posts, _, err := readPostsFromJson(s.storage, fileName, mdDir, s.location)
 	if err != nil {
-		return fmt.Errorf("initPostsFromJson: cannot open file : %v", err)
+		return fmt.Errorf("initPostsFromJson: %v", err)
 	}
-	defer file.Close()
-
-	var posts []Post
-	if err = json.NewDecoder(file).Decode(&posts); err != nil {
-		return fmt.Errorf("initPostsFromJson: cannot unmarshal json file : %v", err)
+	for i := range posts {
+		if posts[i], err = validatePost(posts[i], s.authors); err != nil {
+			return fmt.Errorf("initPostsFromJson: %v", err)
+		}
 	}
 
-	s.Lock()
-	defer s.Unlock()
+	s.RLock()
+	store := make(map[string]Post, len(s.store)+len(posts))
+	for id, p := range s.store {
+		store[id] = p
+	}
+	s.RUnlock()
 	for _, p := range posts {
 		if p.ID != "" {
-			if p.FileName != "" && mdDir != "" {
-				p.FileName = mdDir + "/" + p.FileName
-			}
-
-			s.store[p.ID] = p
+			store[p.ID] = p
 		}
 	}
+	s.setStore(store)
 
 	return nil
 
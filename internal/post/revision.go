@@ -0,0 +1,152 @@
+package post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/fileutil"
+)
+
+// revisionLayout formats the IDs of revisions, which sort in time order.
+const revisionLayout = "20060102T150405.000000000Z"
+
+type (
+	// Revision is a saved version of a post.
+	Revision struct {
+		ID     string    `json:"id"`
+		PostID string    `json:"postId"`
+		Time   time.Time `json:"time"`
+		Author string    `json:"author"`
+		// Note describes how the revision was made, e.g. that it restores an earlier one.
+		Note string    `json:"note,omitempty"`
+		Post PostInput `json:"post"`
+	}
+
+	// Revisions keeps every saved version of the posts, as a json file per revision in a directory per post.
+	Revisions struct {
+		dir string
+		mu  sync.Mutex
+		// now is the clock of the revision times
+		now func() time.Time
+	}
+)
+
+// NewRevisions returns the revisions kept in dir.
+func NewRevisions(dir string) *Revisions {
+	return &Revisions{dir: dir, now: time.Now}
+}
+
+// Add stores a new revision of a post saved by author.
+func (r *Revisions) Add(author string, note string, in PostInput) (Revision, error) {
+	if !validID.MatchString(in.ID) {
+		return Revision{}, fmt.Errorf("Revisions::Add: invalid post ID %q", in.ID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rev := Revision{PostID: in.ID, Time: r.now().UTC(), Author: author, Note: note, Post: in}
+	dir := filepath.Join(r.dir, in.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Revision{}, fmt.Errorf("Revisions::Add: cannot create directory : %v", err)
+	}
+	// revisions of the same instant are told apart by moving the later one forward
+	for {
+		rev.ID = rev.Time.Format(revisionLayout)
+		if _, err := os.Stat(filepath.Join(dir, rev.ID+".json")); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		rev.Time = rev.Time.Add(time.Nanosecond)
+	}
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return Revision{}, fmt.Errorf("Revisions::Add: cannot encode revision : %v", err)
+	}
+	if err := fileutil.WriteAtomic(filepath.Join(dir, rev.ID+".json"), data); err != nil {
+		return Revision{}, fmt.Errorf("Revisions::Add: cannot write revision : %v", err)
+	}
+	return rev, nil
+}
+
+// List returns the revisions of a post, newest first.
+// It returns a KeyError if the post has no revisions.
+func (r *Revisions) List(postID string) ([]Revision, error) {
+	if !validID.MatchString(postID) {
+		return nil, &KeyError{Key: postID, Err: ErrKeyNotExist}
+	}
+	entries, err := os.ReadDir(filepath.Join(r.dir, postID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &KeyError{Key: postID, Err: ErrKeyNotExist}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Revisions::List: cannot read revisions : %v", err)
+	}
+	revisions := make([]Revision, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		rev, err := r.Get(postID, id)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if len(revisions) == 0 {
+		return nil, &KeyError{Key: postID, Err: ErrKeyNotExist}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].ID > revisions[j].ID
+	})
+	return revisions, nil
+}
+
+// Get returns a revision of a post.
+// It returns a KeyError if the revision does not exist.
+func (r *Revisions) Get(postID string, id string) (Revision, error) {
+	if !validID.MatchString(postID) || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return Revision{}, &KeyError{Key: postID + "@" + id, Err: ErrKeyNotExist}
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, postID, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Revision{}, &KeyError{Key: postID + "@" + id, Err: ErrKeyNotExist}
+	}
+	if err != nil {
+		return Revision{}, fmt.Errorf("Revisions::Get: cannot read revision : %v", err)
+	}
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return Revision{}, fmt.Errorf("Revisions::Get: cannot decode revision %s : %v", id, err)
+	}
+	return rev, nil
+}
+
+// Text returns the revision as a document to compare with other revisions: the fields, then the markdown body.
+func (rev Revision) Text() string {
+	var b strings.Builder
+	in := rev.Post
+	fmt.Fprintf(&b, "title: %s\n", in.Title)
+	fmt.Fprintf(&b, "tags: %s\n", strings.Join(in.Tags, ", "))
+	fmt.Fprintf(&b, "date: %s\n", in.Date)
+	if in.PublishAt != "" {
+		fmt.Fprintf(&b, "publishAt: %s\n", in.PublishAt)
+	}
+	if in.Draft {
+		b.WriteString("draft: true\n")
+	}
+	if in.Series != nil {
+		fmt.Fprintf(&b, "series: %s\n", in.Series.Name)
+		if in.Series.Part != 0 {
+			fmt.Fprintf(&b, "part: %d\n", in.Series.Part)
+		}
+	}
+	b.WriteString("---\n")
+	b.WriteString(in.Markdown)
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package post
+
+import (
+	"io/fs"
+
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/search"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+)
+
+// Search implements Service.
+func (s *pService) Search(l logger.LoggingFn, query string, limit int) ([]SearchResult, error) {
+	l(logger.DebugLevel).Str("query", query).Msg("PostService::Search")
+	s.RLock()
+	defer s.RUnlock()
+	// unpublished posts are filtered out here, the limit is applied after
+	matches := s.index.Search(search.ParseQuery(query), 0)
+	results := make([]SearchResult, 0, len(matches))
+	for _, m := range matches {
+		post, ok := s.store[m.ID]
+		if !ok || !s.isVisible(post) {
+			continue
+		}
+		results = append(results, SearchResult{Post: post, Snippet: m.Snippet})
+		if limit > 0 && len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// summarize sets the stats of the posts of store from their markdown body and returns the text of every post by ID.
+// Posts whose markdown file cannot be read are summarized from their content field.
+func (s *pService) summarize(store map[string]Post) map[string]string {
+	texts := make(map[string]string, len(store))
+	for id, p := range store {
+		src := []byte(p.Content)
+		if p.FileName != "" {
+			markdown, err := fs.ReadFile(s.storage, p.FileName)
+			if err != nil {
+				s.logger.Warn().Err(err).Str("id", p.ID).Msg("summarize: summarizing post without its markdown")
+			} else {
+				src = markdown
+			}
+		}
+		summary := render.Summarize(src)
+		p.Stats = newStats(summary)
+		store[id] = p
+		texts[id] = summary.Text
+	}
+	return texts
+}
+
+// buildIndex indexes the title, tags and text of the posts.
+func buildIndex(store map[string]Post, texts map[string]string) *search.Index {
+	posts := make([]Post, 0, len(store))
+	for _, p := range store {
+		posts = append(posts, p)
+	}
+	sortPosts(posts)
+
+	docs := make([]search.Document, len(posts))
+	for i, p := range posts {
+		docs[i] = search.Document{
+			ID:    p.ID,
+			Title: p.Title,
+			Tags:  p.Tags,
+			Year:  postYear(p),
+			Body:  texts[p.ID],
+		}
+	}
+	return search.NewIndex(docs)
+}
+
+// postYear returns the year of the post date, 0 if it has none.
+func postYear(p Post) int {
+	if p.Date.IsZero() {
+		return 0
+	}
+	return p.Date.Year()
+}
@@ -0,0 +1,929 @@
+package post
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/search"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+
+	// the pure Go sqlite driver, registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables of the post database.
+// Dates are unix seconds, unknown dates are stored as the zero time so that they sort last.
+// updated_at is in unix nanoseconds, it is the modification time the rendered markdown is cached by.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS posts (
+	id          TEXT PRIMARY KEY,
+	title       TEXT NOT NULL,
+	date        INTEGER NOT NULL,
+	content     TEXT NOT NULL DEFAULT '',
+	filename    TEXT NOT NULL DEFAULT '',
+	draft       INTEGER NOT NULL DEFAULT 0,
+	publish_at  INTEGER,
+	series_name TEXT,
+	series_part INTEGER,
+	extra       TEXT,
+	markdown    BLOB,
+	updated_at  INTEGER NOT NULL,
+	lang            TEXT NOT NULL DEFAULT '',
+	translation_key TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS posts_date ON posts (date DESC, id);
+CREATE INDEX IF NOT EXISTS posts_series ON posts (series_name, series_part);
+CREATE INDEX IF NOT EXISTS posts_filename ON posts (filename);
+CREATE INDEX IF NOT EXISTS posts_translation_key ON posts (translation_key);
+CREATE TABLE IF NOT EXISTS post_tags (
+	post_id  TEXT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+	tag      TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (post_id, tag)
+);
+CREATE INDEX IF NOT EXISTS post_tags_tag ON post_tags (tag, post_id);
+CREATE TABLE IF NOT EXISTS post_authors (
+	post_id  TEXT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+	author   TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (post_id, author)
+);
+CREATE INDEX IF NOT EXISTS post_authors_author ON post_authors (author, post_id);
+`
+
+// sqliteAddedColumns are the columns of the posts table added after its first version,
+// they are added to older databases by OpenSQLite.
+var sqliteAddedColumns = []struct {
+	name       string
+	definition string
+}{
+	{"lang", "TEXT NOT NULL DEFAULT ''"},
+	{"translation_key", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// postColumns are the columns scanned by scanPost, the tags and the authors are aggregated as json arrays.
+const postColumns = `p.id, p.title, p.date, p.content, p.filename, p.draft, p.publish_at, p.series_name, p.series_part, p.extra, p.lang, p.translation_key,
+	(SELECT json_group_array(tag) FROM (SELECT tag FROM post_tags t WHERE t.post_id = p.id ORDER BY t.position)),
+	(SELECT json_group_array(author) FROM (SELECT author FROM post_authors a WHERE a.post_id = p.id ORDER BY a.position))`
+
+// postOrder is the order of sortPosts.
+const postOrder = ` ORDER BY p.date DESC, p.id`
+
+type (
+	// SQLiteServiceOptions is a struct that contains the options for constructing a Service backed by SQLite.
+	// DB is a database opened with OpenSQLite, it is closed with the service.
+	// Authors are the authors posts may name, as in ServiceOptions.
+	SQLiteServiceOptions struct {
+		Logger     *logger.Logger
+		DB         *sql.DB
+		ShowDrafts bool
+		Location   *time.Location
+		Authors    *author.Directory
+	}
+
+	// TransferOptions are the content sources read by ImportSQLite and written by ExportSQLite.
+	// Storage is only used by ImportSQLite, ExportSQLite writes to the local disk.
+	// Authors are the authors imported posts may name.
+	// Replace makes ImportSQLite delete the posts of the database missing from the files.
+	TransferOptions struct {
+		Storage  Storage
+		FileName string
+		MdDir    string
+		Location *time.Location
+		Authors  *author.Directory
+		Replace  bool
+	}
+
+	// sqlService is the implementation of the Service interface backed by SQLite.
+	sqlService struct {
+		db *sql.DB
+		// mu guards index and stats, which are rebuilt after every write
+		mu    sync.RWMutex
+		index *search.Index
+		// stats are the stats of the posts by ID, they are worked out from the markdown with the index
+		stats      map[string]Stats
+		logger     *logger.Logger
+		showDrafts bool
+		location   *time.Location
+		authors    *author.Directory
+		now        func() time.Time
+	}
+
+	// sqliteStorage is the Storage of the markdown files kept in the database.
+	sqliteStorage struct {
+		db *sql.DB
+	}
+
+	// sqliteFile is a markdown file read from the database.
+	sqliteFile struct {
+		*bytes.Reader
+		info sqliteFileInfo
+	}
+
+	// sqliteFileInfo implements fs.FileInfo for a sqliteFile.
+	sqliteFileInfo struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	// rowScanner is implemented by sql.Row and sql.Rows.
+	rowScanner interface {
+		Scan(dest ...interface{}) error
+	}
+)
+
+// OpenSQLite opens the post database, creating its tables if needed.
+func OpenSQLite(fileName string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", fileName+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("OpenSQLite: cannot open database : %v", err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("OpenSQLite: cannot migrate database : %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("OpenSQLite: cannot create tables : %v", err)
+	}
+	return db, nil
+}
+
+// migrateSQLite adds the sqliteAddedColumns missing from the posts table of an existing database,
+// and converts the updated_at values written in unix seconds by its first version to nanoseconds.
+func migrateSQLite(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('posts')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		// a new database, the schema creates the table with every column
+		return nil
+	}
+	for _, c := range sqliteAddedColumns {
+		if columns[c.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE posts ADD COLUMN ` + c.name + ` ` + c.definition); err != nil {
+			return err
+		}
+	}
+	// no time since 1970 has as many nanoseconds, nor any time before the year 5000 as many seconds
+	if _, err := db.Exec(`UPDATE posts SET updated_at = updated_at * 1000000000 WHERE updated_at > 0 AND updated_at < 100000000000`); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewSQLiteService returns a Service reading the posts from a SQLite database.
+// Tag lookups, ordering and pagination are database queries, only the search index is kept in memory.
+func NewSQLiteService(opts SQLiteServiceOptions) (Service, error) {
+	s := &sqlService{
+		db:         opts.DB,
+		logger:     opts.Logger,
+		showDrafts: opts.ShowDrafts,
+		location:   opts.Location,
+		authors:    opts.Authors,
+		now:        time.Now,
+	}
+	if s.location == nil {
+		s.location = time.UTC
+	}
+	index, stats, err := s.buildIndex()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("buildIndex")
+		return nil, fmt.Errorf("NewSQLiteService: %v", err)
+	}
+	s.index, s.stats = index, stats
+	return s, nil
+}
+
+// NewSQLiteStorage returns the Storage of the markdown files kept in the database, by post file name.
+func NewSQLiteStorage(db *sql.DB) Storage {
+	return sqliteStorage{db: db}
+}
+
+// GetPost implements Service.
+func (s *sqlService) GetPost(l logger.LoggingFn, id string) (Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetPost")
+	visible, args := s.visible()
+	return s.queryPost(`SELECT `+postColumns+` FROM posts p WHERE p.id = ? AND `+visible, append([]interface{}{id}, args...)...)
+}
+
+// GetUnpublishedPost implements Service.
+func (s *sqlService) GetUnpublishedPost(l logger.LoggingFn, id string) (Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetUnpublishedPost")
+	return s.queryPost(`SELECT `+postColumns+` FROM posts p WHERE p.id = ?`, id)
+}
+
+// GetPosts implements Service.
+func (s *sqlService) GetPosts(l logger.LoggingFn) ([]Post, error) {
+	l(logger.DebugLevel).Msg("PostService::GetPosts")
+	visible, args := s.visible()
+	return s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE `+visible+postOrder, args...)
+}
+
+// GetPostsPage implements Service.
+func (s *sqlService) GetPostsPage(l logger.LoggingFn, req PageRequest) (PostPage, error) {
+	l(logger.DebugLevel).Int("page", req.Page).Str("cursor", req.Cursor).Msg("PostService::GetPostsPage")
+	visible, args := s.visible()
+	return s.page(visible, args, req)
+}
+
+// GetPostsByTag implements Service.
+func (s *sqlService) GetPostsByTag(l logger.LoggingFn, tag string) ([]Post, error) {
+	l(logger.DebugLevel).Str("tag", tag).Msg("PostService::GetPostsByTag")
+	filter, args := s.tagFilter(tag)
+	posts, err := s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE `+filter+postOrder, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, &KeyError{Key: tag, Err: ErrKeyNotExist}
+	}
+	return posts, nil
+}
+
+// GetPostsByTagPage implements Service.
+func (s *sqlService) GetPostsByTagPage(l logger.LoggingFn, tag string, req PageRequest) (PostPage, error) {
+	l(logger.DebugLevel).Str("tag", tag).Int("page", req.Page).Str("cursor", req.Cursor).Msg("PostService::GetPostsByTagPage")
+	filter, args := s.tagFilter(tag)
+	page, err := s.page(filter, args, req)
+	if err != nil {
+		return PostPage{}, err
+	}
+	if page.Total == 0 {
+		return PostPage{}, &KeyError{Key: tag, Err: ErrKeyNotExist}
+	}
+	return page, nil
+}
+
+// GetPostsByAuthor implements Service.
+func (s *sqlService) GetPostsByAuthor(l logger.LoggingFn, id string) ([]Post, error) {
+	l(logger.DebugLevel).Str("author", id).Msg("PostService::GetPostsByAuthor")
+	visible, args := s.visible()
+	return s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE p.id IN (SELECT post_id FROM post_authors WHERE author = ?) AND `+visible+postOrder,
+		append([]interface{}{id}, args...)...)
+}
+
+// GetTranslations implements Service.
+func (s *sqlService) GetTranslations(l logger.LoggingFn, id string) ([]Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetTranslations")
+	visible, args := s.visible()
+	post, err := s.queryPost(`SELECT `+postColumns+` FROM posts p WHERE p.id = ? AND `+visible, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if post.TranslationKey == "" {
+		return []Post{post}, nil
+	}
+	return s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE p.translation_key = ? AND `+visible+` ORDER BY p.lang, p.id`,
+		append([]interface{}{post.TranslationKey}, args...)...)
+}
+
+// GetSeries implements Service.
+func (s *sqlService) GetSeries(l logger.LoggingFn, name string) ([]Post, error) {
+	l(logger.DebugLevel).Str("name", name).Msg("PostService::GetSeries")
+	visible, args := s.visible()
+	// parts without a number follow the numbered ones oldest first, as in pService
+	posts, err := s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE p.series_name = ? AND `+visible+`
+		ORDER BY COALESCE(p.series_part, 0) = 0, p.series_part, p.date, p.id`, append([]interface{}{name}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, &KeyError{Key: name, Err: ErrKeyNotExist}
+	}
+	return posts, nil
+}
+
+// GetNeighbours implements Service.
+func (s *sqlService) GetNeighbours(l logger.LoggingFn, id string) (Neighbours, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::GetNeighbours")
+	post, err := s.queryPost(`SELECT `+postColumns+` FROM posts p WHERE p.id = ?`, id)
+	if err != nil {
+		return Neighbours{}, err
+	}
+	date := post.Date.Unix()
+	visible, args := s.visible()
+
+	var n Neighbours
+	older, err := s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE (p.date < ? OR (p.date = ? AND p.id > ?)) AND `+visible+postOrder+` LIMIT 1`,
+		append([]interface{}{date, date, id}, args...)...)
+	if err != nil {
+		return Neighbours{}, err
+	}
+	if len(older) > 0 {
+		n.Previous = &older[0]
+	}
+	newer, err := s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE (p.date > ? OR (p.date = ? AND p.id < ?)) AND `+visible+` ORDER BY p.date, p.id DESC LIMIT 1`,
+		append([]interface{}{date, date, id}, args...)...)
+	if err != nil {
+		return Neighbours{}, err
+	}
+	if len(newer) > 0 {
+		n.Next = &newer[0]
+	}
+	related, err := s.queryPosts(`SELECT `+postColumns+` FROM posts p
+		JOIN post_tags t ON t.post_id = p.id
+		WHERE t.tag IN (SELECT tag FROM post_tags WHERE post_id = ?) AND p.id <> ? AND `+visible+`
+		GROUP BY p.id ORDER BY COUNT(*) DESC, p.date DESC, p.id LIMIT ?`,
+		append(append([]interface{}{id, id}, args...), RelatedLimit)...)
+	if err != nil {
+		return Neighbours{}, err
+	}
+	n.Related = related
+	return n, nil
+}
+
+// GetTags implements Service.
+func (s *sqlService) GetTags(l logger.LoggingFn, order TagOrder) ([]TagCount, error) {
+	l(logger.DebugLevel).Str("order", string(order)).Msg("PostService::GetTags")
+	orderBy := ` ORDER BY t.tag`
+	if order == TagOrderCount {
+		orderBy = ` ORDER BY COUNT(*) DESC, t.tag`
+	}
+	visible, args := s.visible()
+	rows, err := s.db.Query(`SELECT t.tag, COUNT(*) FROM post_tags t JOIN posts p ON p.id = t.post_id
+		WHERE `+visible+` GROUP BY t.tag`+orderBy, args...)
+	if err != nil {
+		return nil, fmt.Errorf("GetTags: cannot query tags : %v", err)
+	}
+	defer rows.Close()
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("GetTags: cannot scan tag : %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// Search implements Service.
+func (s *sqlService) Search(l logger.LoggingFn, query string, limit int) ([]SearchResult, error) {
+	l(logger.DebugLevel).Str("query", query).Msg("PostService::Search")
+	// unpublished posts are filtered out here, the limit is applied after
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+	matches := index.Search(search.ParseQuery(query), 0)
+	results := make([]SearchResult, 0, len(matches))
+	visible, args := s.visible()
+	for _, m := range matches {
+		post, err := s.queryPost(`SELECT `+postColumns+` FROM posts p WHERE p.id = ? AND `+visible, append([]interface{}{m.ID}, args...)...)
+		var keyError *KeyError
+		if errors.As(err, &keyError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Post: post, Snippet: m.Snippet})
+		if limit > 0 && len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// Close implements Service.
+func (s *sqlService) Close() error {
+	return s.db.Close()
+}
+
+// CreatePost implements Service.
+func (s *sqlService) CreatePost(l logger.LoggingFn, in PostInput) (Post, error) {
+	l(logger.DebugLevel).Str("id", in.ID).Msg("PostService::CreatePost")
+	p, err := in.post(s.location, s.authors)
+	if err != nil {
+		return Post{}, err
+	}
+	err = s.write(func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE id = ?)`, p.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("cannot query post %s : %v", p.ID, err)
+		}
+		if exists {
+			return &KeyError{Key: p.ID, Err: ErrKeyExists}
+		}
+		return insertPost(tx, p, p.ID+".md", []byte(in.Markdown), s.now().UnixNano())
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return s.GetUnpublishedPost(l, p.ID)
+}
+
+// UpdatePost implements Service.
+func (s *sqlService) UpdatePost(l logger.LoggingFn, id string, in PostInput) (Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::UpdatePost")
+	if in.ID == "" {
+		in.ID = id
+	}
+	p, err := in.post(s.location, s.authors)
+	if err != nil {
+		return Post{}, err
+	}
+	if p.ID != id {
+		return Post{}, &ValidationError{Fields: map[string]string{"id": "cannot be changed"}}
+	}
+	err = s.write(func(tx *sql.Tx) error {
+		var fileName string
+		var updatedAt int64
+		err := tx.QueryRow(`SELECT filename, updated_at FROM posts WHERE id = ?`, id).Scan(&fileName, &updatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &KeyError{Key: id, Err: ErrKeyNotExist}
+		}
+		if err != nil {
+			return fmt.Errorf("cannot query post %s : %v", id, err)
+		}
+		if fileName == "" {
+			fileName = id + ".md"
+		}
+		// every save changes the modification time, so that the rendered markdown is never served from the cache
+		now := s.now().UnixNano()
+		if now <= updatedAt {
+			now = updatedAt + 1
+		}
+		return insertPost(tx, p, fileName, []byte(in.Markdown), now)
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return s.GetUnpublishedPost(l, id)
+}
+
+// DeletePost implements Service.
+func (s *sqlService) DeletePost(l logger.LoggingFn, id string) error {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::DeletePost")
+	return s.write(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM posts WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("cannot delete post %s : %v", id, err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return &KeyError{Key: id, Err: ErrKeyNotExist}
+		}
+		return nil
+	})
+}
+
+// write runs fn in a transaction and rebuilds the search index and the stats once it is committed.
+func (s *sqlService) write(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction : %v", err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot commit : %v", err)
+	}
+	index, stats, err := s.buildIndex()
+	if err != nil {
+		return fmt.Errorf("cannot rebuild search index : %v", err)
+	}
+	s.mu.Lock()
+	s.index, s.stats = index, stats
+	s.mu.Unlock()
+	return nil
+}
+
+// visible returns the condition selecting the posts readers can see right now.
+func (s *sqlService) visible() (string, []interface{}) {
+	if s.showDrafts {
+		return "1 = 1", nil
+	}
+	return "p.draft = 0 AND (p.publish_at IS NULL OR p.publish_at <= ?)", []interface{}{s.now().Unix()}
+}
+
+// tagFilter returns the condition selecting the visible posts having a tag.
+func (s *sqlService) tagFilter(tag string) (string, []interface{}) {
+	visible, args := s.visible()
+	return "p.id IN (SELECT post_id FROM post_tags WHERE tag = ?) AND " + visible, append([]interface{}{tag}, args...)
+}
+
+// page queries the page selected by req of the posts matching filter, as paginate does in memory.
+func (s *sqlService) page(filter string, args []interface{}, req PageRequest) (PostPage, error) {
+	if req.Size < 1 {
+		req.Size = DefaultPageSize
+	}
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM posts p WHERE `+filter, args...).Scan(&total); err != nil {
+		return PostPage{}, fmt.Errorf("page: cannot count posts : %v", err)
+	}
+
+	var start int
+	var posts []Post
+	var err error
+	switch {
+	case req.Cursor != "":
+		after, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return PostPage{}, err
+		}
+		// the cursor post itself may be gone since, the page starts at the first post after it
+		date := after.Date.Unix()
+		cursorArgs := append([]interface{}{date, date, after.ID}, args...)
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM posts p WHERE (p.date > ? OR (p.date = ? AND p.id <= ?)) AND `+filter, cursorArgs...).Scan(&start); err != nil {
+			return PostPage{}, fmt.Errorf("page: cannot count posts : %v", err)
+		}
+		posts, err = s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE (p.date < ? OR (p.date = ? AND p.id > ?)) AND `+filter+postOrder+` LIMIT ?`,
+			append(cursorArgs, req.Size)...)
+		if err != nil {
+			return PostPage{}, err
+		}
+	case req.Page > 0:
+		start = (req.Page - 1) * req.Size
+		posts, err = s.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE `+filter+postOrder+` LIMIT ? OFFSET ?`,
+			append(args, req.Size, start)...)
+		if err != nil {
+			return PostPage{}, err
+		}
+	default:
+		return PostPage{}, fmt.Errorf("page: page %d: %w", req.Page, ErrInvalidPage)
+	}
+
+	page := PostPage{Posts: posts, Total: total}
+	end := start + len(posts)
+	if len(posts) > 0 && end < total {
+		page.NextCursor = encodeCursor(posts[len(posts)-1])
+		if end%req.Size == 0 {
+			page.NextPage = end/req.Size + 1
+		}
+	}
+	return page, nil
+}
+
+// queryPost returns the single post selected by query, a KeyError if there is none.
+func (s *sqlService) queryPost(query string, args ...interface{}) (Post, error) {
+	post, err := s.scanPost(s.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		id, _ := args[0].(string)
+		return Post{}, &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+	return post, err
+}
+
+// queryPosts returns the posts selected by query.
+func (s *sqlService) queryPosts(query string, args ...interface{}) ([]Post, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("queryPosts: cannot query posts : %v", err)
+	}
+	defer rows.Close()
+	posts := make([]Post, 0)
+	for rows.Next() {
+		post, err := s.scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// scanPost scans the postColumns of a row.
+func (s *sqlService) scanPost(row rowScanner) (Post, error) {
+	var p Post
+	var date int64
+	var publishAt, seriesPart sql.NullInt64
+	var seriesName, extra sql.NullString
+	var tags, authors string
+	err := row.Scan(&p.ID, &p.Title, &date, &p.Content, &p.FileName, &p.Draft, &publishAt, &seriesName, &seriesPart, &extra, &p.Lang, &p.TranslationKey, &tags, &authors)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Post{}, err
+	}
+	if err != nil {
+		return Post{}, fmt.Errorf("scanPost: cannot scan post : %v", err)
+	}
+	p.Date = fromUnix(date, s.location)
+	if publishAt.Valid {
+		t := time.Unix(publishAt.Int64, 0).In(s.location)
+		p.PublishAt = &t
+	}
+	if seriesName.Valid {
+		p.Series = &Series{Name: seriesName.String, Part: int(seriesPart.Int64)}
+	}
+	if extra.Valid {
+		if err := json.Unmarshal([]byte(extra.String), &p.Extra); err != nil {
+			return Post{}, fmt.Errorf("scanPost: cannot decode extra fields of %s : %v", p.ID, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(tags), &p.Tags); err != nil {
+		return Post{}, fmt.Errorf("scanPost: cannot decode tags of %s : %v", p.ID, err)
+	}
+	if len(p.Tags) == 0 {
+		p.Tags = nil
+	}
+	if err := json.Unmarshal([]byte(authors), &p.Authors); err != nil {
+		return Post{}, fmt.Errorf("scanPost: cannot decode authors of %s : %v", p.ID, err)
+	}
+	if len(p.Authors) == 0 {
+		p.Authors = nil
+	}
+	s.mu.RLock()
+	p.Stats = s.stats[p.ID]
+	s.mu.RUnlock()
+	return p, nil
+}
+
+// buildIndex indexes every post of the database and works out their stats, as pService.setStore does with the store.
+// It returns an error if a post names an unknown author.
+func (s *sqlService) buildIndex() (*search.Index, map[string]Stats, error) {
+	rows, err := s.db.Query(`SELECT ` + postColumns + `, p.markdown FROM posts p` + postOrder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("buildIndex: cannot query posts : %v", err)
+	}
+	defer rows.Close()
+	var docs []search.Document
+	stats := make(map[string]Stats)
+	for rows.Next() {
+		var markdown []byte
+		post, err := s.scanPost(scanWith(rows, &markdown))
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := validatePost(post, s.authors); err != nil {
+			return nil, nil, fmt.Errorf("buildIndex: %v", err)
+		}
+		if markdown == nil {
+			markdown = []byte(post.Content)
+		}
+		summary := render.Summarize(markdown)
+		stats[post.ID] = newStats(summary)
+		docs = append(docs, search.Document{
+			ID:    post.ID,
+			Title: post.Title,
+			Tags:  post.Tags,
+			Year:  postYear(post),
+			Body:  summary.Text,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("buildIndex: %v", err)
+	}
+	return search.NewIndex(docs), stats, nil
+}
+
+// scanWith returns a rowScanner scanning extra trailing columns into dest.
+func scanWith(row rowScanner, dest ...interface{}) rowScanner {
+	return scannerFunc(func(columns ...interface{}) error {
+		return row.Scan(append(columns, dest...)...)
+	})
+}
+
+// scannerFunc adapts a function to the rowScanner interface.
+type scannerFunc func(dest ...interface{}) error
+
+// Scan implements rowScanner.
+func (f scannerFunc) Scan(dest ...interface{}) error {
+	return f(dest...)
+}
+
+// fromUnix converts a stored date back to a time, the zero time stays zero.
+func fromUnix(seconds int64, loc *time.Location) time.Time {
+	if seconds == (time.Time{}).Unix() {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).In(loc)
+}
+
+// ImportSQLite loads the posts the way NewService does and writes them, with their markdown files,
+// into the database. Posts already in the database are replaced, and with Replace the others are deleted.
+// File names are stored relative to MdDir.
+// It returns the number of imported posts and the conflicts between posts.json and the front matter.
+func ImportSQLite(db *sql.DB, opts TransferOptions) (int, []Conflict, error) {
+	if opts.Storage == nil {
+		opts.Storage = NewLocalStorage()
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	store, conflicts, err := loadPosts(opts.Storage, opts.FileName, opts.MdDir, opts.Location, opts.Authors)
+	if err != nil {
+		return 0, nil, fmt.Errorf("ImportSQLite: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("ImportSQLite: cannot begin transaction : %v", err)
+	}
+	defer tx.Rollback()
+	now := time.Now().UnixNano()
+	for _, p := range store {
+		var markdown []byte
+		if p.FileName != "" {
+			if markdown, err = fs.ReadFile(opts.Storage, p.FileName); err != nil {
+				return 0, nil, fmt.Errorf("ImportSQLite: cannot read markdown of post %s : %v", p.ID, err)
+			}
+		}
+		if err := insertPost(tx, p, relativeFileName(p.FileName, opts.MdDir), markdown, now); err != nil {
+			return 0, nil, fmt.Errorf("ImportSQLite: %v", err)
+		}
+	}
+	if opts.Replace {
+		if err := deletePostsExcept(tx, store); err != nil {
+			return 0, nil, fmt.Errorf("ImportSQLite: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("ImportSQLite: cannot commit : %v", err)
+	}
+	return len(store), conflicts, nil
+}
+
+// deletePostsExcept deletes the posts missing from kept, their tags and authors going with them.
+func deletePostsExcept(tx *sql.Tx, kept map[string]Post) error {
+	rows, err := tx.Query(`SELECT id FROM posts`)
+	if err != nil {
+		return fmt.Errorf("cannot query posts : %v", err)
+	}
+	var missing []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("cannot scan post : %v", err)
+		}
+		if _, ok := kept[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot query posts : %v", err)
+	}
+	for _, id := range missing {
+		if _, err := tx.Exec(`DELETE FROM posts WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("cannot delete post %s : %v", id, err)
+		}
+	}
+	return nil
+}
+
+// ExportSQLite writes every post of the database to the json file FileName on the local disk,
+// and their markdown files under MdDir. It returns the number of exported posts.
+func ExportSQLite(db *sql.DB, opts TransferOptions) (int, error) {
+	s := &sqlService{db: db, location: opts.Location, showDrafts: true}
+	if s.location == nil {
+		s.location = time.UTC
+	}
+	rows, err := db.Query(`SELECT ` + postColumns + `, p.markdown FROM posts p` + postOrder)
+	if err != nil {
+		return 0, fmt.Errorf("ExportSQLite: cannot query posts : %v", err)
+	}
+	defer rows.Close()
+	posts := make([]jsonPost, 0)
+	for rows.Next() {
+		var markdown []byte
+		p, err := s.scanPost(scanWith(rows, &markdown))
+		if err != nil {
+			return 0, fmt.Errorf("ExportSQLite: %v", err)
+		}
+		if markdown != nil && p.FileName != "" {
+			fileName := filepath.Join(opts.MdDir, filepath.FromSlash(p.FileName))
+			if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+				return 0, fmt.Errorf("ExportSQLite: cannot create directory : %v", err)
+			}
+			if err := os.WriteFile(fileName, markdown, 0644); err != nil {
+				return 0, fmt.Errorf("ExportSQLite: cannot write markdown of post %s : %v", p.ID, err)
+			}
+		}
+		posts = append(posts, newJsonPost(p, s.location))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ExportSQLite: %v", err)
+	}
+
+	data, err := json.MarshalIndent(posts, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("ExportSQLite: cannot marshal posts : %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.FileName), 0755); err != nil {
+		return 0, fmt.Errorf("ExportSQLite: cannot create directory : %v", err)
+	}
+	if err := os.WriteFile(opts.FileName, append(data, '\n'), 0644); err != nil {
+		return 0, fmt.Errorf("ExportSQLite: cannot write json file : %v", err)
+	}
+	return len(posts), nil
+}
+
+// insertPost writes a post with its tags and authors, replacing the post of the same ID.
+func insertPost(tx *sql.Tx, p Post, fileName string, markdown []byte, updatedAt int64) error {
+	var publishAt, seriesPart sql.NullInt64
+	var seriesName, extra sql.NullString
+	if p.PublishAt != nil {
+		publishAt = sql.NullInt64{Int64: p.PublishAt.Unix(), Valid: true}
+	}
+	if p.Series != nil {
+		seriesName = sql.NullString{String: p.Series.Name, Valid: true}
+		seriesPart = sql.NullInt64{Int64: int64(p.Series.Part), Valid: true}
+	}
+	if len(p.Extra) != 0 {
+		data, err := json.Marshal(p.Extra)
+		if err != nil {
+			return fmt.Errorf("cannot encode extra fields of post %s : %v", p.ID, err)
+		}
+		extra = sql.NullString{String: string(data), Valid: true}
+	}
+	if _, err := tx.Exec(`DELETE FROM posts WHERE id = ?`, p.ID); err != nil {
+		return fmt.Errorf("cannot replace post %s : %v", p.ID, err)
+	}
+	_, err := tx.Exec(`INSERT INTO posts (id, title, date, content, filename, draft, publish_at, series_name, series_part, extra, markdown, updated_at, lang, translation_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.Title, p.Date.Unix(), p.Content, fileName, p.Draft, publishAt, seriesName, seriesPart, extra, markdown, updatedAt, p.Lang, p.TranslationKey)
+	if err != nil {
+		return fmt.Errorf("cannot insert post %s : %v", p.ID, err)
+	}
+	for i, tag := range p.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO post_tags (post_id, tag, position) VALUES (?, ?, ?)`, p.ID, tag, i); err != nil {
+			return fmt.Errorf("cannot insert tags of post %s : %v", p.ID, err)
+		}
+	}
+	for i, id := range p.Authors {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO post_authors (post_id, author, position) VALUES (?, ?, ?)`, p.ID, id, i); err != nil {
+			return fmt.Errorf("cannot insert authors of post %s : %v", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// relativeFileName returns the path of a markdown file relative to mdDir, as written in posts.json.
+func relativeFileName(fileName string, mdDir string) string {
+	if mdDir == "" {
+		return fileName
+	}
+	return strings.TrimPrefix(fileName, cleanMdDir(mdDir)+"/")
+}
+
+// Open implements fs.FS.
+func (s sqliteStorage) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	var markdown []byte
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT markdown, updated_at FROM posts WHERE filename = ? AND markdown IS NOT NULL`, name).Scan(&markdown, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &sqliteFile{
+		Reader: bytes.NewReader(markdown),
+		info:   sqliteFileInfo{name: path.Base(name), size: int64(len(markdown)), modTime: time.Unix(0, updatedAt)},
+	}, nil
+}
+
+// Local implements Storage.
+func (sqliteStorage) Local() bool {
+	return false
+}
+
+// Stat implements fs.File.
+func (f *sqliteFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Close implements fs.File.
+func (f *sqliteFile) Close() error {
+	return nil
+}
+
+func (i sqliteFileInfo) Name() string       { return i.name }
+func (i sqliteFileInfo) Size() int64        { return i.size }
+func (i sqliteFileInfo) Mode() fs.FileMode  { return 0444 }
+func (i sqliteFileInfo) ModTime() time.Time { return i.modTime }
+func (i sqliteFileInfo) IsDir() bool        { return false }
+func (i sqliteFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,377 @@
+package post
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type SQLiteServiceTestSuite struct {
+	suite.Suite
+	Logger *logger.Logger
+	LogFn  logger.LoggingFn
+	DB     *sql.DB
+}
+
+func (s *SQLiteServiceTestSuite) SetupSuite() {
+	s.Logger = logger.NewLogger(logger.LoggerOptions{
+		Debug: true,
+	})
+	s.LogFn = s.Logger.ContextLoggingFn(&gin.Context{})
+}
+
+func (s *SQLiteServiceTestSuite) SetupTest() {
+	db, err := OpenSQLite(filepath.Join(s.T().TempDir(), "posts.db"))
+	s.Require().NoError(err)
+	s.DB = db
+}
+
+func (s *SQLiteServiceTestSuite) TearDownTest() {
+	s.DB.Close()
+}
+
+// importDir imports a markdown directory and returns a service over the database
+func (s *SQLiteServiceTestSuite) importDir(fileName string, mdDir string) Service {
+	_, _, err := ImportSQLite(s.DB, TransferOptions{FileName: fileName, MdDir: mdDir})
+	s.Require().NoError(err)
+	service, err := NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB})
+	s.Require().NoError(err)
+	return service
+}
+
+// TestImport tests that the imported posts match the posts loaded from the files
+func (s *SQLiteServiceTestSuite) TestImport() {
+	count, conflicts, err := ImportSQLite(s.DB, TransferOptions{FileName: "testdata/md_posts.json", MdDir: "testdata/md"})
+	s.Require().NoError(err)
+	s.Require().Equal(3, count)
+	s.Require().Len(conflicts, 2)
+
+	service, err := NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB})
+	s.Require().NoError(err)
+	fileService, err := NewService(ServiceOptions{Logger: s.Logger, FileName: "testdata/md_posts.json", MdDir: "testdata/md"})
+	s.Require().NoError(err)
+
+	posts, err := service.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	filePosts, err := fileService.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, len(filePosts))
+	for i := range posts {
+		s.Require().Equal(filePosts[i].ID, posts[i].ID)
+		s.Require().Equal(filePosts[i].Title, posts[i].Title)
+		s.Require().Equal(filePosts[i].Tags, posts[i].Tags)
+		s.Require().True(filePosts[i].Date.Equal(posts[i].Date))
+		s.Require().Equal(filePosts[i].Stats, posts[i].Stats, "the stats should be worked out from the stored markdown")
+	}
+
+	post, err := service.GetPost(s.LogFn, "yaml-post")
+	s.Require().NoError(err)
+	s.Require().Equal("yaml-post.md", post.FileName, "file names should be relative to the markdown directory")
+	s.Require().Equal("formats", post.Extra["subtitle"])
+	src, err := fs.ReadFile(NewSQLiteStorage(s.DB), post.FileName)
+	s.Require().NoError(err, "the markdown should be read from the database")
+	s.Require().Contains(string(src), "title: Front matter in YAML")
+
+	_, err = service.GetPost(s.LogFn, "missing")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+
+	results, err := service.Search(s.LogFn, "legacy", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1)
+}
+
+// TestImportReplace tests that an import with Replace deletes the posts missing from the files
+func (s *SQLiteServiceTestSuite) TestImportReplace() {
+	_, _, err := ImportSQLite(s.DB, TransferOptions{FileName: "testdata/test_posts.json"})
+	s.Require().NoError(err)
+	_, _, err = ImportSQLite(s.DB, TransferOptions{FileName: "testdata/md_posts.json", MdDir: "testdata/md"})
+	s.Require().NoError(err)
+	service, err := NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB})
+	s.Require().NoError(err)
+	posts, err := service.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 5, "an import should keep the posts missing from the files")
+
+	count, _, err := ImportSQLite(s.DB, TransferOptions{FileName: "testdata/md_posts.json", MdDir: "testdata/md", Replace: true})
+	s.Require().NoError(err)
+	s.Require().Equal(3, count)
+	service, err = NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB})
+	s.Require().NoError(err)
+	posts, err = service.GetPosts(s.LogFn)
+	s.Require().NoError(err)
+	s.Require().Len(posts, 3, "an import with Replace should delete the posts missing from the files")
+	tags, err := service.GetTags(s.LogFn, TagOrderCount)
+	s.Require().NoError(err)
+	for _, tag := range tags {
+		s.Require().NotEqual("post", tag.Tag, "the tags of the deleted posts should be gone")
+	}
+	var authors int
+	s.Require().NoError(s.DB.QueryRow(`SELECT COUNT(*) FROM post_authors WHERE post_id NOT IN (SELECT id FROM posts)`).Scan(&authors))
+	s.Require().Zero(authors, "the authors of the deleted posts should be gone")
+}
+
+// TestPaging tests the paging queries by page number and cursor
+func (s *SQLiteServiceTestSuite) TestPaging() {
+	service := s.importDir("testdata/test_posts.json", "")
+
+	page, err := service.GetPostsPage(s.LogFn, PageRequest{Size: 1, Page: 1})
+	s.Require().NoError(err)
+	s.Require().Equal(2, page.Total)
+	s.Require().Equal("2", page.Posts[0].ID)
+	s.Require().Equal(2, page.NextPage)
+
+	page, err = service.GetPostsPage(s.LogFn, PageRequest{Size: 1, Cursor: page.NextCursor})
+	s.Require().NoError(err)
+	s.Require().Len(page.Posts, 1)
+	s.Require().Equal("1", page.Posts[0].ID)
+	s.Require().Empty(page.NextCursor)
+
+	_, err = service.GetPostsPage(s.LogFn, PageRequest{Page: 0})
+	s.Require().ErrorIs(err, ErrInvalidPage)
+
+	page, err = service.GetPostsByTagPage(s.LogFn, "post", PageRequest{Page: 1})
+	s.Require().NoError(err)
+	s.Require().Equal(1, page.Total)
+	_, err = service.GetPostsByTagPage(s.LogFn, "unknown", PageRequest{Page: 1})
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+
+	tags, err := service.GetTags(s.LogFn, TagOrderCount)
+	s.Require().NoError(err)
+	s.Require().Equal([]TagCount{{Tag: "example", Count: 2}, {Tag: "post", Count: 1}}, tags)
+}
+
+// TestGetTags tests that the database orders the tags like the post service
+func (s *SQLiteServiceTestSuite) TestGetTags() {
+	service := s.importDir("testdata/tag_posts.json", "")
+
+	tags, err := service.GetTags(s.LogFn, TagOrderCount)
+	s.Require().NoError(err)
+	s.Require().Equal([]TagCount{
+		{Tag: "zebra", Count: 3},
+		{Tag: "banana", Count: 2},
+		{Tag: "mango", Count: 2},
+		{Tag: "apple", Count: 1},
+	}, tags, "tags with the same count should be ordered by name")
+
+	tags, err = service.GetTags(s.LogFn, TagOrderName)
+	s.Require().NoError(err)
+	s.Require().Equal([]TagCount{
+		{Tag: "apple", Count: 1},
+		{Tag: "banana", Count: 2},
+		{Tag: "mango", Count: 2},
+		{Tag: "zebra", Count: 3},
+	}, tags)
+}
+
+// TestDraftsAndSeries tests the visibility of unpublished posts, series order and neighbours
+func (s *SQLiteServiceTestSuite) TestDraftsAndSeries() {
+	_, _, err := ImportSQLite(s.DB, TransferOptions{MdDir: "testdata/drafts"})
+	s.Require().NoError(err)
+	service := s.importDir("", "testdata/series")
+	service.(*sqlService).now = func() time.Time {
+		return time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	_, err = service.GetPost(s.LogFn, "draft")
+	s.Require().Error(err, "drafts should be hidden")
+	_, err = service.GetPost(s.LogFn, "scheduled")
+	s.Require().Error(err, "scheduled posts should be hidden before their time")
+	_, err = service.GetUnpublishedPost(s.LogFn, "scheduled")
+	s.Require().NoError(err)
+	_, err = service.GetPostsByTag(s.LogFn, "secret")
+	s.Require().Error(err, "tags of drafts should be hidden")
+
+	series, err := service.GetSeries(s.LogFn, "tour")
+	s.Require().NoError(err)
+	s.Require().Len(series, 3)
+	for i, id := range []string{"part-one", "part-two", "part-three"} {
+		s.Require().Equal(id, series[i].ID)
+	}
+
+	n, err := service.GetNeighbours(s.LogFn, "published")
+	s.Require().NoError(err)
+	s.Require().Equal("part-three", n.Next.ID, "posts of the same date should be ordered by ID")
+	s.Require().Nil(n.Previous)
+
+	service.(*sqlService).now = func() time.Time {
+		return time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	}
+	_, err = service.GetPost(s.LogFn, "scheduled")
+	s.Require().NoError(err, "scheduled post should be published without reload")
+}
+
+// TestExport tests that an export can be loaded back
+func (s *SQLiteServiceTestSuite) TestExport() {
+	s.importDir("testdata/md_posts.json", "testdata/md")
+
+	dir := s.T().TempDir()
+	fileName := filepath.Join(dir, "posts.json")
+	mdDir := filepath.Join(dir, "md")
+	count, err := ExportSQLite(s.DB, TransferOptions{FileName: fileName, MdDir: mdDir})
+	s.Require().NoError(err)
+	s.Require().Equal(3, count)
+
+	data, err := os.ReadFile(fileName)
+	s.Require().NoError(err)
+	var exported []map[string]interface{}
+	s.Require().NoError(json.Unmarshal(data, &exported))
+	s.Require().Len(exported, 3)
+
+	store, conflicts, err := loadPosts(NewLocalStorage(), fileName, mdDir, time.UTC, nil)
+	s.Require().NoError(err)
+	s.Require().Len(conflicts, 2, "the markdown files should be exported unchanged")
+	s.Require().Len(store, 3)
+	s.Require().Equal("Overridden title", store["yaml-post"].Title)
+	s.Require().FileExists(store["legacy"].FileName)
+}
+
+// TestWritePosts tests creating, updating and deleting posts in the database
+func (s *SQLiteServiceTestSuite) TestWritePosts() {
+	service := s.importDir("testdata/md_posts.json", "testdata/md")
+
+	created, err := service.CreatePost(s.LogFn, PostInput{ID: "new-post", Title: "New post", Tags: []string{"fresh"}, Date: "2024-05-01", Markdown: "Written by the API."})
+	s.Require().NoError(err)
+	s.Require().Equal("new-post.md", created.FileName)
+	src, err := fs.ReadFile(NewSQLiteStorage(s.DB), created.FileName)
+	s.Require().NoError(err)
+	s.Require().Equal("Written by the API.", string(src))
+	results, err := service.Search(s.LogFn, "written", 10)
+	s.Require().NoError(err)
+	s.Require().Len(results, 1, "the search index should be rebuilt after a write")
+
+	_, err = service.CreatePost(s.LogFn, PostInput{ID: "new-post", Title: "Again", Date: "2024-05-01"})
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+	s.Require().ErrorIs(keyError.Err, ErrKeyExists)
+	_, err = service.CreatePost(s.LogFn, PostInput{ID: "untitled", Date: "2024-05-01"})
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+
+	updated, err := service.UpdatePost(s.LogFn, "yaml-post", PostInput{Title: "Updated", Tags: []string{"fresh"}, Date: "2021-03-04"})
+	s.Require().NoError(err)
+	s.Require().Equal("yaml-post.md", updated.FileName, "the file name should be kept")
+	posts, err := service.GetPostsByTag(s.LogFn, "fresh")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2)
+
+	// saves within the same second should still change the modification time of the markdown
+	service.(*sqlService).now = func() time.Time { return time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC) }
+	var modTimes []time.Time
+	for _, markdown := range []string{"First.", "Second.", "Third."} {
+		_, err = service.UpdatePost(s.LogFn, "new-post", PostInput{Title: "New post", Date: "2024-05-01", Markdown: markdown})
+		s.Require().NoError(err)
+		info, err := fs.Stat(NewSQLiteStorage(s.DB), "new-post.md")
+		s.Require().NoError(err)
+		modTimes = append(modTimes, info.ModTime())
+	}
+	s.Require().True(modTimes[0].Before(modTimes[1]), "%v should be before %v", modTimes[0], modTimes[1])
+	s.Require().True(modTimes[1].Before(modTimes[2]), "%v should be before %v", modTimes[1], modTimes[2])
+
+	s.Require().NoError(service.DeletePost(s.LogFn, "new-post"))
+	s.Require().ErrorAs(service.DeletePost(s.LogFn, "new-post"), &keyError)
+	posts, err = service.GetPostsByTag(s.LogFn, "fresh")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 1, "the tags of a deleted post should be removed")
+}
+
+// TestAuthors tests storing the authors of the posts and listing posts by author
+func (s *SQLiteServiceTestSuite) TestAuthors() {
+	authors, err := author.NewDirectory([]author.Author{{ID: "ann", Name: "Ann"}, {ID: "bob", Name: "Bob"}})
+	s.Require().NoError(err)
+	service, err := NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB, Authors: authors})
+	s.Require().NoError(err)
+
+	_, err = service.CreatePost(s.LogFn, PostInput{ID: "first", Title: "First", Date: "2024-05-01", Authors: []string{"bob", "ann"}})
+	s.Require().NoError(err)
+	_, err = service.CreatePost(s.LogFn, PostInput{ID: "second", Title: "Second", Date: "2024-05-02", Authors: []string{"ann"}})
+	s.Require().NoError(err)
+	_, err = service.CreatePost(s.LogFn, PostInput{ID: "third", Title: "Third", Date: "2024-05-03", Authors: []string{"eve"}})
+	var validationError *ValidationError
+	s.Require().ErrorAs(err, &validationError)
+
+	post, err := service.GetPost(s.LogFn, "first")
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"bob", "ann"}, post.Authors)
+	posts, err := service.GetPostsByAuthor(s.LogFn, "ann")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2)
+	s.Require().Equal("second", posts[0].ID)
+	posts, err = service.GetPostsByAuthor(s.LogFn, "bob")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 1)
+
+	_, err = NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB})
+	s.Require().ErrorContains(err, `post "second": unknown author "ann"`, "the stored posts should be checked against the authors")
+}
+
+// TestTranslations tests storing the language of the posts and finding their translations
+func (s *SQLiteServiceTestSuite) TestTranslations() {
+	service, err := NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: s.DB})
+	s.Require().NoError(err)
+	for _, in := range []PostInput{
+		{ID: "boxes", Title: "Beyond boxes", Date: "2024-03-01", Lang: "en", TranslationKey: "boxes"},
+		{ID: "boxes-hu", Title: "A dobozokon túl", Date: "2024-03-02", Lang: "hu", TranslationKey: "boxes"},
+		{ID: "alone", Title: "Alone", Date: "2024-03-03"},
+	} {
+		_, err := service.CreatePost(s.LogFn, in)
+		s.Require().NoError(err)
+	}
+	posts, err := service.GetTranslations(s.LogFn, "boxes")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 2)
+	s.Require().Equal("hu", posts[1].Lang)
+	s.Require().Equal("boxes", posts[1].TranslationKey)
+	posts, err = service.GetTranslations(s.LogFn, "alone")
+	s.Require().NoError(err)
+	s.Require().Len(posts, 1)
+	_, err = service.GetTranslations(s.LogFn, "missing")
+	var keyError *KeyError
+	s.Require().ErrorAs(err, &keyError)
+}
+
+// TestMigration tests that a database created before the language columns and the nanosecond updated_at is upgraded
+func (s *SQLiteServiceTestSuite) TestMigration() {
+	fileName := filepath.Join(s.T().TempDir(), "old.db")
+	db, err := sql.Open("sqlite", fileName)
+	s.Require().NoError(err)
+	_, err = db.Exec(`CREATE TABLE posts (id TEXT PRIMARY KEY, title TEXT NOT NULL, date INTEGER NOT NULL, content TEXT NOT NULL DEFAULT '',
+		filename TEXT NOT NULL DEFAULT '', draft INTEGER NOT NULL DEFAULT 0, publish_at INTEGER, series_name TEXT, series_part INTEGER,
+		extra TEXT, markdown BLOB, updated_at INTEGER NOT NULL);
+		INSERT INTO posts (id, title, date, updated_at) VALUES ('old', 'Old post', 0, 0);
+		INSERT INTO posts (id, title, date, filename, markdown, updated_at) VALUES ('saved', 'Saved post', 0, 'saved.md', 'Saved.', 1700000000);`)
+	s.Require().NoError(err)
+	s.Require().NoError(db.Close())
+
+	db, err = OpenSQLite(fileName)
+	s.Require().NoError(err)
+	defer db.Close()
+	info, err := fs.Stat(NewSQLiteStorage(db), "saved.md")
+	s.Require().NoError(err)
+	s.Require().Equal(time.Unix(1700000000, 0).UTC(), info.ModTime().UTC(), "the seconds should be converted to nanoseconds")
+	s.Require().NoError(migrateSQLite(db))
+	info, err = fs.Stat(NewSQLiteStorage(db), "saved.md")
+	s.Require().NoError(err)
+	s.Require().Equal(time.Unix(1700000000, 0).UTC(), info.ModTime().UTC(), "the migration should only convert once")
+	service, err := NewSQLiteService(SQLiteServiceOptions{Logger: s.Logger, DB: db})
+	s.Require().NoError(err)
+	post, err := service.GetPost(s.LogFn, "old")
+	s.Require().NoError(err)
+	s.Require().Empty(post.Lang)
+	_, err = service.UpdatePost(s.LogFn, "old", PostInput{Title: "Old post", Date: "2024-01-01", Lang: "hu"})
+	s.Require().NoError(err)
+}
+
+func TestSQLiteServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(SQLiteServiceTestSuite))
+}
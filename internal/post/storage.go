@@ -0,0 +1,112 @@
+package post
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Storage kinds selected by StorageOptions.Kind.
+const (
+	// StorageFileSystem reads the content from the local disk, paths are relative to the working directory.
+	StorageFileSystem = "filesystem"
+	// StorageEmbed reads the content compiled into the binary.
+	StorageEmbed = "embed"
+	// StorageArchive reads the content from a zip archive.
+	StorageArchive = "archive"
+)
+
+type (
+	// Storage is the read-only source of the posts.json file and the markdown directory.
+	// Paths are slash-separated, as in fs.FS.
+	Storage interface {
+		fs.FS
+		// Local reports whether the content is on the local disk, only local content can be watched for changes.
+		Local() bool
+	}
+
+	// StorageOptions is a struct that contains the options for constructing a Storage.
+	// Embedded is the content compiled into the binary, Archive is the path of the zip archive.
+	StorageOptions struct {
+		Kind     string
+		Embedded fs.FS
+		Archive  string
+	}
+
+	// localStorage is the Storage of the local disk.
+	localStorage struct{}
+
+	// fsStorage is the Storage of a fs.FS, like an embed.FS or an archive.
+	fsStorage struct {
+		fs.FS
+	}
+)
+
+// NewStorage returns the Storage of the given kind, the local disk if the kind is not set.
+func NewStorage(opts StorageOptions) (Storage, error) {
+	switch opts.Kind {
+	case "", StorageFileSystem:
+		return NewLocalStorage(), nil
+	case StorageEmbed:
+		if opts.Embedded == nil {
+			return nil, fmt.Errorf("NewStorage: no embedded content")
+		}
+		return NewFSStorage(opts.Embedded), nil
+	case StorageArchive:
+		return NewArchiveStorage(opts.Archive)
+	default:
+		return nil, fmt.Errorf("NewStorage: unknown storage %q", opts.Kind)
+	}
+}
+
+// NewLocalStorage returns the Storage of the local disk.
+// Unlike os.DirFS it accepts absolute and parent relative paths, as the config did before storages.
+func NewLocalStorage() Storage {
+	return localStorage{}
+}
+
+// NewFSStorage returns a Storage reading from fsys, for example an embed.FS.
+func NewFSStorage(fsys fs.FS) Storage {
+	return fsStorage{FS: fsys}
+}
+
+// NewArchiveStorage returns a Storage reading from a zip archive.
+// The archive is read into memory once, later changes of the file are not seen.
+func NewArchiveStorage(fileName string) (Storage, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("NewArchiveStorage: cannot read archive : %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("NewArchiveStorage: cannot open archive : %v", err)
+	}
+	return fsStorage{FS: r}, nil
+}
+
+// Open implements fs.FS.
+func (localStorage) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+// Stat implements fs.StatFS.
+func (localStorage) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (localStorage) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// Local implements Storage.
+func (localStorage) Local() bool {
+	return true
+}
+
+// Local implements Storage.
+func (fsStorage) Local() bool {
+	return false
+}
@@ -0,0 +1,151 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is the quiet period after the last file system event before the store is rebuilt.
+// Editors tend to write a file in several steps, this collapses them into one reload.
+var reloadDelay = 200 * time.Millisecond
+
+// watch starts watching the json file and the markdown directory and rebuilds the store when they change.
+func (s *pService) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch: cannot create watcher: %v", err)
+	}
+	if s.fileName != "" {
+		// the directory is watched because editors often replace the file instead of writing it
+		if err := w.Add(filepath.Dir(s.fileName)); err != nil {
+			w.Close()
+			return fmt.Errorf("watch: cannot watch %s: %v", s.fileName, err)
+		}
+	}
+	if s.mdDir != "" {
+		if err := s.watchMdDir(w); err != nil {
+			w.Close()
+			return fmt.Errorf("watch: cannot watch %s: %v", s.mdDir, err)
+		}
+	}
+	s.watcher = w
+	s.watchDone = make(chan struct{})
+	go s.watchLoop(w)
+	return nil
+}
+
+// watchLoop consumes the watcher events until the watcher is closed.
+func (s *pService) watchLoop(w *fsnotify.Watcher) {
+	defer close(s.watchDone)
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			if !s.isContentEvent(event) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addDirs(w, event.Name); err != nil {
+						s.logger.Error().Err(err).Str("dir", event.Name).Msg("watch: cannot watch new directory")
+					}
+				}
+			}
+			s.logger.Debug().Str("file", event.Name).Str("op", event.Op.String()).Msg("watch: content changed")
+			timer.Reset(reloadDelay)
+		case err, ok := <-w.Errors:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			s.logger.Error().Err(err).Msg("watch: watcher error")
+		case <-timer.C:
+			s.reload()
+		}
+	}
+}
+
+// reload rebuilds the store. On failure the previous store keeps being served.
+func (s *pService) reload() {
+	if err := s.initPosts(s.fileName, s.mdDir); err != nil {
+		s.logger.Error().Err(err).Msg("reload: keeping previous posts")
+		return
+	}
+	s.logger.Info().Msg("reload: posts reloaded")
+	if s.onReload != nil {
+		s.onReload()
+	}
+}
+
+// isContentEvent reports whether the event concerns the json file or the markdown directory.
+func (s *pService) isContentEvent(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	if s.fileName != "" && name == filepath.Clean(s.fileName) {
+		return true
+	}
+	if s.mdDir != "" {
+		rel, err := filepath.Rel(filepath.Clean(s.mdDir), name)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	return false
+}
+
+// Close implements Service.
+func (s *pService) Close() error {
+	if s.watcher == nil {
+		return nil
+	}
+	err := s.watcher.Close()
+	<-s.watchDone
+	s.watcher = nil
+	return err
+}
+
+// watchMdDir adds the markdown directory to the watcher. A directory that does not exist yet
+// is waited for in its parent, watchLoop adds it once it is created.
+func (s *pService) watchMdDir(w *fsnotify.Watcher) error {
+	if _, err := os.Stat(s.mdDir); !errors.Is(err, fs.ErrNotExist) {
+		return addDirs(w, s.mdDir)
+	}
+	parent := filepath.Dir(filepath.Clean(s.mdDir))
+	if err := w.Add(parent); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		s.logger.Warn().Str("dir", s.mdDir).Msg("watch: markdown directory and its parent do not exist, new posts will not be picked up")
+		return nil
+	}
+	s.logger.Warn().Str("dir", s.mdDir).Msg("watch: markdown directory does not exist, waiting for it to be created")
+	return nil
+}
+
+// addDirs adds dir and all its subdirectories to the watcher.
+func addDirs(w *fsnotify.Watcher, dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.Add(path)
+		}
+		return nil
+	})
+}
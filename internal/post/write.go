@@ -0,0 +1,355 @@
+package post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/fileutil"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+)
+
+var ErrKeyExists = errors.New("key already exists")
+var ErrReadOnly = errors.New("posts are read-only")
+
+// validID are the IDs a post can be created with, they are used in URLs and file names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+type (
+	// PostInput is the editable content of a post, as sent to CreatePost and UpdatePost.
+	// Dates are text in any of the accepted layouts, taken in the site timezone if they have no zone.
+	PostInput struct {
+		ID      string   `json:"id"`
+		Title   string   `json:"title"`
+		Tags    []string `json:"tags"`
+		Authors []string `json:"authors"`
+		Lang    string   `json:"lang"`
+		// TranslationKey names the article the post is a translation of.
+		TranslationKey string                 `json:"translationKey"`
+		Date           string                 `json:"date"`
+		Content        string                 `json:"content"`
+		Draft          bool                   `json:"draft"`
+		PublishAt      string                 `json:"publishAt"`
+		Series         *Series                `json:"series"`
+		Extra          map[string]interface{} `json:"extra"`
+		// Markdown is the body of the post.
+		Markdown string `json:"markdown"`
+	}
+
+	// ValidationError is returned by CreatePost and UpdatePost for invalid input.
+	// Fields maps the json name of every invalid field to the problem with it.
+	ValidationError struct {
+		Fields map[string]string
+	}
+)
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for i, field := range fields {
+		fields[i] = field + ": " + e.Fields[field]
+	}
+	return "invalid post: " + strings.Join(fields, ", ")
+}
+
+// NewPostInput returns the editable content of a post with its markdown body, the dates in their textual form in loc.
+func NewPostInput(p Post, markdown string, loc *time.Location) PostInput {
+	jp := newJsonPost(p, loc)
+	return PostInput{
+		ID:             p.ID,
+		Title:          p.Title,
+		Tags:           p.Tags,
+		Authors:        p.Authors,
+		Lang:           p.Lang,
+		TranslationKey: p.TranslationKey,
+		Date:           jp.Date,
+		Content:        p.Content,
+		Draft:          p.Draft,
+		PublishAt:      jp.PublishAt,
+		Series:         p.Series,
+		Extra:          p.Extra,
+		Markdown:       markdown,
+	}
+}
+
+// post validates the input and converts it to a post, parsing its dates in loc.
+// The authors of the post must be in authors.
+func (in PostInput) post(loc *time.Location, authors *author.Directory) (Post, error) {
+	fields := make(map[string]string)
+	p := Post{
+		ID:             strings.TrimSpace(in.ID),
+		Title:          strings.TrimSpace(in.Title),
+		Content:        in.Content,
+		Draft:          in.Draft,
+		Extra:          in.Extra,
+		TranslationKey: strings.TrimSpace(in.TranslationKey),
+	}
+	switch {
+	case p.ID == "":
+		fields["id"] = "is required"
+	case !validID.MatchString(p.ID):
+		fields["id"] = "may only contain letters, digits, - and _"
+	}
+	if p.Title == "" {
+		fields["title"] = "is required"
+	}
+	for _, tag := range in.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			fields["tags"] = "must not be empty"
+			continue
+		}
+		p.Tags = append(p.Tags, tag)
+	}
+	for _, id := range in.Authors {
+		id = strings.TrimSpace(id)
+		switch {
+		case id == "":
+			fields["authors"] = "must not be empty"
+			continue
+		case slices.Contains(p.Authors, id):
+			fields["authors"] = "duplicate author " + id
+			continue
+		}
+		if _, ok := authors.Get(id); !ok {
+			fields["authors"] = "unknown author " + id
+		}
+		p.Authors = append(p.Authors, id)
+	}
+	if lang := strings.TrimSpace(in.Lang); lang != "" {
+		if canonical, err := parseLang(lang); err != nil {
+			fields["lang"] = err.Error()
+		} else {
+			p.Lang = canonical
+		}
+	}
+	if strings.TrimSpace(in.Date) == "" {
+		fields["date"] = "is required"
+	} else if date, err := parseDate(strings.TrimSpace(in.Date), loc); err != nil {
+		fields["date"] = err.Error()
+	} else {
+		p.Date = date
+	}
+	if strings.TrimSpace(in.PublishAt) != "" {
+		if publishAt, err := parseDate(strings.TrimSpace(in.PublishAt), loc); err != nil {
+			fields["publishAt"] = err.Error()
+		} else {
+			p.PublishAt = &publishAt
+		}
+	}
+	if in.Series != nil {
+		switch {
+		case strings.TrimSpace(in.Series.Name) == "":
+			fields["series"] = "name is required"
+		case in.Series.Part < 0:
+			fields["series"] = "part must not be negative"
+		default:
+			p.Series = &Series{Name: strings.TrimSpace(in.Series.Name), Part: in.Series.Part}
+		}
+	}
+	if len(fields) > 0 {
+		return Post{}, &ValidationError{Fields: fields}
+	}
+	return p, nil
+}
+
+// CreatePost implements Service.
+func (s *pService) CreatePost(l logger.LoggingFn, in PostInput) (Post, error) {
+	l(logger.DebugLevel).Str("id", in.ID).Msg("PostService::CreatePost")
+	if err := s.writable(); err != nil {
+		return Post{}, err
+	}
+	p, err := in.post(s.location, s.authors)
+	if err != nil {
+		return Post{}, err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, ok := s.storedPost(p.ID); ok {
+		return Post{}, &KeyError{Key: p.ID, Err: ErrKeyExists}
+	}
+	entries, err := s.readEntries()
+	if err != nil {
+		return Post{}, fmt.Errorf("CreatePost: %v", err)
+	}
+	name := p.ID + ".md"
+	mdFile := filepath.Join(s.mdDir, name)
+	if _, err := os.Stat(mdFile); err == nil {
+		return Post{}, &KeyError{Key: p.ID, Err: ErrKeyExists}
+	}
+	if err := fileutil.WriteAtomic(mdFile, []byte(in.Markdown)); err != nil {
+		return Post{}, fmt.Errorf("CreatePost: cannot write markdown file : %v", err)
+	}
+	entry := newJsonPost(p, s.location)
+	entry.FileName = name
+	entry.Draft = &p.Draft
+	if err := s.writeEntries(append(entries, entry)); err != nil {
+		os.Remove(mdFile)
+		return Post{}, fmt.Errorf("CreatePost: %v", err)
+	}
+	return s.reloadPost(p.ID)
+}
+
+// UpdatePost implements Service.
+func (s *pService) UpdatePost(l logger.LoggingFn, id string, in PostInput) (Post, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::UpdatePost")
+	if err := s.writable(); err != nil {
+		return Post{}, err
+	}
+	if in.ID == "" {
+		in.ID = id
+	}
+	p, err := in.post(s.location, s.authors)
+	if err != nil {
+		return Post{}, err
+	}
+	if p.ID != id {
+		return Post{}, &ValidationError{Fields: map[string]string{"id": "cannot be changed"}}
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	current, ok := s.storedPost(id)
+	if !ok {
+		return Post{}, &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+	entries, err := s.readEntries()
+	if err != nil {
+		return Post{}, fmt.Errorf("UpdatePost: %v", err)
+	}
+	// the markdown file keeps its name, posts described by front matter only get a json entry
+	name := relativeFileName(current.FileName, s.mdDir)
+	if name == "" {
+		name = id + ".md"
+	}
+	// the entry sets draft even to false, so that it wins over the front matter of the markdown
+	entry := newJsonPost(p, s.location)
+	entry.FileName = name
+	entry.Draft = &p.Draft
+	found := false
+	for i := range entries {
+		if entries[i].ID == id {
+			entries[i], found = entry, true
+		}
+	}
+	if !found {
+		entries = append(entries, entry)
+	}
+	// the previous markdown is written back if the json file cannot be updated
+	mdFile := filepath.Join(s.mdDir, name)
+	previous, err := os.ReadFile(mdFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Post{}, fmt.Errorf("UpdatePost: cannot read markdown file : %v", err)
+	}
+	existed := err == nil
+	if err := fileutil.WriteAtomic(mdFile, []byte(in.Markdown)); err != nil {
+		return Post{}, fmt.Errorf("UpdatePost: cannot write markdown file : %v", err)
+	}
+	if err := s.writeEntries(entries); err != nil {
+		if existed {
+			fileutil.WriteAtomic(mdFile, previous)
+		} else {
+			os.Remove(mdFile)
+		}
+		return Post{}, fmt.Errorf("UpdatePost: %v", err)
+	}
+	return s.reloadPost(id)
+}
+
+// DeletePost implements Service.
+func (s *pService) DeletePost(l logger.LoggingFn, id string) error {
+	l(logger.DebugLevel).Str("id", id).Msg("PostService::DeletePost")
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	current, ok := s.storedPost(id)
+	if !ok {
+		return &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+	entries, err := s.readEntries()
+	if err != nil {
+		return fmt.Errorf("DeletePost: %v", err)
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.ID != id {
+			kept = append(kept, entry)
+		}
+	}
+	if err := s.writeEntries(kept); err != nil {
+		return fmt.Errorf("DeletePost: %v", err)
+	}
+	if current.FileName != "" {
+		if err := os.Remove(current.FileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("DeletePost: cannot remove markdown file : %v", err)
+		}
+	}
+	if err := s.initPosts(s.fileName, s.mdDir); err != nil {
+		return fmt.Errorf("DeletePost: cannot reload posts : %v", err)
+	}
+	return nil
+}
+
+// writable returns ErrReadOnly unless the posts are read from a json file and a markdown directory on the local disk.
+func (s *pService) writable() error {
+	if !s.storage.Local() || s.fileName == "" || s.mdDir == "" {
+		return fmt.Errorf("%w: posts.file and posts.mddir on the local disk are required", ErrReadOnly)
+	}
+	return nil
+}
+
+// storedPost returns a post of the store whether it is published or not.
+func (s *pService) storedPost(id string) (Post, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	p, ok := s.store[id]
+	return p, ok
+}
+
+// reloadPost rebuilds the store after a write and returns the written post.
+func (s *pService) reloadPost(id string) (Post, error) {
+	if err := s.initPosts(s.fileName, s.mdDir); err != nil {
+		return Post{}, fmt.Errorf("cannot reload posts : %v", err)
+	}
+	p, ok := s.storedPost(id)
+	if !ok {
+		return Post{}, &KeyError{Key: id, Err: ErrKeyNotExist}
+	}
+	return p, nil
+}
+
+// readEntries returns the entries of the json file, none if it does not exist yet.
+func (s *pService) readEntries() ([]jsonPost, error) {
+	if _, err := os.Stat(s.fileName); errors.Is(err, os.ErrNotExist) {
+		return []jsonPost{}, nil
+	}
+	return readJsonPosts(s.storage, s.fileName)
+}
+
+// writeEntries replaces the json file with the given entries.
+func (s *pService) writeEntries(entries []jsonPost) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode posts : %v", err)
+	}
+	if err := fileutil.WriteAtomic(s.fileName, append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write json file : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package preview
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSignerVerify tests minting and verifying tokens
+func TestSignerVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewSigner([]byte("secret"))
+	assert.Nil(err)
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	token := s.Token("draft", now.Add(time.Hour))
+	assert.Nil(s.Verify("draft", token))
+	assert.ErrorIs(s.Verify("other", token), ErrInvalidToken, "token should be bound to the post")
+	assert.ErrorIs(s.Verify("draft", "garbage"), ErrInvalidToken)
+	assert.ErrorIs(s.Verify("draft", strings.Replace(token, token[:10], "9999999999", 1)), ErrInvalidToken, "expiry should be signed")
+
+	other, _ := NewSigner([]byte("other secret"))
+	assert.ErrorIs(other.Verify("draft", token), ErrInvalidToken, "token should be bound to the key")
+
+	now = now.Add(2 * time.Hour)
+	assert.ErrorIs(s.Verify("draft", token), ErrExpiredToken)
+}
+
+// TestSignerURL tests building preview URLs
+func TestSignerURL(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewSigner([]byte("secret"))
+	assert.Nil(err)
+
+	u, err := url.Parse(s.URL("https://example.com/", "my post", time.Hour))
+	assert.Nil(err)
+	assert.Equal("/preview/my post", u.Path)
+	assert.Nil(s.Verify("my post", u.Query().Get("token")))
+
+	_, err = NewSigner(nil)
+	assert.ErrorIs(err, ErrNoKey)
+}
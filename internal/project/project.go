@@ -0,0 +1,190 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+)
+
+// validID are the IDs a project can have, they are used in URLs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+const (
+	// StatusActive is a project under active development.
+	StatusActive Status = "active"
+	// StatusMaintained is a finished project that still gets fixes.
+	StatusMaintained Status = "maintained"
+	// StatusExperimental is a prototype or a work in progress.
+	StatusExperimental Status = "experimental"
+	// StatusArchived is a project that is not worked on anymore.
+	StatusArchived Status = "archived"
+)
+
+type (
+	// Status is the state of development of a project.
+	Status string
+
+	// ServiceOptions is a struct that contains the options for constructing a Service.
+	// FileName is the json file listing the projects, read from Storage; without it there are no projects.
+	ServiceOptions struct {
+		Logger   *logger.Logger
+		Storage  fs.FS
+		FileName string
+	}
+
+	// Service is an interface that defines the methods of the Service.
+	Service interface {
+		// GetProjects returns every project in the order of the projects file.
+		GetProjects(l logger.LoggingFn) ([]Project, error)
+		// GetProject returns a project by its ID.
+		// It returns a post.KeyError if the project does not exist.
+		GetProject(l logger.LoggingFn, id string) (Project, error)
+		// GetProjectsByTags returns the projects having any of the tags, in the order of the projects file.
+		GetProjectsByTags(l logger.LoggingFn, tags []string) ([]Project, error)
+	}
+
+	// Project is a piece of work presented on the projects page, as described in the projects file.
+	Project struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		// Repo is the URL of the source repository of the project.
+		Repo   string   `json:"repo"`
+		Status Status   `json:"status"`
+		Tags   []string `json:"tags"`
+		// Detail is the markdown file describing the project in length, relative to the projects file.
+		Detail string `json:"detail,omitempty"`
+		// Markdown is the content of the Detail file, read when the projects are loaded.
+		Markdown string `json:"-"`
+	}
+
+	// service is the implementation of the Service interface.
+	service struct {
+		projects []Project
+		byID     map[string]Project
+	}
+)
+
+// NewService returns a new Service with the projects of the file in options.
+// It returns an error if the file cannot be read or a project is invalid.
+func NewService(opts ServiceOptions) (Service, error) {
+	s := &service{byID: make(map[string]Project)}
+	if opts.FileName == "" {
+		return s, nil
+	}
+	projects, err := loadProjects(opts.Storage, opts.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("NewService: %v", err)
+	}
+	for _, p := range projects {
+		s.projects = append(s.projects, p)
+		s.byID[p.ID] = p
+	}
+	if opts.Logger != nil {
+		opts.Logger.Debug().Int("projects", len(s.projects)).Msg("NewService: projects loaded")
+	}
+	return s, nil
+}
+
+// GetProjects implements Service.
+func (s *service) GetProjects(l logger.LoggingFn) ([]Project, error) {
+	l(logger.DebugLevel).Msg("ProjectService::GetProjects")
+	return s.projects, nil
+}
+
+// GetProject implements Service.
+func (s *service) GetProject(l logger.LoggingFn, id string) (Project, error) {
+	l(logger.DebugLevel).Str("id", id).Msg("ProjectService::GetProject")
+	p, ok := s.byID[id]
+	if !ok {
+		return Project{}, &post.KeyError{Key: id, Err: post.ErrKeyNotExist}
+	}
+	return p, nil
+}
+
+// GetProjectsByTags implements Service.
+func (s *service) GetProjectsByTags(l logger.LoggingFn, tags []string) ([]Project, error) {
+	l(logger.DebugLevel).Strs("tags", tags).Msg("ProjectService::GetProjectsByTags")
+	projects := make([]Project, 0)
+	for _, p := range s.projects {
+		if p.HasAnyTag(tags) {
+			projects = append(projects, p)
+		}
+	}
+	return projects, nil
+}
+
+// HasAnyTag reports whether the project has any of the tags.
+func (p Project) HasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, own := range p.Tags {
+			if own == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadProjects reads and validates the projects of the json file fileName of fsys with their detail files.
+func loadProjects(fsys fs.FS, fileName string) ([]Project, error) {
+	data, err := fs.ReadFile(fsys, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("loadProjects: cannot read projects file : %v", err)
+	}
+	var projects []Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("loadProjects: cannot unmarshal projects file : %v", err)
+	}
+	seen := make(map[string]bool, len(projects))
+	for i := range projects {
+		p := &projects[i]
+		p.ID, p.Name = strings.TrimSpace(p.ID), strings.TrimSpace(p.Name)
+		switch {
+		case !validID.MatchString(p.ID):
+			return nil, fmt.Errorf("project #%d: invalid id %q", i+1, p.ID)
+		case seen[p.ID]:
+			return nil, fmt.Errorf("project %q: duplicate id", p.ID)
+		case p.Name == "":
+			return nil, fmt.Errorf("project %q: name is required", p.ID)
+		case p.Repo != "" && !validRepo(p.Repo):
+			return nil, fmt.Errorf("project %q: invalid repo %q", p.ID, p.Repo)
+		case p.Status != "" && !p.Status.valid():
+			return nil, fmt.Errorf("project %q: unknown status %q", p.ID, p.Status)
+		}
+		seen[p.ID] = true
+		if p.Detail != "" {
+			detail, err := fs.ReadFile(fsys, path.Join(path.Dir(fileName), p.Detail))
+			if err != nil {
+				return nil, fmt.Errorf("project %q: cannot read detail file : %v", p.ID, err)
+			}
+			p.Markdown = string(detail)
+		}
+	}
+	return projects, nil
+}
+
+// valid reports whether the status is one of the known ones.
+func (s Status) valid() bool {
+	switch s {
+	case StatusActive, StatusMaintained, StatusExperimental, StatusArchived:
+		return true
+	}
+	return false
+}
+
+// validRepo reports whether u is an absolute http(s) URL.
+func validRepo(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -0,0 +1,65 @@
+package project
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewService tests reading and validating the projects file
+func TestNewService(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys := fstest.MapFS{
+		"data/projects.json": {Data: []byte(`[
+			{"id": "blog", "name": " Silent Blog ", "description": "This blog.", "repo": "https://github.com/kegliz/silent-blog", "status": "active", "tags": ["golang", "htmx"], "detail": "projects/blog.md"},
+			{"id": "stars", "name": "Stars", "status": "archived", "tags": ["astronomy"]}
+		]`)},
+		"data/projects/blog.md": {Data: []byte("# Silent Blog\n")},
+		"duplicate.json":        {Data: []byte(`[{"id": "blog", "name": "Blog"}, {"id": "blog", "name": "Blog again"}]`)},
+		"nameless.json":         {Data: []byte(`[{"id": "blog"}]`)},
+		"badid.json":            {Data: []byte(`[{"id": "silent blog", "name": "Blog"}]`)},
+		"badrepo.json":          {Data: []byte(`[{"id": "blog", "name": "Blog", "repo": "javascript:alert(1)"}]`)},
+		"badstatus.json":        {Data: []byte(`[{"id": "blog", "name": "Blog", "status": "done"}]`)},
+		"nodetail.json":         {Data: []byte(`[{"id": "blog", "name": "Blog", "detail": "missing.md"}]`)},
+	}
+	l := logger.NewLogger(logger.LoggerOptions{}).ContextLoggingFn(&gin.Context{})
+
+	s, err := NewService(ServiceOptions{Storage: fsys, FileName: "data/projects.json"})
+	assert.Nil(err)
+	projects, err := s.GetProjects(l)
+	assert.Nil(err)
+	assert.Len(projects, 2)
+	assert.Equal("Silent Blog", projects[0].Name, "names should be trimmed")
+	assert.Equal("# Silent Blog\n", projects[0].Markdown, "the detail file should be read relative to the projects file")
+
+	stars, err := s.GetProject(l, "stars")
+	assert.Nil(err)
+	assert.Equal(StatusArchived, stars.Status)
+	_, err = s.GetProject(l, "moon")
+	var keyError *post.KeyError
+	assert.True(errors.As(err, &keyError))
+
+	tagged, err := s.GetProjectsByTags(l, []string{"htmx", "astronomy"})
+	assert.Nil(err)
+	assert.Equal(projects, tagged)
+	tagged, err = s.GetProjectsByTags(l, []string{"c"})
+	assert.Nil(err)
+	assert.Empty(tagged)
+
+	for _, fileName := range []string{"duplicate.json", "nameless.json", "badid.json", "badrepo.json", "badstatus.json", "nodetail.json", "missing.json"} {
+		_, err := NewService(ServiceOptions{Storage: fsys, FileName: fileName})
+		assert.NotNil(err, fileName)
+	}
+
+	empty, err := NewService(ServiceOptions{})
+	assert.Nil(err)
+	projects, err = empty.GetProjects(l)
+	assert.Nil(err)
+	assert.Empty(projects)
+}
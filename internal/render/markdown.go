@@ -0,0 +1,82 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/kegliz/silent-blog/internal/frontmatter"
+	"github.com/yuin/goldmark"
+
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// codeStyle is the chroma style used for highlighting code blocks.
+var codeStyle = chroma.MustNewStyle("custom", chroma.StyleEntries{
+	chroma.Background:           "bg:#1d1d1d",
+	chroma.Comment:              "#7ec699",
+	chroma.Keyword:              "#cc99cd",
+	chroma.KeywordDeclaration:   "#cc99cd",
+	chroma.KeywordNamespace:     "#cc99cd",
+	chroma.KeywordType:          "#cc99cd",
+	chroma.Operator:             "#67cdcc",
+	chroma.OperatorWord:         "#cdcd00",
+	chroma.NameClass:            "#f08d49",
+	chroma.NameBuiltin:          "#f08d49",
+	chroma.NameFunction:         "#f08d49",
+	chroma.NameException:        "bold #666699",
+	chroma.NameVariable:         "#21212c",
+	chroma.LiteralString:        "#999999",
+	chroma.LiteralNumber:        "#f08d49",
+	chroma.LiteralStringBoolean: "#f08d49",
+	chroma.Text:                 "#21212c",
+	chroma.Name:                 "#21212c",
+	chroma.Generic:              "#21212c",
+})
+
+// markdown is the goldmark engine shared by every conversion, it is safe for concurrent use.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(highlighting.WithCustomStyle(codeStyle))),
+	goldmark.WithParserOptions(
+		parser.WithAttribute(),
+		parser.WithAutoHeadingID(),
+	),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+		renderer.WithNodeRenderers(util.Prioritized(headingRenderer{}, 100)),
+	),
+)
+
+// Document is a markdown document converted to HTML with its headings.
+type Document struct {
+	HTML     string
+	Headings []Heading
+}
+
+// Convert converts a markdown document to HTML. The front matter of the document is skipped.
+func Convert(src []byte) (string, error) {
+	doc, err := ConvertDocument(src)
+	return doc.HTML, err
+}
+
+// ConvertDocument converts a markdown document to HTML and returns it with its headings.
+// Headings get an ID made of their text unless they set one with {#id}, and an anchor linking to it.
+// The front matter of the document is skipped.
+func ConvertDocument(src []byte) (Document, error) {
+	src = frontmatter.Strip(src)
+	ctx := parser.NewContext(parser.WithIDs(headingIDs{}))
+	root := markdown.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, src, root); err != nil {
+		return Document{}, fmt.Errorf("Convert: cannot convert markdown : %v", err)
+	}
+	return Document{HTML: buf.String(), Headings: findHeadings(root, src)}, nil
+}
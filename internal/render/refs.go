@@ -0,0 +1,101 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kegliz/silent-blog/internal/frontmatter"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+type (
+	// References are the images and links of a markdown document.
+	References struct {
+		Images []Image
+		// Links are the destinations of the links, autolinks included.
+		Links []string
+	}
+
+	// Image is an image of a markdown document, written in markdown or as an html img tag.
+	Image struct {
+		Destination string
+		Alt         string
+	}
+)
+
+var (
+	// imgTag matches the html img tags of a document.
+	imgTag = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	// imgAttr matches the src and alt attributes of an img tag.
+	imgAttr = regexp.MustCompile(`(?i)\b(src|alt)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// FindReferences returns the images and links of a markdown document.
+// The front matter of the document is skipped.
+func FindReferences(src []byte) References {
+	src = frontmatter.Strip(src)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	var refs References
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Image:
+			refs.Images = append(refs.Images, Image{
+				Destination: string(node.Destination),
+				Alt:         strings.TrimSpace(inlineText(node, src)),
+			})
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			refs.Links = append(refs.Links, string(node.Destination))
+		case *ast.AutoLink:
+			refs.Links = append(refs.Links, string(node.URL(src)))
+		case *ast.RawHTML:
+			for i := 0; i < node.Segments.Len(); i++ {
+				segment := node.Segments.At(i)
+				refs.Images = append(refs.Images, htmlImages(segment.Value(src))...)
+			}
+		case *ast.HTMLBlock:
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				refs.Images = append(refs.Images, htmlImages(line.Value(src))...)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return refs
+}
+
+// inlineText returns the text of the inline children of a node.
+func inlineText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := child.(*ast.Text); ok && entering {
+			b.Write(t.Segment.Value(src))
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// htmlImages returns the images of the img tags in a piece of html.
+func htmlImages(html []byte) []Image {
+	var images []Image
+	for _, tag := range imgTag.FindAll(html, -1) {
+		var image Image
+		for _, attr := range imgAttr.FindAllSubmatch(tag, -1) {
+			value := string(attr[2]) + string(attr[3]) + string(attr[4])
+			if strings.EqualFold(string(attr[1]), "src") {
+				image.Destination = value
+			} else {
+				image.Alt = strings.TrimSpace(value)
+			}
+		}
+		images = append(images, image)
+	}
+	return images
+}
@@ -0,0 +1,23 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFindReferences is a test for FindReferences
+func TestFindReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	refs := FindReferences([]byte("---\ntitle: Post\n---\n" +
+		"![A hedgehog](hedgehog.jpg) and ![](no-alt.png)\n\n" +
+		"See [the intro](/post/intro) or <https://example.com>.\n\n" +
+		"<img src=\"inline.png\" alt=\"\">\n"))
+	assert.Equal([]Image{
+		{Destination: "hedgehog.jpg", Alt: "A hedgehog"},
+		{Destination: "no-alt.png"},
+		{Destination: "inline.png"},
+	}, refs.Images)
+	assert.Equal([]string{"/post/intro", "https://example.com"}, refs.Links)
+}
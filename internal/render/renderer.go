@@ -0,0 +1,185 @@
+package render
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultMaxEntries is the cache size used when RendererOptions.MaxEntries is not set.
+const DefaultMaxEntries = 128
+
+type (
+	// RendererOptions is a struct that contains the options for constructing a Renderer.
+	RendererOptions struct {
+		// MaxEntries limits the number of rendered files kept in the cache.
+		MaxEntries int
+		// FS is where the files are read from, the local disk if not set.
+		FS fs.FS
+	}
+
+	// Renderer renders markdown files to HTML and caches the documents per file and modification time.
+	// Concurrent renders of the same file are collapsed into one.
+	Renderer struct {
+		maxEntries int
+		fsys       fs.FS
+		group      singleflight.Group
+
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		lru     *list.List
+
+		hits      atomic.Int64
+		misses    atomic.Int64
+		renders   atomic.Int64
+		evictions atomic.Int64
+	}
+
+	// Stats is a snapshot of the cache counters of a Renderer.
+	Stats struct {
+		Hits       int64 `json:"hits"`
+		Misses     int64 `json:"misses"`
+		Renders    int64 `json:"renders"`
+		Evictions  int64 `json:"evictions"`
+		Entries    int   `json:"entries"`
+		MaxEntries int   `json:"maxEntries"`
+	}
+
+	// cacheEntry is a rendered file.
+	cacheEntry struct {
+		fileName string
+		modTime  time.Time
+		doc      Document
+	}
+)
+
+// NewRenderer returns a new Renderer.
+func NewRenderer(opts RendererOptions) *Renderer {
+	maxEntries := opts.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Renderer{
+		maxEntries: maxEntries,
+		fsys:       opts.FS,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// RenderFile returns the HTML of a markdown file.
+// The file is only rendered again when its modification time changes.
+func (r *Renderer) RenderFile(l logger.LoggingFn, fileName string) (string, error) {
+	doc, err := r.RenderDocument(l, fileName)
+	return doc.HTML, err
+}
+
+// RenderDocument returns the HTML of a markdown file with its headings, cached as RenderFile does.
+func (r *Renderer) RenderDocument(l logger.LoggingFn, fileName string) (Document, error) {
+	info, err := r.stat(fileName)
+	if err != nil {
+		return Document{}, fmt.Errorf("RenderFile: cannot stat file : %v", err)
+	}
+	modTime := info.ModTime()
+
+	if doc, ok := r.lookup(fileName, modTime); ok {
+		r.hits.Add(1)
+		l(logger.DebugLevel).Str("filename", fileName).Msg("Renderer::RenderFile cache hit")
+		return doc, nil
+	}
+	r.misses.Add(1)
+
+	key := fileName + "@" + modTime.String()
+	doc, err, shared := r.group.Do(key, func() (interface{}, error) {
+		r.renders.Add(1)
+		src, err := r.readFile(fileName)
+		if err != nil {
+			return Document{}, fmt.Errorf("RenderFile: cannot read file : %v", err)
+		}
+		doc, err := ConvertDocument(src)
+		if err != nil {
+			return Document{}, err
+		}
+		r.store(fileName, modTime, doc)
+		return doc, nil
+	})
+	if err != nil {
+		return Document{}, err
+	}
+	l(logger.DebugLevel).Str("filename", fileName).Bool("shared", shared).Msg("Renderer::RenderFile rendered")
+	return doc.(Document), nil
+}
+
+// Stats returns the current cache counters.
+func (r *Renderer) Stats() Stats {
+	r.mu.Lock()
+	entries := r.lru.Len()
+	r.mu.Unlock()
+	return Stats{
+		Hits:       r.hits.Load(),
+		Misses:     r.misses.Load(),
+		Renders:    r.renders.Load(),
+		Evictions:  r.evictions.Load(),
+		Entries:    entries,
+		MaxEntries: r.maxEntries,
+	}
+}
+
+// stat returns the file info of a file of the renderer's file system.
+func (r *Renderer) stat(fileName string) (fs.FileInfo, error) {
+	if r.fsys == nil {
+		return os.Stat(fileName)
+	}
+	return fs.Stat(r.fsys, fileName)
+}
+
+// readFile reads a file of the renderer's file system.
+func (r *Renderer) readFile(fileName string) ([]byte, error) {
+	if r.fsys == nil {
+		return os.ReadFile(fileName)
+	}
+	return fs.ReadFile(r.fsys, fileName)
+}
+
+// lookup returns the cached document of a file if it was rendered from the same modification time.
+func (r *Renderer) lookup(fileName string, modTime time.Time) (Document, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elem, ok := r.entries[fileName]
+	if !ok {
+		return Document{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !entry.modTime.Equal(modTime) {
+		return Document{}, false
+	}
+	r.lru.MoveToFront(elem)
+	return entry.doc, true
+}
+
+// store puts a rendered file in the cache, evicting the least recently used entries over the limit.
+func (r *Renderer) store(fileName string, modTime time.Time, doc Document) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.entries[fileName]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.modTime = modTime
+		entry.doc = doc
+		r.lru.MoveToFront(elem)
+		return
+	}
+	r.entries[fileName] = r.lru.PushFront(&cacheEntry{fileName: fileName, modTime: modTime, doc: doc})
+	for r.lru.Len() > r.maxEntries {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).fileName)
+		r.evictions.Add(1)
+	}
+}
@@ -0,0 +1,126 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/stretchr/testify/suite"
+)
+
+type RendererTestSuite struct {
+	suite.Suite
+	LogFn logger.LoggingFn
+	Dir   string
+}
+
+func (s *RendererTestSuite) SetupSuite() {
+	l := logger.NewLogger(logger.LoggerOptions{
+		Debug: true,
+	})
+	s.LogFn = l.ContextLoggingFn(&gin.Context{})
+}
+
+func (s *RendererTestSuite) SetupTest() {
+	s.Dir = s.T().TempDir()
+}
+
+func (s *RendererTestSuite) writeFile(name string, content string, modTime time.Time) string {
+	fileName := filepath.Join(s.Dir, name)
+	s.Require().NoError(os.WriteFile(fileName, []byte(content), 0644))
+	s.Require().NoError(os.Chtimes(fileName, modTime, modTime))
+	return fileName
+}
+
+// TestRenderFileCaches tests that a file is rendered once until it is modified
+func (s *RendererTestSuite) TestRenderFileCaches() {
+	r := NewRenderer(RendererOptions{})
+	modTime := time.Now().Add(-time.Hour)
+	fileName := s.writeFile("post.md", "---\ntitle: Post\n---\n# First\n", modTime)
+
+	html, err := r.RenderFile(s.LogFn, fileName)
+	s.Require().NoError(err)
+	s.Require().Contains(html, "<h1 id=\"first\">First<a")
+	s.Require().NotContains(html, "title:")
+
+	_, err = r.RenderFile(s.LogFn, fileName)
+	s.Require().NoError(err)
+	s.Require().Equal(Stats{Hits: 1, Misses: 1, Renders: 1, Entries: 1, MaxEntries: DefaultMaxEntries}, r.Stats())
+
+	s.writeFile("post.md", "# Second\n", modTime.Add(time.Minute))
+	html, err = r.RenderFile(s.LogFn, fileName)
+	s.Require().NoError(err)
+	s.Require().Contains(html, "<h1 id=\"second\">Second<a", "modified file should be rendered again")
+	s.Require().Equal(int64(2), r.Stats().Renders)
+	s.Require().Equal(1, r.Stats().Entries)
+}
+
+// TestRenderFileEvicts tests that the cache does not grow over its limit
+func (s *RendererTestSuite) TestRenderFileEvicts() {
+	r := NewRenderer(RendererOptions{MaxEntries: 2})
+	modTime := time.Now()
+	first := s.writeFile("1.md", "# 1", modTime)
+	second := s.writeFile("2.md", "# 2", modTime)
+	third := s.writeFile("3.md", "# 3", modTime)
+
+	for _, fileName := range []string{first, second, first, third} {
+		_, err := r.RenderFile(s.LogFn, fileName)
+		s.Require().NoError(err)
+	}
+	stats := r.Stats()
+	s.Require().Equal(2, stats.Entries)
+	s.Require().Equal(int64(1), stats.Evictions)
+
+	_, err := r.RenderFile(s.LogFn, first)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(2), r.Stats().Hits, "recently used file should have been kept")
+}
+
+// TestRenderFileConcurrent tests that concurrent renders of the same file render it once
+func (s *RendererTestSuite) TestRenderFileConcurrent() {
+	r := NewRenderer(RendererOptions{})
+	fileName := s.writeFile("post.md", "# Concurrent", time.Now())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			html, err := r.RenderFile(s.LogFn, fileName)
+			s.NoError(err)
+			s.Contains(html, "<h1 id=\"concurrent\">Concurrent<a")
+		}()
+	}
+	wg.Wait()
+	stats := r.Stats()
+	s.Require().Equal(int64(1), stats.Renders)
+	s.Require().Equal(int64(50), stats.Hits+stats.Misses)
+}
+
+// TestRenderFileMissing tests rendering a file that does not exist
+func (s *RendererTestSuite) TestRenderFileMissing() {
+	r := NewRenderer(RendererOptions{})
+	_, err := r.RenderFile(s.LogFn, filepath.Join(s.Dir, "missing.md"))
+	s.Require().ErrorContains(err, "no such file or directory")
+}
+
+// TestRenderFileFromFS tests rendering the files of a fs.FS
+func (s *RendererTestSuite) TestRenderFileFromFS() {
+	fsys := fstest.MapFS{"posts/post.md": {Data: []byte("# From FS"), ModTime: time.Now()}}
+	r := NewRenderer(RendererOptions{FS: fsys})
+	html, err := r.RenderFile(s.LogFn, "posts/post.md")
+	s.Require().NoError(err)
+	s.Require().Contains(html, "<h1 id=\"from-fs\">From FS<a")
+
+	_, err = r.RenderFile(s.LogFn, "posts/missing.md")
+	s.Require().Error(err)
+}
+
+func TestRendererTestSuite(t *testing.T) {
+	suite.Run(t, new(RendererTestSuite))
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/kegliz/silent-blog/internal/frontmatter"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// moreMarker ends the excerpt of a document when it stands on its own line.
+const moreMarker = "<!--more-->"
+
+// Summary is the text of a markdown document with its word count and excerpt.
+type Summary struct {
+	// Text is the text content of the document, as returned by PlainText.
+	Text  string
+	Words int
+	// Excerpt is the text before the <!--more--> marker, or else the text of the first paragraph, on one line.
+	Excerpt string
+}
+
+// PlainText returns the text content of a markdown document without any markup.
+// Blocks are separated by new lines. The front matter of the document is skipped.
+func PlainText(src []byte) string {
+	return Summarize(src).Text
+}
+
+// Summarize returns the text, the word count and the excerpt of a markdown document.
+// The front matter of the document is skipped.
+func Summarize(src []byte) Summary {
+	src = frontmatter.Strip(src)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	// the first paragraph is the excerpt unless a marker ends it
+	var excerpt, before []ast.Node
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if isMoreMarker(n, src) {
+			excerpt = before
+			break
+		}
+		before = append(before, n)
+		if excerpt == nil && n.Kind() == ast.KindParagraph {
+			excerpt = []ast.Node{n}
+		}
+	}
+
+	s := Summary{Text: nodesText(src, doc)}
+	s.Words = len(strings.Fields(s.Text))
+	s.Excerpt = strings.Join(strings.Fields(nodesText(src, excerpt...)), " ")
+	return s
+}
+
+// isMoreMarker reports whether a block is the <!--more--> marker.
+func isMoreMarker(n ast.Node, src []byte) bool {
+	if n.Kind() != ast.KindHTMLBlock {
+		return false
+	}
+	var b bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		b.Write(line.Value(src))
+	}
+	return strings.Join(strings.Fields(b.String()), "") == moreMarker
+}
+
+// nodesText returns the text content of nodes without any markup, blocks are separated by new lines.
+func nodesText(src []byte, nodes ...ast.Node) string {
+	var b strings.Builder
+	endBlock := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	for _, root := range nodes {
+		_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			switch node := n.(type) {
+			case *ast.Text:
+				if entering {
+					b.Write(node.Segment.Value(src))
+					if node.HardLineBreak() || node.SoftLineBreak() {
+						b.WriteByte(' ')
+					}
+				}
+			case *ast.String:
+				if entering {
+					b.Write(node.Value)
+				}
+			case *ast.CodeBlock, *ast.FencedCodeBlock:
+				if entering {
+					lines := n.Lines()
+					for i := 0; i < lines.Len(); i++ {
+						line := lines.At(i)
+						b.Write(line.Value(src))
+					}
+				} else {
+					endBlock()
+				}
+			default:
+				if !entering && n.Type() == ast.TypeBlock {
+					endBlock()
+				}
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,33 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPlainText is a test for PlainText
+func TestPlainText(t *testing.T) {
+	assert := assert.New(t)
+
+	text := PlainText([]byte("---\ntitle: Post\n---\n# Heading\n\nSome **bold** and [a link](https://example.com).\n\n```go\nfmt.Println(1)\n```\n"))
+	assert.Equal("Heading\nSome bold and a link.\nfmt.Println(1)", text)
+}
+
+// TestSummarize is a test for Summarize
+func TestSummarize(t *testing.T) {
+	assert := assert.New(t)
+
+	s := Summarize([]byte("---\ntitle: Post\n---\n# Heading\n\nThe *first*\nparagraph.\n\nThe second one.\n"))
+	assert.Equal("Heading\nThe first paragraph.\nThe second one.", s.Text)
+	assert.Equal(7, s.Words)
+	assert.Equal("The first paragraph.", s.Excerpt, "the first paragraph should be the excerpt")
+
+	s = Summarize([]byte("# Heading\n\nThe first paragraph.\n\n- and a [list](/list)\n<!-- more -->\n\nThe rest.\n"))
+	assert.Equal("Heading The first paragraph. and a list", s.Excerpt, "the text before the marker should be the excerpt")
+	assert.Equal(9, s.Words)
+
+	s = Summarize([]byte("```\n<!--more-->\n```\n"))
+	assert.Empty(s.Excerpt, "a marker in code should be ignored")
+	assert.Equal(1, s.Words)
+}
@@ -0,0 +1,142 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+type (
+	// Heading is a heading of a document with the ID its anchor links to.
+	Heading struct {
+		Level int
+		ID    string
+		Text  string
+	}
+
+	// TOCEntry is a heading of a table of contents with the headings of its section.
+	TOCEntry struct {
+		Heading
+		Children []TOCEntry
+	}
+
+	// headingRenderer renders the headings with an anchor linking to their ID.
+	headingRenderer struct{}
+
+	// headingIDs generates the IDs of the headings of a document, unique within the document.
+	// Unlike the goldmark default it keeps non-ASCII letters, so that the IDs stay readable in any language.
+	headingIDs map[string]bool
+)
+
+// NewTOC returns the table of contents of a document from its headings.
+// The level 1 heading is the title of the document, depth is the number of levels below it that are listed.
+// A document with less than two such headings has no table of contents.
+func NewTOC(headings []Heading, depth int) []TOCEntry {
+	var listed []Heading
+	for _, h := range headings {
+		if h.Level > 1 && h.Level <= depth+1 && h.ID != "" {
+			listed = append(listed, h)
+		}
+	}
+	if len(listed) < 2 {
+		return nil
+	}
+	return nestHeadings(listed)
+}
+
+// nestHeadings places every heading under the closest preceding heading of a higher level.
+func nestHeadings(headings []Heading) []TOCEntry {
+	var entries []TOCEntry
+	for i := 0; i < len(headings); {
+		end := i + 1
+		for end < len(headings) && headings[end].Level > headings[i].Level {
+			end++
+		}
+		entries = append(entries, TOCEntry{Heading: headings[i], Children: nestHeadings(headings[i+1 : end])})
+		i = end
+	}
+	return entries
+}
+
+// findHeadings returns the headings of a parsed document in order.
+func findHeadings(doc ast.Node, src []byte) []Heading {
+	var headings []Heading
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		h := Heading{Level: heading.Level, Text: strings.TrimSpace(inlineText(heading, src))}
+		if id, ok := heading.AttributeString("id"); ok {
+			if id, ok := id.([]byte); ok {
+				h.ID = string(id)
+			}
+		}
+		headings = append(headings, h)
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// Generate implements parser.IDs, the ID is the lower case text of the heading with dashes between words.
+func (ids headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(string(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			b.WriteByte('-')
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "section"
+	}
+	unique := id
+	for i := 1; ids[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", id, i)
+	}
+	ids[unique] = true
+	return []byte(unique)
+}
+
+// Put implements parser.IDs.
+func (ids headingIDs) Put(value []byte) {
+	ids[string(value)] = true
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r headingRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+// renderHeading renders a heading as goldmark does, followed by an anchor when it has an ID.
+func (r headingRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	if entering {
+		_, _ = w.WriteString("<h")
+		_ = w.WriteByte("0123456"[n.Level])
+		if n.Attributes() != nil {
+			html.RenderAttributes(w, node, html.HeadingAttributeFilter)
+		}
+		_ = w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+	if id, ok := n.AttributeString("id"); ok {
+		if id, ok := id.([]byte); ok {
+			_, _ = w.WriteString(`<a class="heading-anchor" href="#`)
+			_, _ = w.Write(util.EscapeHTML(util.URLEscape(id, false)))
+			_, _ = w.WriteString(`" aria-label="Link to this section">#</a>`)
+		}
+	}
+	_, _ = w.WriteString("</h")
+	_ = w.WriteByte("0123456"[n.Level])
+	_, _ = w.WriteString(">\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConvertDocument tests the heading IDs and anchors and the headings returned with the HTML
+func TestConvertDocument(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := ConvertDocument([]byte("---\ntitle: Post\n---\n# Title\n\n## Getting `started`\n\n### Über die *Sache*\n\n## Getting started\n\n## Custom {#my-id}\n"))
+	assert.Nil(err)
+	assert.Contains(doc.HTML, `<h2 id="getting-started">Getting <code>started</code><a class="heading-anchor" href="#getting-started" aria-label="Link to this section">#</a></h2>`)
+	assert.Contains(doc.HTML, `<h3 id="über-die-sache">`)
+	assert.Contains(doc.HTML, `href="#%C3%BCber-die-sache"`)
+	assert.Contains(doc.HTML, `<h2 id="my-id">Custom<a class="heading-anchor" href="#my-id"`)
+	assert.Equal([]Heading{
+		{Level: 1, ID: "title", Text: "Title"},
+		{Level: 2, ID: "getting-started", Text: "Getting started"},
+		{Level: 3, ID: "über-die-sache", Text: "Über die Sache"},
+		{Level: 2, ID: "getting-started-1", Text: "Getting started"},
+		{Level: 2, ID: "my-id", Text: "Custom"},
+	}, doc.Headings, "repeated headings should get distinct IDs")
+
+	again, err := ConvertDocument([]byte("# Title\n\n## Getting started\n"))
+	assert.Nil(err)
+	assert.Equal("getting-started", again.Headings[1].ID, "the IDs should not depend on earlier documents")
+}
+
+// TestNewTOC tests the nesting and the depth of tables of contents
+func TestNewTOC(t *testing.T) {
+	assert := assert.New(t)
+
+	headings := []Heading{
+		{Level: 1, ID: "title", Text: "Title"},
+		{Level: 2, ID: "a", Text: "A"},
+		{Level: 3, ID: "a1", Text: "A1"},
+		{Level: 4, ID: "a1x", Text: "A1x"},
+		{Level: 2, ID: "b", Text: "B"},
+		{Level: 4, ID: "b1", Text: "B1"},
+	}
+	assert.Equal([]TOCEntry{
+		{Heading: headings[1], Children: []TOCEntry{{Heading: headings[2]}}},
+		{Heading: headings[4]},
+	}, NewTOC(headings, 2))
+	assert.Equal([]TOCEntry{
+		{Heading: headings[1], Children: []TOCEntry{{Heading: headings[2], Children: []TOCEntry{{Heading: headings[3]}}}}},
+		{Heading: headings[4], Children: []TOCEntry{{Heading: headings[5]}}},
+	}, NewTOC(headings, 3), "skipped levels should nest under the closest heading")
+	assert.Len(NewTOC(headings, 1), 2)
+	assert.Nil(NewTOC(headings, 0))
+	assert.Nil(NewTOC(headings[:3], 1), "a single section should have no table of contents")
+}
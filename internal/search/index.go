@@ -0,0 +1,182 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Field weights of a term occurrence, a match in the title counts more than one in the body.
+const (
+	titleWeight = 3.0
+	tagWeight   = 2.0
+	bodyWeight  = 1.0
+	// prefixPenalty scales the score of terms that only match a query word as a prefix.
+	prefixPenalty = 0.5
+)
+
+type (
+	// Document is the searchable content of a post.
+	Document struct {
+		ID    string
+		Title string
+		Tags  []string
+		Year  int
+		Body  string
+	}
+
+	// Result is a document matching a query.
+	Result struct {
+		ID      string
+		Score   float64
+		Snippet []Fragment
+	}
+
+	// Index is an immutable in-memory inverted index, it is safe for concurrent use.
+	Index struct {
+		docs     []indexedDoc
+		postings map[string][]posting
+		// terms is the sorted list of the indexed terms used for prefix lookups.
+		terms []string
+	}
+
+	// indexedDoc is a document together with its tokenized body.
+	indexedDoc struct {
+		Document
+		tags   map[string]bool
+		tokens []token
+	}
+
+	// posting is the weighted number of occurrences of a term in a document.
+	posting struct {
+		doc    int
+		weight float64
+	}
+)
+
+// NewIndex builds an index of the documents. Documents with the same score are
+// returned in the order they are given here.
+func NewIndex(docs []Document) *Index {
+	ix := &Index{
+		docs:     make([]indexedDoc, len(docs)),
+		postings: make(map[string][]posting),
+	}
+	for i, doc := range docs {
+		weights := make(map[string]float64)
+		for _, t := range tokenize(doc.Title) {
+			weights[t.term] += titleWeight
+		}
+		tags := make(map[string]bool, len(doc.Tags))
+		for _, tag := range doc.Tags {
+			tags[strings.ToLower(tag)] = true
+			for _, t := range tokenize(tag) {
+				weights[t.term] += tagWeight
+			}
+		}
+		tokens := tokenize(doc.Body)
+		for _, t := range tokens {
+			weights[t.term] += bodyWeight
+		}
+		for term, weight := range weights {
+			ix.postings[term] = append(ix.postings[term], posting{doc: i, weight: weight})
+		}
+		ix.docs[i] = indexedDoc{Document: doc, tags: tags, tokens: tokens}
+	}
+	ix.terms = make([]string, 0, len(ix.postings))
+	for term := range ix.postings {
+		ix.terms = append(ix.terms, term)
+	}
+	sort.Strings(ix.terms)
+	return ix
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Search returns the documents matching every word of the query and all of its filters,
+// best match first. A limit below 1 returns every match.
+func (ix *Index) Search(q Query, limit int) []Result {
+	if q.IsEmpty() {
+		return nil
+	}
+	scores := make(map[int]float64)
+	for i := range ix.docs {
+		if ix.docs[i].matchesFilters(q) {
+			scores[i] = 0
+		}
+	}
+	for _, word := range q.Words {
+		wordScores := ix.wordScores(word)
+		for doc := range scores {
+			score, ok := wordScores[doc]
+			if !ok {
+				delete(scores, doc)
+				continue
+			}
+			scores[doc] += score
+		}
+	}
+
+	matches := make([]int, 0, len(scores))
+	for doc := range scores {
+		matches = append(matches, doc)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if scores[matches[i]] != scores[matches[j]] {
+			return scores[matches[i]] > scores[matches[j]]
+		}
+		return matches[i] < matches[j]
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]Result, len(matches))
+	for i, doc := range matches {
+		d := &ix.docs[doc]
+		results[i] = Result{ID: d.ID, Score: scores[doc], Snippet: snippet(d.Body, d.tokens, q.Words)}
+	}
+	return results
+}
+
+// wordScores returns the score of every document containing a term starting with word.
+// The score is a tf-idf weight, terms that only share the prefix are penalized.
+func (ix *Index) wordScores(word string) map[int]float64 {
+	scores := make(map[int]float64)
+	start := sort.SearchStrings(ix.terms, word)
+	for _, term := range ix.terms[start:] {
+		if !strings.HasPrefix(term, word) {
+			break
+		}
+		postings := ix.postings[term]
+		idf := math.Log(1 + float64(len(ix.docs))/float64(len(postings)))
+		factor := 1.0
+		if term != word {
+			factor = prefixPenalty
+		}
+		for _, p := range postings {
+			scores[p.doc] += factor * idf * (1 + math.Log(p.weight))
+		}
+	}
+	return scores
+}
+
+// matchesFilters reports whether the document passes the tag and year filters of the query.
+func (d *indexedDoc) matchesFilters(q Query) bool {
+	for _, tag := range q.Tags {
+		if !d.tags[tag] {
+			return false
+		}
+	}
+	if len(q.Years) == 0 {
+		return true
+	}
+	for _, year := range q.Years {
+		if d.Year == year {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IndexTestSuite struct {
+	suite.Suite
+	Index *Index
+}
+
+func (s *IndexTestSuite) SetupSuite() {
+	s.Index = NewIndex([]Document{
+		{
+			ID:    "goroutines",
+			Title: "Leaking goroutines",
+			Tags:  []string{"golang"},
+			Year:  2024,
+			Body:  "A goroutine that never returns keeps its stack alive. Channels are the usual suspects.",
+		},
+		{
+			ID:    "channels",
+			Title: "Channel patterns",
+			Tags:  []string{"golang", "concurrency"},
+			Year:  2023,
+			Body:  "Fan-in and fan-out with channels, and how a goroutine should stop.",
+		},
+		{
+			ID:    "stars",
+			Title: "Looking at the stars",
+			Tags:  []string{"astronomy"},
+			Year:  2023,
+			Body:  "The heliocentric model put the sun in the centre.",
+		},
+	})
+}
+
+// TestParseQuery tests parsing words and filters
+func (s *IndexTestSuite) TestParseQuery() {
+	q := ParseQuery("Goroutine LEAK tag:GoLang year:2023 year:abc")
+	s.Equal([]string{"goroutine", "leak"}, q.Words)
+	s.Equal([]string{"golang"}, q.Tags)
+	s.Equal([]int{2023}, q.Years)
+	s.True(ParseQuery("  ").IsEmpty())
+}
+
+// TestSearchRanking tests that title matches rank above body matches
+func (s *IndexTestSuite) TestSearchRanking() {
+	results := s.Index.Search(ParseQuery("channel"), 0)
+	s.Require().Len(results, 2)
+	s.Equal("channels", results[0].ID, "title match should rank first")
+	s.Equal("goroutines", results[1].ID)
+}
+
+// TestSearchPrefixAndAllWords tests prefix matching and that every word must match
+func (s *IndexTestSuite) TestSearchPrefixAndAllWords() {
+	results := s.Index.Search(ParseQuery("gorout"), 0)
+	s.Len(results, 2)
+
+	results = s.Index.Search(ParseQuery("gorout stack"), 0)
+	s.Require().Len(results, 1)
+	s.Equal("goroutines", results[0].ID)
+
+	s.Empty(s.Index.Search(ParseQuery("gorout heliocentric"), 0))
+}
+
+// TestSearchFilters tests the tag and year filters
+func (s *IndexTestSuite) TestSearchFilters() {
+	results := s.Index.Search(ParseQuery("goroutine year:2023"), 0)
+	s.Require().Len(results, 1)
+	s.Equal("channels", results[0].ID)
+
+	results = s.Index.Search(ParseQuery("tag:golang"), 0)
+	s.Require().Len(results, 2)
+	s.Equal("goroutines", results[0].ID, "equal scores should keep the document order")
+
+	results = s.Index.Search(ParseQuery("tag:golang tag:concurrency"), 1)
+	s.Require().Len(results, 1)
+	s.Equal("channels", results[0].ID)
+}
+
+// TestSearchSnippet tests that the snippet highlights the matching words
+func (s *IndexTestSuite) TestSearchSnippet() {
+	results := s.Index.Search(ParseQuery("sun"), 0)
+	s.Require().Len(results, 1)
+	s.Equal([]Fragment{
+		{Text: "The heliocentric model put the "},
+		{Text: "sun", Highlight: true},
+		{Text: " in the centre."},
+	}, results[0].Snippet)
+}
+
+func TestIndexTestSuite(t *testing.T) {
+	suite.Run(t, new(IndexTestSuite))
+}
@@ -0,0 +1,45 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed search query.
+type Query struct {
+	// Words must all be found, each as a prefix of an indexed term.
+	Words []string
+	// Tags must all be present on a document.
+	Tags []string
+	// Years restricts the documents to any of these years.
+	Years []int
+}
+
+// ParseQuery parses a query such as "goroutine leak tag:golang year:2023".
+// Filters with an invalid value are ignored.
+func ParseQuery(s string) Query {
+	var q Query
+	for _, field := range strings.Fields(s) {
+		key, value, found := strings.Cut(field, ":")
+		switch {
+		case found && strings.EqualFold(key, "tag"):
+			if value != "" {
+				q.Tags = append(q.Tags, strings.ToLower(value))
+			}
+		case found && strings.EqualFold(key, "year"):
+			if year, err := strconv.Atoi(value); err == nil {
+				q.Years = append(q.Years, year)
+			}
+		default:
+			for _, t := range tokenize(field) {
+				q.Words = append(q.Words, t.term)
+			}
+		}
+	}
+	return q
+}
+
+// IsEmpty reports whether the query has neither words nor filters.
+func (q Query) IsEmpty() bool {
+	return len(q.Words) == 0 && len(q.Tags) == 0 && len(q.Years) == 0
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetWords is the number of words around the first match shown in a snippet.
+const snippetWords = 24
+
+type (
+	// Fragment is a piece of a snippet, Highlight is set on the words matching the query.
+	Fragment struct {
+		Text      string
+		Highlight bool
+	}
+
+	// token is a term of a text with its byte offsets in the text.
+	token struct {
+		term       string
+		start, end int
+	}
+)
+
+// tokenize splits a text into lower case terms of letters and digits.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// snippet cuts a window of the body around the first token matching any of the words
+// and highlights every matching token in it.
+func snippet(body string, tokens []token, words []string) []Fragment {
+	if len(tokens) == 0 {
+		return nil
+	}
+	matches := func(t token) bool {
+		for _, w := range words {
+			if strings.HasPrefix(t.term, w) {
+				return true
+			}
+		}
+		return false
+	}
+	first := 0
+	for i, t := range tokens {
+		if matches(t) {
+			first = i
+			break
+		}
+	}
+	from := max(first-snippetWords/3, 0)
+	to := min(from+snippetWords, len(tokens))
+
+	var fragments []Fragment
+	appendText := func(text string, highlight bool) {
+		text = collapseSpace(text)
+		if text == "" {
+			return
+		}
+		if n := len(fragments); n > 0 && fragments[n-1].Highlight == highlight {
+			fragments[n-1].Text += text
+			return
+		}
+		fragments = append(fragments, Fragment{Text: text, Highlight: highlight})
+	}
+	if from > 0 {
+		appendText("… ", false)
+	}
+	offset := tokens[from].start
+	for _, t := range tokens[from:to] {
+		appendText(body[offset:t.start], false)
+		appendText(body[t.start:t.end], matches(t))
+		offset = t.end
+	}
+	if to < len(tokens) {
+		appendText(" …", false)
+	} else {
+		appendText(body[offset:], false)
+	}
+	return fragments
+}
+
+// collapseSpace replaces every run of white space with a single space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
@@ -75,6 +75,22 @@ func (logger *Logger) ContextLoggingFn(c *gin.Context) LoggingFn {
 	}
 }
 
+// BackgroundLoggingFn creates a LoggingFn without request context, for the work done outside of requests.
+func (logger *Logger) BackgroundLoggingFn() LoggingFn {
+	return func(level logLevel) *zerolog.Event {
+		switch level {
+		case DebugLevel:
+			return logger.Debug()
+		case WarnLevel:
+			return logger.Warn()
+		case ErrorLevel:
+			return logger.Error()
+		default:
+			return logger.Info()
+		}
+	}
+}
+
 func (l *Logger) Infoc(c *gin.Context) *zerolog.Event {
 	return injectContextVars(c, l.Info())
 }
@@ -22,9 +22,13 @@ type (
 		HTTPSServer *http.Server
 	}
 
+	// RouterOptions is a struct that contains the options for constructing a Router.
+	// The client IP of a request is only read from the X-Forwarded-For and X-Real-IP headers set by the TrustedProxies,
+	// without any the headers are ignored.
 	RouterOptions struct {
-		Logger   *logger.Logger
-		BasePath string
+		Logger         *logger.Logger
+		BasePath       string
+		TrustedProxies []string
 	}
 
 	Route struct {
@@ -32,6 +36,8 @@ type (
 		Method      string
 		Pattern     string
 		HandlerFunc gin.HandlerFunc
+		// Middleware runs before HandlerFunc, e.g. to require authentication.
+		Middleware []gin.HandlerFunc
 	}
 
 	ErrNoServerToShutdown struct{}
@@ -45,6 +51,10 @@ func (e *ErrNoServerToShutdown) Error() string {
 func NewRouter(options RouterOptions) *Router {
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
+	if err := engine.SetTrustedProxies(options.TrustedProxies); err != nil {
+		options.Logger.Error().Err(err).Msg("NewRouter: invalid trusted proxies, forwarding headers are ignored")
+		_ = engine.SetTrustedProxies(nil)
+	}
 
 	engine.Static("/static", "./public") //TODO it should be configurable
 
@@ -126,15 +136,16 @@ func (r *Router) Shutdown(ctx context.Context) error {
 func (r *Router) SetRoutes(routes []*Route) {
 	r.Routes = routes
 	for _, route := range routes {
+		handlers := append(append([]gin.HandlerFunc{}, route.Middleware...), route.HandlerFunc)
 		switch route.Method {
 		case http.MethodGet:
-			r.GET(route.Pattern, route.HandlerFunc)
+			r.GET(route.Pattern, handlers...)
 		case http.MethodPost:
-			r.POST(route.Pattern, route.HandlerFunc)
+			r.POST(route.Pattern, handlers...)
 		case http.MethodPut:
-			r.PUT(route.Pattern, route.HandlerFunc)
+			r.PUT(route.Pattern, handlers...)
 		case http.MethodDelete:
-			r.DELETE(route.Pattern, route.HandlerFunc)
+			r.DELETE(route.Pattern, handlers...)
 		}
 		r.Logger.Info().Msgf("Route %s %s registered", route.Method, r.BasePath+route.Pattern)
 	}
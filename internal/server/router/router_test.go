@@ -49,6 +49,17 @@ func (s *RouterTestSuite) SetupSuite() {
 			Pattern:     "/obj/:param",
 			HandlerFunc: func(c *gin.Context) { c.Data(http.StatusOK, "text/html", []byte(c.Param("param"))) },
 		},
+		{
+			Name:        "guarded",
+			Method:      http.MethodGet,
+			Pattern:     "/guarded",
+			HandlerFunc: func(c *gin.Context) { c.Data(http.StatusOK, "text/html", []byte("200")) },
+			Middleware: []gin.HandlerFunc{func(c *gin.Context) {
+				if c.Query("key") != "open" {
+					c.AbortWithStatus(http.StatusUnauthorized)
+				}
+			}},
+		},
 	}
 	s.BasePathRouter = NewRouter(RouterOptions{
 		Logger: log,
@@ -68,6 +79,37 @@ func (s *RouterTestSuite) TestBasePathRouter() {
 	s.Equal("rudolf", rec.Body.String(), "200 GET "+"/obj/rudolf")
 }
 
+func (s *RouterTestSuite) TestRouteMiddleware() {
+	rec := s.doRequest(http.MethodGet, "/guarded")
+	s.Equal(http.StatusUnauthorized, rec.Code, "401 GET "+"/guarded")
+
+	rec = s.doRequest(http.MethodGet, "/guarded?key=open")
+	s.Equal(http.StatusOK, rec.Code, "200 GET "+"/guarded?key=open")
+}
+
+func (s *RouterTestSuite) TestTrustedProxies() {
+	clientIP := func(proxies []string, remoteAddr string) string {
+		r := NewRouter(RouterOptions{Logger: s.BasePathRouter.Logger, TrustedProxies: proxies})
+		r.SetRoutes([]*Route{{
+			Name:        "ip",
+			Method:      http.MethodGet,
+			Pattern:     "/ip",
+			HandlerFunc: func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) },
+		}})
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		r.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	s.Equal("198.51.100.1", clientIP(nil, "198.51.100.1:4000"), "forwarding headers should be ignored without trusted proxies")
+	s.Equal("203.0.113.7", clientIP([]string{"127.0.0.1"}, "127.0.0.1:4000"), "a trusted proxy should forward the client IP")
+	s.Equal("198.51.100.1", clientIP([]string{"127.0.0.1"}, "198.51.100.1:4000"), "other clients should not forward an IP")
+	s.Equal("198.51.100.1", clientIP([]string{"not an address"}, "198.51.100.1:4000"), "invalid proxies should trust none")
+}
+
 func TestRouterTestSuite(t *testing.T) {
 	suite.Run(t, new(RouterTestSuite))
 }
@@ -10,6 +10,8 @@ import (
 type (
 	EngineOptions struct {
 		Debug bool
+		// TrustedProxies are the addresses or networks of the reverse proxies whose forwarding headers are trusted
+		TrustedProxies []string
 	}
 
 	Server interface {
@@ -23,7 +25,8 @@ func NewLoggerAndRouter(options EngineOptions) (l *logger.Logger, r *router.Rout
 		Debug: options.Debug,
 	})
 	r = router.NewRouter(router.RouterOptions{
-		Logger: l,
+		Logger:         l,
+		TrustedProxies: options.TrustedProxies,
 	})
 	return
 }
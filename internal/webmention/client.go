@@ -0,0 +1,155 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// userAgent is sent with every request of the package
+	userAgent = "silent-blog-webmention"
+	// maxPageSize is the most read of a fetched page
+	maxPageSize = 1 << 20
+)
+
+var ErrPrivateAddress = errors.New("address is not public")
+
+// NewClient returns the http client fetching the remote pages.
+// Unless allowPrivate is set, it refuses to connect to loopback, private and link-local addresses,
+// so a mention cannot make the server probe its own network.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+				return fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+	}
+	return &http.Client{Transport: transport, Timeout: 15 * time.Second}
+}
+
+// validURL reports whether u is an absolute http(s) URL.
+func validURL(u string) (*url.URL, bool) {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// fetch gets a page and parses it, the response is returned with its body closed.
+// Pages which are not HTML have no document.
+func fetch(ctx context.Context, client *http.Client, pageURL string) (*http.Response, *html.Node, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxPageSize))
+		return resp, nil, nil
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, doc, nil
+}
+
+// walk calls visit on n and every element below it, in document order.
+func walk(n *html.Node, visit func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		visit(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
+
+// find returns the first element below n, n included, matching match.
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	var found *html.Node
+	walk(n, func(e *html.Node) {
+		if found == nil && match(e) {
+			found = e
+		}
+	})
+	return found
+}
+
+// attr returns an attribute of an element, empty if it is not set.
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasToken reports whether a space separated attribute of an element, like class or rel, holds token.
+func hasToken(n *html.Node, name string, token string) bool {
+	for _, t := range strings.Fields(attr(n, name)) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// text returns the text of an element with its whitespace collapsed.
+func text(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// resolve returns href relative to base as an absolute URL without fragment, empty if it is invalid.
+func resolve(base *url.URL, href string) string {
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,239 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"golang.org/x/net/html"
+)
+
+// maxContentLength is the most kept of the content of a reply, in characters
+const maxContentLength = 280
+
+// microformatTypes are the classes of the links telling the type of a mention
+var microformatTypes = []struct {
+	class string
+	typ   Type
+}{
+	{"u-in-reply-to", TypeReply},
+	{"u-like-of", TypeLike},
+	{"u-repost-of", TypeRepost},
+	{"u-bookmark-of", TypeBookmark},
+}
+
+var (
+	ErrInvalidSource = errors.New("source must be an http(s) URL")
+	ErrInvalidTarget = errors.New("target is not a post of this site")
+	ErrSameURL       = errors.New("source and target must differ")
+	ErrQueueFull     = errors.New("too many webmentions waiting for verification")
+	ErrNoLink        = errors.New("source does not link to target")
+	ErrSourceGone    = errors.New("source is gone")
+	ErrClosed        = errors.New("webmentions are not accepted anymore")
+)
+
+type (
+	// ReceiverOptions are the options of a Receiver.
+	ReceiverOptions struct {
+		Logger *logger.Logger
+		Store  Store
+		// Client fetches the sources, NewClient(false) if nil
+		Client *http.Client
+		// QueueSize is the number of mentions waiting for verification, 100 if 0
+		QueueSize int
+	}
+
+	// Receiver accepts Webmentions and verifies them in the background.
+	Receiver struct {
+		logger *logger.Logger
+		store  Store
+		client *http.Client
+		queue  chan request
+		wg     sync.WaitGroup
+		// mu guards closed, so that no mention is queued once the queue is closed
+		mu     sync.RWMutex
+		closed bool
+		// now is the clock of the verification times
+		now func() time.Time
+	}
+
+	// request is an accepted Webmention waiting for verification.
+	request struct {
+		source string
+		target string
+		postID string
+	}
+)
+
+// NewReceiver returns a Receiver verifying the accepted mentions until it is closed.
+func NewReceiver(opts ReceiverOptions) *Receiver {
+	if opts.Client == nil {
+		opts.Client = NewClient(false)
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = 100
+	}
+	r := &Receiver{
+		logger: opts.Logger,
+		store:  opts.Store,
+		client: opts.Client,
+		queue:  make(chan request, opts.QueueSize),
+		now:    time.Now,
+	}
+	r.wg.Add(1)
+	go r.work()
+	return r
+}
+
+// Accept checks a Webmention of the post postID, found at target, and queues it for verification.
+// It returns an error if the mention is invalid, the queue is full or the Receiver is closed.
+func (r *Receiver) Accept(source string, target string, postID string) error {
+	if _, ok := validURL(source); !ok {
+		return ErrInvalidSource
+	}
+	if _, ok := validURL(target); !ok {
+		return ErrInvalidTarget
+	}
+	if source == target {
+		return ErrSameURL
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return ErrClosed
+	}
+	select {
+	case r.queue <- request{source: source, target: target, postID: postID}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops the verification once the queued mentions are verified, later mentions are refused.
+func (r *Receiver) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// Mentions returns the verified mentions of a post.
+func (r *Receiver) Mentions(postID string) ([]Mention, error) {
+	return r.store.ForPost(postID)
+}
+
+// work verifies the queued mentions one by one.
+func (r *Receiver) work() {
+	defer r.wg.Done()
+	for req := range r.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := r.verify(ctx, req)
+		cancel()
+		if err != nil {
+			r.logger.Warn().Err(err).Str("source", req.source).Str("target", req.target).Msg("webmention rejected")
+			continue
+		}
+		r.logger.Info().Str("source", req.source).Str("target", req.target).Msg("webmention verified")
+	}
+}
+
+// verify fetches the source of a mention and stores the mention if the source links to the target.
+// A mention whose source is gone or does not link to the target anymore is deleted.
+func (r *Receiver) verify(ctx context.Context, req request) error {
+	resp, doc, err := fetch(ctx, r.client, req.source)
+	if err != nil {
+		return fmt.Errorf("Receiver::verify: cannot fetch source : %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound:
+		err = ErrSourceGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("Receiver::verify: source answered %s", resp.Status)
+	case doc == nil:
+		err = ErrNoLink
+	}
+	var m Mention
+	if err == nil {
+		m, err = parseMention(doc, resp.Request.URL.String(), req.target)
+	}
+	if err != nil {
+		if deleteErr := r.store.Delete(req.source, req.target); deleteErr != nil {
+			return fmt.Errorf("Receiver::verify: %v", deleteErr)
+		}
+		return err
+	}
+	m.Source, m.PostID, m.Time = req.source, req.postID, r.now().UTC()
+	if err := r.store.Save(m); err != nil {
+		return fmt.Errorf("Receiver::verify: %v", err)
+	}
+	return nil
+}
+
+// parseMention reads the mention of target from the source page, located at pageURL.
+// The type, the author and the content come from the microformats of the page, if it has any.
+func parseMention(doc *html.Node, pageURL string, target string) (Mention, error) {
+	base, _ := validURL(pageURL)
+	m := Mention{Target: target, Type: TypeMention}
+	linked := false
+	walk(doc, func(n *html.Node) {
+		if (n.Data != "a" && n.Data != "link") || resolve(base, attr(n, "href")) != resolve(base, target) {
+			return
+		}
+		linked = true
+		for _, t := range microformatTypes {
+			if hasToken(n, "class", t.class) {
+				m.Type = t.typ
+			}
+		}
+	})
+	if !linked {
+		return Mention{}, ErrNoLink
+	}
+
+	entry := find(doc, func(n *html.Node) bool { return hasToken(n, "class", "h-entry") })
+	if entry == nil {
+		entry = doc
+	}
+	if author := find(entry, func(n *html.Node) bool { return hasToken(n, "class", "p-author") }); author != nil {
+		m.AuthorName = text(author)
+		if name := find(author, func(n *html.Node) bool { return hasToken(n, "class", "p-name") }); name != nil {
+			m.AuthorName = text(name)
+		}
+		if link := find(author, func(n *html.Node) bool { return n.Data == "a" && attr(n, "href") != "" }); link != nil {
+			authorURL := resolve(base, attr(link, "href"))
+			if _, ok := validURL(authorURL); ok {
+				m.AuthorURL = authorURL
+			}
+		}
+	}
+	if name := find(entry, func(n *html.Node) bool {
+		return hasToken(n, "class", "p-name") && !hasToken(n.Parent, "class", "p-author") && !hasToken(n.Parent, "class", "h-card")
+	}); name != nil {
+		m.Title = text(name)
+	} else if title := find(doc, func(n *html.Node) bool { return n.Data == "title" }); title != nil {
+		m.Title = text(title)
+	}
+	if content := find(entry, func(n *html.Node) bool {
+		return hasToken(n, "class", "e-content") || hasToken(n, "class", "p-content")
+	}); content != nil {
+		m.Content = shorten(text(content), maxContentLength)
+	}
+	return m, nil
+}
+
+// shorten cuts s to at most n characters, marking the cut with an ellipsis.
+func shorten(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
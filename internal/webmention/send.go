@@ -0,0 +1,165 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var ErrNoEndpoint = errors.New("no webmention endpoint")
+
+type (
+	// Sender notifies the pages linked from a post.
+	Sender struct {
+		client *http.Client
+		// site is the URL of the site, links to it are not notified
+		site string
+	}
+
+	// Result is the outcome of notifying a linked page.
+	Result struct {
+		Target   string
+		Endpoint string
+		// Status is the status code answered by the endpoint, 0 if it was not reached
+		Status int
+		Err    error
+	}
+)
+
+// NewSender returns a Sender notifying the pages linked from the posts of site with client, NewClient(false) if nil.
+func NewSender(client *http.Client, site string) *Sender {
+	if client == nil {
+		client = NewClient(false)
+	}
+	return &Sender{client: client, site: strings.TrimSuffix(site, "/")}
+}
+
+// Send notifies every page linked from content, the HTML of the page at source, which has a Webmention endpoint.
+// Pages without an endpoint get a Result with ErrNoEndpoint.
+func (s *Sender) Send(ctx context.Context, source string, content string) []Result {
+	var results []Result
+	for _, target := range s.Links(source, content) {
+		result := Result{Target: target}
+		result.Endpoint, result.Err = s.Discover(ctx, target)
+		if result.Err == nil {
+			result.Status, result.Err = s.notify(ctx, result.Endpoint, source, target)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Links returns the http(s) links of content, the HTML of the page at source, to other sites, without duplicates.
+func (s *Sender) Links(source string, content string) []string {
+	base, ok := validURL(source)
+	if !ok {
+		return nil
+	}
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil
+	}
+	var links []string
+	seen := map[string]bool{}
+	walk(doc, func(n *html.Node) {
+		if n.Data != "a" {
+			return
+		}
+		link := resolve(base, attr(n, "href"))
+		if _, ok := validURL(link); !ok || seen[link] || link == s.site || strings.HasPrefix(link, s.site+"/") {
+			return
+		}
+		seen[link] = true
+		links = append(links, link)
+	})
+	return links
+}
+
+// Discover returns the Webmention endpoint of the page at target, announced by a Link header
+// or else by the first link or a element with the webmention rel.
+func (s *Sender) Discover(ctx context.Context, target string) (string, error) {
+	resp, doc, err := fetch(ctx, s.client, target)
+	if err != nil {
+		return "", fmt.Errorf("Sender::Discover: cannot fetch target : %w", err)
+	}
+	base := resp.Request.URL
+	for _, header := range resp.Header.Values("Link") {
+		if href, ok := webmentionLink(header); ok {
+			return resolve(base, href), nil
+		}
+	}
+	if doc != nil {
+		element := find(doc, func(n *html.Node) bool {
+			_, hasHref := attrOK(n, "href")
+			return (n.Data == "link" || n.Data == "a") && hasHref && hasToken(n, "rel", "webmention")
+		})
+		if element != nil {
+			// an empty href is the target itself
+			return resolve(base, attr(element, "href")), nil
+		}
+	}
+	return "", ErrNoEndpoint
+}
+
+// notify sends the Webmention of source linking to target to endpoint.
+func (s *Sender) notify(ctx context.Context, endpoint string, source string, target string) (int, error) {
+	if _, ok := validURL(endpoint); !ok {
+		return 0, fmt.Errorf("Sender::notify: invalid endpoint %q", endpoint)
+	}
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Sender::notify: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxPageSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("Sender::notify: endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webmentionLink returns the URL of a Link header value with the webmention rel.
+func webmentionLink(header string) (string, bool) {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		href := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(href, "<") || !strings.HasSuffix(href, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(name, "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+				if strings.EqualFold(rel, "webmention") {
+					return href[1 : len(href)-1], true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// attrOK returns an attribute of an element and whether it is set.
+func attrOK(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
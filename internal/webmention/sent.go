@@ -0,0 +1,76 @@
+package webmention
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/fileutil"
+)
+
+// SentLog keeps when the pages linked from each post were last notified, in a json file loaded in memory.
+type SentLog struct {
+	fileName string
+	mu       sync.Mutex
+	sent     map[string]time.Time
+	// fresh is true until the file is written for the first time
+	fresh bool
+}
+
+// NewSentLog returns the SentLog kept in the json file fileName, created on the first notified post.
+func NewSentLog(fileName string) (*SentLog, error) {
+	l := &SentLog{fileName: fileName, sent: make(map[string]time.Time)}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		l.fresh = true
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NewSentLog: cannot read sent webmentions : %v", err)
+	}
+	if err := json.Unmarshal(data, &l.sent); err != nil {
+		return nil, fmt.Errorf("NewSentLog: cannot decode sent webmentions : %v", err)
+	}
+	return l, nil
+}
+
+// Fresh reports whether the log has never been written, so that it knows nothing about the posts published so far.
+func (l *SentLog) Fresh() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fresh
+}
+
+// Sent reports whether the pages linked from the post postID have been notified.
+func (l *SentLog) Sent(postID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.sent[postID]
+	return ok
+}
+
+// MarkSent records that the pages linked from the posts postIDs were notified at t.
+func (l *SentLog) MarkSent(t time.Time, postIDs ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sent := make(map[string]time.Time, len(l.sent)+len(postIDs))
+	for id, at := range l.sent {
+		sent[id] = at
+	}
+	for _, id := range postIDs {
+		sent[id] = t
+	}
+	data, err := json.MarshalIndent(sent, "", "  ")
+	if err != nil {
+		return fmt.Errorf("SentLog::MarkSent: cannot encode sent webmentions : %v", err)
+	}
+	if err := fileutil.WriteAtomic(l.fileName, append(data, '\n')); err != nil {
+		return fmt.Errorf("SentLog::MarkSent: cannot write sent webmentions : %v", err)
+	}
+	l.sent = sent
+	l.fresh = false
+	return nil
+}
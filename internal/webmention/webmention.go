@@ -0,0 +1,154 @@
+// Package webmention receives and sends Webmentions (https://www.w3.org/TR/webmention/),
+// the notifications of the IndieWeb that a page links to another one.
+package webmention
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/fileutil"
+)
+
+const (
+	// TypeMention is a page linking to a post.
+	TypeMention Type = "mention"
+	// TypeReply is a reply to a post.
+	TypeReply Type = "reply"
+	// TypeLike is a like of a post.
+	TypeLike Type = "like"
+	// TypeRepost is a repost of a post.
+	TypeRepost Type = "repost"
+	// TypeBookmark is a bookmark of a post.
+	TypeBookmark Type = "bookmark"
+)
+
+type (
+	// Type is the kind of a mention, read from the microformats of its source.
+	Type string
+
+	// Mention is a verified Webmention of a post.
+	Mention struct {
+		// Source is the URL of the page mentioning the post
+		Source string `json:"source"`
+		// Target is the URL of the mentioned post
+		Target string `json:"target"`
+		PostID string `json:"postId"`
+		Type   Type   `json:"type"`
+		// Title is the name or the title of the source page
+		Title      string `json:"title,omitempty"`
+		AuthorName string `json:"authorName,omitempty"`
+		AuthorURL  string `json:"authorUrl,omitempty"`
+		// Content is the text of a reply, shortened
+		Content string `json:"content,omitempty"`
+		// Time is when the mention was last verified
+		Time time.Time `json:"time"`
+	}
+
+	// Store keeps the verified mentions.
+	Store interface {
+		// Save adds a mention or replaces the mention of the same source and target.
+		Save(m Mention) error
+		// Delete removes the mention of a source and a target, if there is one.
+		Delete(source string, target string) error
+		// ForPost returns the mentions of a post, oldest first.
+		ForPost(postID string) ([]Mention, error)
+	}
+
+	// fileStore is a Store keeping the mentions in a json file, loaded in memory.
+	fileStore struct {
+		fileName string
+		mu       sync.RWMutex
+		mentions []Mention
+	}
+)
+
+// NewFileStore returns a Store keeping the mentions in the json file fileName, created on the first mention.
+func NewFileStore(fileName string) (Store, error) {
+	s := &fileStore{fileName: fileName}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NewFileStore: cannot read webmentions : %v", err)
+	}
+	if err := json.Unmarshal(data, &s.mentions); err != nil {
+		return nil, fmt.Errorf("NewFileStore: cannot decode webmentions : %v", err)
+	}
+	return s, nil
+}
+
+// Save implements Store.
+func (s *fileStore) Save(m Mention) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mentions := append([]Mention(nil), s.mentions...)
+	if i := s.index(m.Source, m.Target); i >= 0 {
+		mentions[i] = m
+	} else {
+		mentions = append(mentions, m)
+	}
+	if err := s.save(mentions); err != nil {
+		return fmt.Errorf("fileStore::Save: %v", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *fileStore) Delete(source string, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(source, target)
+	if i < 0 {
+		return nil
+	}
+	mentions := append(append([]Mention(nil), s.mentions[:i]...), s.mentions[i+1:]...)
+	if err := s.save(mentions); err != nil {
+		return fmt.Errorf("fileStore::Delete: %v", err)
+	}
+	return nil
+}
+
+// ForPost implements Store.
+func (s *fileStore) ForPost(postID string) ([]Mention, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var mentions []Mention
+	for _, m := range s.mentions {
+		if m.PostID == postID {
+			mentions = append(mentions, m)
+		}
+	}
+	sort.SliceStable(mentions, func(i, j int) bool {
+		return mentions[i].Time.Before(mentions[j].Time)
+	})
+	return mentions, nil
+}
+
+// index returns the position of the mention of source and target, -1 if there is none.
+func (s *fileStore) index(source string, target string) int {
+	for i, m := range s.mentions {
+		if m.Source == source && m.Target == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the mentions to the file and keeps them if the write succeeded.
+func (s *fileStore) save(mentions []Mention) error {
+	data, err := json.MarshalIndent(mentions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode webmentions : %v", err)
+	}
+	if err := fileutil.WriteAtomic(s.fileName, append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write webmentions : %v", err)
+	}
+	s.mentions = mentions
+	return nil
+}
@@ -0,0 +1,222 @@
+package webmention
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kegliz/silent-blog/internal/server/logger"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const target = "https://blog.example/post/first"
+
+// TestParseMention tests reading the type, the author and the content of mentions
+func TestParseMention(t *testing.T) {
+	assert := assert.New(t)
+
+	parse := func(page string) (Mention, error) {
+		doc, err := html.Parse(strings.NewReader(page))
+		assert.Nil(err)
+		return parseMention(doc, "https://other.example/notes/1", target)
+	}
+
+	m, err := parse(`<html><head><title>Site</title></head><body><div class="h-entry">
+		<a class="p-author h-card" href="/"><img src="/me.png"><span class="p-name">Ann Other</span></a>
+		<a class="u-in-reply-to" href="https://blog.example/post/first#top">in reply to</a>
+		<div class="e-content">  Great
+			post! </div></div></body></html>`)
+	assert.Nil(err)
+	assert.Equal(TypeReply, m.Type)
+	assert.Equal("Ann Other", m.AuthorName)
+	assert.Equal("https://other.example/", m.AuthorURL)
+	assert.Equal("Great post!", m.Content)
+	assert.Equal("Site", m.Title)
+
+	m, err = parse(`<div class="h-entry"><span class="p-author">Bob</span><a class="u-like-of" href="https://blog.example/post/first">liked</a></div>`)
+	assert.Nil(err)
+	assert.Equal(TypeLike, m.Type)
+	assert.Equal("Bob", m.AuthorName)
+
+	m, err = parse(`<article class="h-entry"><h1 class="p-name">Links of the week</h1>
+		<p class="p-author h-card"><a href="javascript:alert(1)" class="p-name">Eve</a></p>
+		<p>Read <a href="https://blog.example/post/first">this</a>.</p></article>`)
+	assert.Nil(err)
+	assert.Equal(TypeMention, m.Type)
+	assert.Equal("Links of the week", m.Title)
+	assert.Equal("Eve", m.AuthorName)
+	assert.Empty(m.AuthorURL, "only http(s) author links should be kept")
+	assert.Empty(m.Content)
+
+	_, err = parse(`<p>Read <a href="https://blog.example/post/second">this</a> and https://blog.example/post/first.</p>`)
+	assert.ErrorIs(err, ErrNoLink)
+
+	assert.Equal("abc", shorten("abc", 3))
+	assert.Equal("ab…", shorten("abcd", 3))
+}
+
+// TestReceiver tests accepting, verifying and deleting mentions
+func TestReceiver(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	pages := map[string]string{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		page, ok := pages[r.URL.Path]
+		mu.Unlock()
+		if !ok {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	defer remote.Close()
+	setPage := func(path string, page string) {
+		mu.Lock()
+		defer mu.Unlock()
+		pages[path] = page
+	}
+
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "webmentions.json"))
+	assert.Nil(err)
+	newReceiver := func() *Receiver {
+		return NewReceiver(ReceiverOptions{Logger: logger.NewLogger(logger.LoggerOptions{}), Store: store, Client: NewClient(true)})
+	}
+
+	r := newReceiver()
+	assert.ErrorIs(r.Accept("ftp://other.example/", target, "first"), ErrInvalidSource)
+	assert.ErrorIs(r.Accept(remote.URL+"/like", "first", "first"), ErrInvalidTarget)
+	assert.ErrorIs(r.Accept(target, target, "first"), ErrSameURL)
+
+	setPage("/like", `<div class="h-entry"><a class="u-like-of" href="`+target+`">like</a></div>`)
+	setPage("/mention", `<p>See <a href="`+target+`">the post</a></p>`)
+	setPage("/spam", `<p>Buy things</p>`)
+	for _, path := range []string{"/like", "/mention", "/spam", "/missing"} {
+		assert.Nil(r.Accept(remote.URL+path, target, "first"))
+	}
+	r.Close()
+	mentions, err := store.ForPost("first")
+	assert.Nil(err)
+	assert.Len(mentions, 2, "only the sources linking to the post should be kept")
+	assert.Equal(TypeLike, mentions[0].Type)
+	assert.Equal(remote.URL+"/like", mentions[0].Source)
+	assert.Equal(TypeMention, mentions[1].Type)
+
+	// updated and deleted sources are verified again
+	setPage("/like", `<div class="h-entry"><a class="u-repost-of" href="`+target+`">repost</a></div>`)
+	setPage("/mention", `<p>No link anymore</p>`)
+	r = newReceiver()
+	assert.Nil(r.Accept(remote.URL+"/like", target, "first"))
+	assert.Nil(r.Accept(remote.URL+"/mention", target, "first"))
+	r.Close()
+	assert.ErrorIs(r.Accept(remote.URL+"/like", target, "first"), ErrClosed, "a closed receiver should refuse mentions")
+	r.Close()
+	mentions, err = store.ForPost("first")
+	assert.Nil(err)
+	assert.Len(mentions, 1)
+	assert.Equal(TypeRepost, mentions[0].Type)
+
+	reloaded, err := NewFileStore(store.(*fileStore).fileName)
+	assert.Nil(err)
+	mentions, err = reloaded.ForPost("first")
+	assert.Nil(err)
+	assert.Len(mentions, 1, "the mentions should be kept in the file")
+
+	private := NewReceiver(ReceiverOptions{Logger: logger.NewLogger(logger.LoggerOptions{}), Store: store})
+	assert.Nil(private.Accept(remote.URL+"/like", target, "first"))
+	private.Close()
+	mentions, err = store.ForPost("first")
+	assert.Nil(err)
+	assert.Len(mentions, 1, "a failed fetch should not delete the mention")
+}
+
+// TestSender tests discovering the endpoints of the linked pages and notifying them
+func TestSender(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	received := map[string]string{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.Method == http.MethodPost {
+			mu.Lock()
+			received[r.PostFormValue("target")] = r.URL.String()
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Add("Link", `<https://example.com/other>; rel="other", </endpoint?from=header>; rel="webmention"`)
+		case "/element":
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/style.css"></head><body><a rel="nofollow webmention" href="">endpoint</a></body></html>`)
+		case "/redirect":
+			http.Redirect(w, r, "/element", http.StatusFound)
+		case "/plain":
+			fmt.Fprint(w, `<p>no endpoint</p>`)
+		}
+	}))
+	defer remote.Close()
+
+	s := NewSender(NewClient(true), "https://blog.example/")
+	content := `<p><a href="` + remote.URL + `/header">one</a> <a href="` + remote.URL + `/redirect#part">two</a>
+		<a href="` + remote.URL + `/plain">three</a> <a href="` + remote.URL + `/header">again</a>
+		<a href="/post/second">own</a> <a href="https://blog.example/tags/go">own tag</a> <a href="mailto:me@example.com">mail</a></p>`
+	assert.Equal([]string{remote.URL + "/header", remote.URL + "/redirect", remote.URL + "/plain"}, s.Links(target, content))
+
+	results := s.Send(context.Background(), target, content)
+	assert.Len(results, 3)
+	assert.Equal(remote.URL+"/endpoint?from=header", results[0].Endpoint)
+	assert.Nil(results[0].Err)
+	assert.Equal(http.StatusAccepted, results[0].Status)
+	assert.Equal(remote.URL+"/element", results[1].Endpoint, "an empty href should be the page after redirects")
+	assert.Nil(results[1].Err)
+	assert.ErrorIs(results[2].Err, ErrNoEndpoint)
+	assert.Equal(map[string]string{
+		remote.URL + "/header":   "/endpoint?from=header",
+		remote.URL + "/redirect": "/element",
+	}, received)
+
+	_, err := NewSender(nil, "https://blog.example").Discover(context.Background(), remote.URL+"/header")
+	assert.ErrorIs(err, ErrPrivateAddress, "the default client should not reach private addresses")
+
+	href, ok := webmentionLink(`<https://example.com/wm>; rel="webmention"`)
+	assert.True(ok)
+	assert.Equal("https://example.com/wm", href)
+	href, ok = webmentionLink(`<https://example.com/wm>; rel=webmention`)
+	assert.True(ok)
+	assert.Equal("https://example.com/wm", href)
+	_, ok = webmentionLink(`<https://example.com/other>; rel="webmention.org"`)
+	assert.False(ok)
+}
+
+// TestSentLog tests recording the notified posts in a file
+func TestSentLog(t *testing.T) {
+	assert := assert.New(t)
+
+	fileName := filepath.Join(t.TempDir(), "sent.json")
+	l, err := NewSentLog(fileName)
+	assert.Nil(err)
+	assert.True(l.Fresh(), "a log without file should be fresh")
+	assert.False(l.Sent("first"))
+
+	assert.Nil(l.MarkSent(time.Now(), "first", "second"))
+	assert.False(l.Fresh())
+	assert.True(l.Sent("first"))
+	assert.True(l.Sent("second"))
+	assert.False(l.Sent("third"))
+
+	reloaded, err := NewSentLog(fileName)
+	assert.Nil(err)
+	assert.False(reloaded.Fresh(), "a log with a file should not be fresh")
+	assert.True(reloaded.Sent("second"), "the notified posts should be kept in the file")
+}
@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultDateLayout is the layout of displayed dates when no DateFormat is set
+const DefaultDateLayout = time.DateOnly
+
+// DateFormat controls how dates are displayed
+type DateFormat struct {
+	// Layout is the time layout of the dates, DefaultDateLayout if empty
+	Layout string
+	// Relative displays dates as "3 days ago", with the formatted date as a tooltip
+	Relative bool
+	// Location is the timezone dates are displayed in, the zone of the date if nil
+	Location *time.Location
+}
+
+type dateFormatKey struct{}
+
+// WithDateFormat returns a context that makes the templates display dates in the given format
+func WithDateFormat(ctx context.Context, format DateFormat) context.Context {
+	return context.WithValue(ctx, dateFormatKey{}, format)
+}
+
+// dateFormat returns the DateFormat of the context
+func dateFormat(ctx context.Context) DateFormat {
+	format, _ := ctx.Value(dateFormatKey{}).(DateFormat)
+	if format.Layout == "" {
+		format.Layout = DefaultDateLayout
+	}
+	return format
+}
+
+// FormatDate returns a date formatted with the layout of the context, ignoring Relative
+func FormatDate(ctx context.Context, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	format := dateFormat(ctx)
+	if format.Location != nil {
+		t = t.In(format.Location)
+	}
+	return t.Format(format.Layout)
+}
+
+// FormatTime returns a point in time, like the time of a revision, to the second in the timezone of the context
+func FormatTime(ctx context.Context, t time.Time) string {
+	if location := dateFormat(ctx).Location; location != nil {
+		t = t.In(location)
+	}
+	return t.Format("2006-01-02 15:04:05 MST")
+}
+
+// DisplayDate returns a date as displayed by the templates, relative in the language of the context if it asks for it
+func DisplayDate(ctx context.Context, t time.Time) string {
+	if !t.IsZero() && dateFormat(ctx).Relative {
+		return relativeDate(Lang(ctx), t, time.Now())
+	}
+	return FormatDate(ctx, t)
+}
+
+// RelativeDate describes how long before or after now t is, like "3 days ago" or "in 2 hours"
+func RelativeDate(t time.Time, now time.Time) string {
+	return relativeDate(DefaultLang, t, now)
+}
+
+// relativeDate is RelativeDate in the language lang
+func relativeDate(lang string, t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	direction := "date.ago."
+	if future {
+		d = -d
+		direction = "date.in."
+	}
+	days := int(d.Hours() / 24)
+
+	switch {
+	case d < time.Minute:
+		return message(lang, "date.now")
+	case d < time.Hour:
+		return countMessage(lang, direction+"minute", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return countMessage(lang, direction+"hour", int(d.Hours()))
+	case days == 1 && future:
+		return message(lang, "date.tomorrow")
+	case days == 1:
+		return message(lang, "date.yesterday")
+	case days < 7:
+		return countMessage(lang, direction+"day", days)
+	case days < 30:
+		return countMessage(lang, direction+"week", days/7)
+	case days < 365:
+		return countMessage(lang, direction+"month", days/30)
+	default:
+		return countMessage(lang, direction+"year", days/365)
+	}
+}
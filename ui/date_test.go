@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRelativeDate is a test for RelativeDate
+func TestRelativeDate(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		0:                     "just now",
+		-5 * time.Minute:      "5 minutes ago",
+		-time.Hour:            "1 hour ago",
+		-30 * time.Hour:       "yesterday",
+		-3 * 24 * time.Hour:   "3 days ago",
+		-15 * 24 * time.Hour:  "2 weeks ago",
+		-90 * 24 * time.Hour:  "3 months ago",
+		-800 * 24 * time.Hour: "2 years ago",
+		2 * time.Hour:         "in 2 hours",
+		30 * time.Hour:        "tomorrow",
+	}
+	for offset, want := range tests {
+		assert.Equal(want, RelativeDate(now.Add(offset), now), offset.String())
+	}
+}
+
+// TestFormatDate is a test for FormatDate and DisplayDate with a DateFormat in the context
+func TestFormatDate(t *testing.T) {
+	assert := assert.New(t)
+
+	date := time.Date(2023, 10, 2, 22, 30, 0, 0, time.UTC)
+	assert.Equal("2023-10-02", FormatDate(context.Background(), date), "default layout")
+	assert.Equal("", FormatDate(context.Background(), time.Time{}))
+
+	budapest, err := time.LoadLocation("Europe/Budapest")
+	assert.Nil(err)
+	ctx := WithDateFormat(context.Background(), DateFormat{Layout: "Jan 2, 2006", Location: budapest})
+	assert.Equal("Oct 3, 2023", FormatDate(ctx, date), "date should be shown in the site timezone")
+	assert.Equal("Oct 3, 2023", DisplayDate(ctx, date))
+
+	ctx = WithDateFormat(context.Background(), DateFormat{Relative: true})
+	assert.Equal("yesterday", DisplayDate(ctx, time.Now().Add(-30*time.Hour)))
+	assert.Equal("tegnap", DisplayDate(WithLang(ctx, "hu"), time.Now().Add(-30*time.Hour)), "relative dates should be in the language of the context")
+}
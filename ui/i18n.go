@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLang is the language of the templates when the context sets none,
+// its catalog provides the messages missing from the other catalogs.
+const DefaultLang = "en"
+
+// messageFiles are the message catalogs of the templates, one json object per language
+//
+//go:embed messages/*.json
+var messageFiles embed.FS
+
+// catalogs are the messages of the templates by language and key
+var catalogs = loadCatalogs()
+
+// Alternate is a version of a page in another language
+type Alternate struct {
+	// Lang is the language of the version, or x-default for the page picking the language of the reader
+	Lang string
+	URL  string
+}
+
+type (
+	langKey       struct{}
+	alternatesKey struct{}
+)
+
+// loadCatalogs reads the embedded message catalogs, named after their language.
+func loadCatalogs() map[string]map[string]string {
+	files, err := messageFiles.ReadDir("messages")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := messageFiles.ReadFile("messages/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("ui: invalid message catalog %s: %v", file.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(file.Name(), path.Ext(file.Name()))] = messages
+	}
+	return catalogs
+}
+
+// Languages returns the languages the templates have a message catalog for, sorted
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// WithLang returns a context that makes the templates speak the given language
+func WithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// Lang returns the language of the context, DefaultLang if it sets none
+func Lang(ctx context.Context) string {
+	if lang, _ := ctx.Value(langKey{}).(string); lang != "" {
+		return lang
+	}
+	return DefaultLang
+}
+
+// WithAlternates returns a context that makes the page link its versions in other languages
+func WithAlternates(ctx context.Context, alternates []Alternate) context.Context {
+	return context.WithValue(ctx, alternatesKey{}, alternates)
+}
+
+// alternates returns the versions of the page in other languages set in the context
+func alternates(ctx context.Context) []Alternate {
+	alternates, _ := ctx.Value(alternatesKey{}).([]Alternate)
+	return alternates
+}
+
+// T returns the message of key in the language of the context, formatted with args if there are any
+func T(ctx context.Context, key string, args ...interface{}) string {
+	msg := message(Lang(ctx), key)
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// N returns the message of key formatted with count in the language of the context,
+// the key.one message of the catalog if count is 1 and the language has a singular form
+func N(ctx context.Context, key string, count int) string {
+	return countMessage(Lang(ctx), key, count)
+}
+
+// LanguageName returns the name of a language in that language, the tag itself if it has no catalog
+func LanguageName(lang string) string {
+	if name := catalog(lang)["language.name"]; name != "" {
+		return name
+	}
+	return lang
+}
+
+// catalog returns the messages of lang, or of its base language like hu for hu-HU, nil if neither has a catalog
+func catalog(lang string) map[string]string {
+	if messages, ok := catalogs[lang]; ok {
+		return messages
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	return catalogs[strings.ToLower(base)]
+}
+
+// message returns the first of keys found in the catalog of lang, or else in the catalog of DefaultLang.
+// It returns the first key if neither catalog has any of them.
+func message(lang string, keys ...string) string {
+	for _, messages := range []map[string]string{catalog(lang), catalogs[DefaultLang]} {
+		for _, key := range keys {
+			if msg, ok := messages[key]; ok {
+				return msg
+			}
+		}
+	}
+	return keys[0]
+}
+
+// countMessage returns the message of key in lang formatted with count, preferring key.one if count is 1
+func countMessage(lang string, key string, count int) string {
+	if count == 1 {
+		return fmt.Sprintf(message(lang, key+".one", key), count)
+	}
+	return fmt.Sprintf(message(lang, key), count)
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCatalogs is a test that every message catalog translates every message of the default catalog
+func TestCatalogs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"en", "hu"}, Languages())
+	for _, lang := range Languages() {
+		for key := range catalogs[DefaultLang] {
+			if strings.HasSuffix(key, ".one") {
+				continue
+			}
+			assert.Contains(catalogs[lang], key, "catalog %s", lang)
+		}
+	}
+}
+
+// TestT is a test for T and N
+func TestT(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	assert.Equal("en", Lang(ctx))
+	assert.Equal("Posts", T(ctx, "nav.posts"))
+	assert.Equal("1 word", N(ctx, "post.words", 1))
+	assert.Equal("2 words", N(ctx, "post.words", 2))
+	assert.Equal("no.such.key", T(ctx, "no.such.key"))
+
+	hu := WithLang(ctx, "hu-HU")
+	assert.Equal("Bejegyzések", T(hu, "nav.posts"), "regional languages should use the catalog of their base language")
+	assert.Equal("1 szó", N(hu, "post.words", 1))
+	assert.Equal("5 perc olvasás", T(hu, "post.readingTime", 5))
+	assert.Equal("Posts", T(WithLang(ctx, "fr"), "nav.posts"), "languages without a catalog should fall back to the default")
+
+	assert.Equal("Magyar", LanguageName("hu"))
+	assert.Equal("fr", LanguageName("fr"))
+}
+
+// TestRelativeDateLang is a test for relativeDate in a language without singular forms
+func TestRelativeDateLang(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		0:                   "épp most",
+		-time.Hour:          "1 órája",
+		-3 * 24 * time.Hour: "3 napja",
+		30 * time.Hour:      "holnap",
+		14 * 24 * time.Hour: "2 hét múlva",
+	}
+	for offset, want := range tests {
+		assert.Equal(want, relativeDate("hu", now.Add(offset), now), offset.String())
+	}
+}
@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"context"
+
+	"github.com/kegliz/silent-blog/internal/author"
+)
+
+// DefaultSiteTitle is the name of the site in the header when no Site is set
+const DefaultSiteTitle = "Silent Blog"
+
+// Site is what the templates know of the whole site
+type Site struct {
+	// Title is the name of the site in the header, DefaultSiteTitle if empty
+	Title string
+	// Lang is the language of the site and of the posts that do not declare one, DefaultLang if empty
+	Lang string
+	// Authors are the authors named in the bylines, nil if the posts have none
+	Authors *author.Directory
+	// Projects tells whether the header links the projects page
+	Projects bool
+}
+
+type siteKey struct{}
+
+// WithSite returns a context that makes the templates present the given site
+func WithSite(ctx context.Context, s Site) context.Context {
+	return context.WithValue(ctx, siteKey{}, s)
+}
+
+// site returns the Site of the context
+func site(ctx context.Context) Site {
+	s, _ := ctx.Value(siteKey{}).(Site)
+	if s.Title == "" {
+		s.Title = DefaultSiteTitle
+	}
+	if s.Lang == "" {
+		s.Lang = DefaultLang
+	}
+	return s
+}
@@ -13,10 +13,20 @@ func TestConvertMdFileToHTML(t *testing.T) {
 
 	html, err := ConvertMdFileToHTML("testdata/blog_1.md")
 	assert.Nil(err)
-	assert.Contains(html, "<h1>Heading 1</h1>")
-	assert.Contains(html, "<h2>Heading 2</h2>")
-	assert.Contains(html, "<h3>Heading 3</h3>")
+	assert.Contains(html, "<h1 id=\"heading-1\">Heading 1<a")
+	assert.Contains(html, "<h2 id=\"heading-2\">Heading 2<a")
+	assert.Contains(html, "<h3 id=\"heading-3\">Heading 3<a")
 	// write it out into a file to check it later
 	os.WriteFile("testdata/blog_1.html", []byte(html), 0644)
 
 }
+
+// TestConvertMdFileToHTMLStripsFrontMatter is a test for ConvertMdFileToHTML with a front matter block
+func TestConvertMdFileToHTMLStripsFrontMatter(t *testing.T) {
+	assert := assert.New(t)
+
+	html, err := ConvertMdFileToHTML("testdata/frontmatter.md")
+	assert.Nil(err)
+	assert.Contains(html, "<h1 id=\"body-heading\">Body heading<a")
+	assert.NotContains(html, "title:")
+}
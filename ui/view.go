@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kegliz/silent-blog/internal/author"
+	"github.com/kegliz/silent-blog/internal/comment"
+	"github.com/kegliz/silent-blog/internal/diff"
+	"github.com/kegliz/silent-blog/internal/post"
+	"github.com/kegliz/silent-blog/internal/project"
+	"github.com/kegliz/silent-blog/internal/render"
+	"github.com/kegliz/silent-blog/internal/webmention"
+)
+
+// PostView is everything presented on a post page
+type PostView struct {
+	Post        post.Post
+	HTMLContent string
+	// TOC is the table of contents of the post, empty if it is too short or opts out
+	TOC []render.TOCEntry
+	// Translations are the versions of the post in other languages, ordered by language
+	Translations []post.Post
+	// Series holds the parts of the series of the post in order, empty if the post stands alone
+	Series []post.Post
+	// Neighbours are the chronological neighbours and the related posts linked at the bottom
+	Neighbours post.Neighbours
+	// Projects are the projects sharing a tag with the post
+	Projects []project.Project
+	// Comments is nil when the comments are disabled
+	Comments *CommentsView
+	// Mentions are the verified webmentions of the post, oldest first
+	Mentions []webmention.Mention
+}
+
+// PostListView is a page of a list of posts
+type PostListView struct {
+	Page post.PostPage
+	// BaseURL is the URL of the list, the following pages add their cursor to it
+	BaseURL string
+	// CommentCounts holds the number of approved comments by post ID
+	CommentCounts map[string]int
+}
+
+// AuthorView is the page of an author
+type AuthorView struct {
+	Author author.Author
+	// BioHTML is the HTML of the markdown bio
+	BioHTML string
+	// Posts are the posts of the author, newest first
+	Posts []post.Post
+	// CommentCounts holds the number of approved comments by post ID
+	CommentCounts map[string]int
+}
+
+// ProjectView is the page of a project
+type ProjectView struct {
+	Project project.Project
+	// DetailHTML is the HTML of the markdown detail file, empty if the project has none
+	DetailHTML string
+	// Posts are the posts sharing a tag with the project, newest first
+	Posts []post.Post
+	// CommentCounts holds the number of approved comments by post ID
+	CommentCounts map[string]int
+}
+
+// CommentsView is the comment section under a post
+type CommentsView struct {
+	// Comments are the approved comments, oldest first
+	Comments []comment.Comment
+	Form     CommentFormView
+}
+
+// CommentFormView is the form of a new comment on a post
+type CommentFormView struct {
+	PostID string
+	// Token is the signed time the form was shown, checked against spam
+	Token  string
+	Author string
+	Body   string
+	// Errors maps the invalid fields to their problem
+	Errors map[string]string
+	// Message tells the outcome of the last submission
+	Message string
+}
+
+// CommentQueueView is the moderation queue of the comments
+type CommentQueueView struct {
+	// Pending are the comments waiting for moderation, oldest first
+	Pending []comment.Comment
+	// Message tells the outcome of the last moderation
+	Message string
+}
+
+// EditorView is the state of the post editor
+type EditorView struct {
+	// Original is the ID of the edited post, empty for a new post
+	Original string
+	Input    post.PostInput
+	// Errors maps the invalid fields of the input to their problem
+	Errors map[string]string
+	// Message tells the outcome of the last save
+	Message string
+	// Preview is the HTML of the markdown body
+	Preview string
+}
+
+// RevisionsView lists the saved versions of a post
+type RevisionsView struct {
+	PostID string
+	// Revisions are ordered newest first
+	Revisions []post.Revision
+	// Message tells the outcome of the last restore
+	Message string
+}
+
+// DiffView compares two revisions of a post line by line
+type DiffView struct {
+	PostID string
+	From   post.Revision
+	To     post.Revision
+	Lines  []diff.Line
+}
+
+// PreviousPart returns the part of the series before the post, nil if there is none
+func (v PostView) PreviousPart() *post.Post {
+	if i := v.seriesIndex(); i > 0 {
+		return &v.Series[i-1]
+	}
+	return nil
+}
+
+// NextPart returns the part of the series after the post, nil if there is none
+func (v PostView) NextPart() *post.Post {
+	if i := v.seriesIndex(); i >= 0 && i < len(v.Series)-1 {
+		return &v.Series[i+1]
+	}
+	return nil
+}
+
+// seriesIndex returns the position of the post in its series, -1 if it is not listed
+func (v PostView) seriesIndex() int {
+	for i, p := range v.Series {
+		if p.ID == v.Post.ID {
+			return i
+		}
+	}
+	return -1
+}
+
+// MentionsOf returns the webmentions of the post of a type
+func (v PostView) MentionsOf(t webmention.Type) []webmention.Mention {
+	var mentions []webmention.Mention
+	for _, m := range v.Mentions {
+		if m.Type == t {
+			mentions = append(mentions, m)
+		}
+	}
+	return mentions
+}
+
+// TagsText returns the tags of the edited post as written in the editor
+func (v EditorView) TagsText() string {
+	return strings.Join(v.Input.Tags, ", ")
+}
+
+// AuthorsText returns the author IDs of the edited post as written in the editor
+func (v EditorView) AuthorsText() string {
+	return strings.Join(v.Input.Authors, ", ")
+}
+
+// SeriesName returns the series of the edited post, empty if it stands alone
+func (v EditorView) SeriesName() string {
+	if v.Input.Series == nil {
+		return ""
+	}
+	return v.Input.Series.Name
+}
+
+// SeriesPart returns the part number of the edited post in its series, empty if it has none
+func (v EditorView) SeriesPart() string {
+	if v.Input.Series == nil || v.Input.Series.Part == 0 {
+		return ""
+	}
+	return strconv.Itoa(v.Input.Series.Part)
+}